@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/llm-inferno/queue-analysis/pkg/calibration"
+)
+
+// fit the service parameters (alpha, beta, gamma) to benchmark observations given as a json
+// calibration problem, e.g.
+//
+//	go run ./cmd/calibrate -f observations.json
+//
+// where observations.json holds {"maxNumTokens": 8192, "confidence": 0.95, "observations": [
+// {"batchSize": 16, "inputTokens": 512, "outputTokens": 256, "itl": 21.3, "ttft": 180.2}, ...]}
+func main() {
+	fileName := flag.String("f", "", "json calibration problem file (default stdin)")
+	confidence := flag.Float64("confidence", 0, "confidence level of the parameter intervals (overrides the file)")
+	maxNumTokens := flag.Int("maxNumTokens", 0, "maximum number of tokens per batch (overrides the file)")
+	asJSON := flag.Bool("json", false, "print the result as json")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if *fileName != "" {
+		file, err := os.Open(*fileName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open failed: %v\n", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		input = file
+	}
+	problem := &calibration.Problem{}
+	if err := json.NewDecoder(input).Decode(problem); err != nil {
+		fmt.Fprintf(os.Stderr, "decode failed: %v\n", err)
+		os.Exit(1)
+	}
+	if *confidence > 0 {
+		problem.Confidence = float32(*confidence)
+	}
+	if *maxNumTokens > 0 {
+		problem.MaxNumTokens = *maxNumTokens
+	}
+
+	res, err := calibration.Calibrate(problem.Observations, &problem.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Calibrate failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(res); err != nil {
+			fmt.Fprintf(os.Stderr, "encode failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Printf("alpha = %s\n", res.Alpha)
+	fmt.Printf("beta  = %s\n", res.Beta)
+	fmt.Printf("gamma = %s\n", res.Gamma)
+	fmt.Printf("confidence=%.2f, R2=%.4f, relRMSE=%.4f, measurements=%d, dof=%d\n",
+		res.Confidence, res.RSquared, res.RelativeRMSE, res.NumMeasurements, res.DegreesOfFreedom)
+	fmt.Println()
+	fmt.Printf("%5s %-10s %12s %12s %9s\n", "obs", "metric", "observed", "fitted", "rel")
+	for _, r := range res.Residuals {
+		fmt.Printf("%5d %-10s %12.4f %12.4f %9.4f\n", r.Observation, r.Metric, r.Observed, r.Fitted, r.Relative)
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// campaign is a scenarios file of the nous harness (nous/scenarios.json)
+type campaign struct {
+	SearchRange struct {
+		MMin int `json:"m_min"`
+		MMax int `json:"m_max"`
+	} `json:"search_range"`
+	Scenarios []scenario `json:"scenarios"`
+}
+
+type scenario struct {
+	Name            string  `json:"name"`
+	Regime          string  `json:"regime"`
+	AvgInputTokens  float32 `json:"AvgInputTokens"`
+	AvgOutputTokens float32 `json:"AvgOutputTokens"`
+	TargetITL       float32 `json:"targetITL"`
+	TargetTTFT      float32 `json:"targetTTFT"`
+	MaxQueueSize    int     `json:"maxQueueSize"`
+	Alpha           float32 `json:"alpha"`
+	Beta            float32 `json:"beta"`
+	Gamma           float32 `json:"gamma"`
+}
+
+// truth is a truth cache of nous/harness/baseline_truth.py
+type truth struct {
+	Scenario        string       `json:"scenario"`
+	Regime          string       `json:"regime"`
+	MTruth          int          `json:"M_truth"`
+	ThroughputTruth float32      `json:"throughput_truth"`
+	FCurve          []curvePoint `json:"f_curve"`
+}
+
+type curvePoint struct {
+	M          int     `json:"m"`
+	Throughput float32 `json:"throughput"`
+}
+
+// result is a ScenarioResult record of nous/harness/run.py
+type result struct {
+	Scenario           string  `json:"scenario"`
+	Strategy           string  `json:"strategy"`
+	MChosen            int     `json:"M_chosen"`
+	Calls              int     `json:"calls"`
+	ThroughputChosen   float32 `json:"throughput_chosen"`
+	MTruth             int     `json:"M_truth"`
+	ThroughputTruth    float32 `json:"throughput_truth"`
+	GapThroughputRel   float32 `json:"gap_throughput_rel"`
+	GapM               int     `json:"gap_M"`
+	WallClockSeconds   float64 `json:"wall_clock_seconds"`
+	InternalSolveCalls int     `json:"internal_solve_calls"` // not reported, 0 as in run.py
+}
+
+// compute the exhaustive throughput curves f(M) of the scenarios of a nous campaign, writing
+// the truth caches of nous/harness/baseline_truth.py (with -truth), and score concurrency search
+// strategies against them on (calls, gap_throughput_rel), writing the records of
+// nous/harness/run.py, all in process, e.g.
+//
+//	go run ./cmd/harness -scenarios nous/scenarios.json -strategy formula_guided,naive_ternary -out nous/results/go.json
+//	go run ./cmd/harness -scenarios nous/scenarios.json -truth -cache-dir /tmp/cache
+//	go run ./cmd/harness -scenarios nous/scenarios_benchmark.json -truth -cache-dir /tmp/cache -out-subdir bench
+//
+// where -strategy all scores every registered strategy. The truth caches under nous/cache are the
+// fixtures of the parity tests, and are only overwritten by -truth with the default -cache-dir.
+func main() {
+	scenariosFile := flag.String("scenarios", filepath.Join("nous", "scenarios.json"), "json scenarios file")
+	cacheDir := flag.String("cache-dir", filepath.Join("nous", "cache"), "directory of the truth caches")
+	outSubdir := flag.String("out-subdir", "", "if set, write truth caches as <cache-dir>/<subdir>/<name>.json")
+	writeTruth := flag.Bool("truth", false, "write the truth caches")
+	mMin := flag.Int("m-min", 0, "smallest concurrency cap (overrides the file)")
+	mMax := flag.Int("m-max", 0, "largest concurrency cap (overrides the file)")
+	strategies := flag.String("strategy", "", "comma-separated strategies to score, or all (default none)")
+	outFile := flag.String("out", "", "json file of the strategy records (default none)")
+	flag.Parse()
+
+	config, err := loadCampaign(*scenariosFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load failed: %v\n", err)
+		os.Exit(1)
+	}
+	if *mMin > 0 {
+		config.SearchRange.MMin = *mMin
+	}
+	if *mMax > 0 {
+		config.SearchRange.MMax = *mMax
+	}
+	var names []string
+	switch *strategies {
+	case "":
+	case "all":
+		names = analyzer.SearchStrategyNames()
+	default:
+		names = strings.Split(*strategies, ",")
+		for _, name := range names {
+			if _, err := analyzer.SearchStrategyByName(name); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	truthDir := *cacheDir
+	if *outSubdir != "" {
+		truthDir = filepath.Join(*cacheDir, *outSubdir)
+	}
+	if *writeTruth {
+		if err := os.MkdirAll(truthDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "mkdir failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	optimizers := make([]*analyzer.ConcurrencyOptimizer, len(config.Scenarios))
+	curves := make([]*analyzer.ConcurrencyCurve, len(config.Scenarios))
+	for i, s := range config.Scenarios {
+		optimizers[i] = s.optimizer(config.SearchRange.MMin, config.SearchRange.MMax)
+		if curves[i], err = optimizers[i].Curve(); err != nil {
+			fmt.Fprintf(os.Stderr, "[%s] Curve failed: %v\n", s.Name, err)
+			os.Exit(1)
+		}
+		warn := ""
+		if curves[i].ThroughputTruth <= 0 {
+			warn = "  WARNING: f(M)=0 for all M, fully infeasible"
+		}
+		fmt.Printf("[%s] M*=%d f*=%.4f%s\n", s.Name, curves[i].MTruth, curves[i].ThroughputTruth, warn)
+		if !*writeTruth {
+			continue
+		}
+		fileName := filepath.Join(truthDir, "truth-"+s.Name+".json")
+		if *outSubdir != "" {
+			fileName = filepath.Join(truthDir, s.Name+".json")
+		}
+		if err := writeJSON(fileName, s.truth(curves[i])); err != nil {
+			fmt.Fprintf(os.Stderr, "write failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	records := make([]*result, 0, len(names)*len(config.Scenarios))
+	for _, name := range names {
+		for i, s := range config.Scenarios {
+			opt := *optimizers[i]
+			opt.Strategy = name
+			start := time.Now()
+			res, err := opt.Find()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[%s] %s failed: %v\n", s.Name, name, err)
+				os.Exit(1)
+			}
+			elapsed := time.Since(start).Seconds()
+			score := curves[i].Score(res)
+			records = append(records, &result{
+				Scenario:         s.Name,
+				Strategy:         name,
+				MChosen:          score.Concurrency,
+				Calls:            score.Calls,
+				ThroughputChosen: score.Throughput,
+				MTruth:           curves[i].MTruth,
+				ThroughputTruth:  curves[i].ThroughputTruth,
+				GapThroughputRel: score.GapThroughputRel,
+				GapM:             score.GapM,
+				WallClockSeconds: elapsed,
+			})
+			fmt.Printf("[%s] %s M=%d calls=%d gap_rel=%.4f\n", s.Name, name, score.Concurrency, score.Calls, score.GapThroughputRel)
+		}
+	}
+	if *outFile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(*outFile), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "mkdir failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeJSON(*outFile, records); err != nil {
+		fmt.Fprintf(os.Stderr, "write failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("wrote %d records to %s\n", len(records), *outFile)
+}
+
+// read a scenarios file, rejecting unknown fields as nous/harness/scenarios.py does
+func loadCampaign(fileName string) (*campaign, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	config := &campaign{}
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	for i, s := range config.Scenarios {
+		if s.Name == "" || slices.ContainsFunc(config.Scenarios[:i], func(t scenario) bool { return t.Name == s.Name }) {
+			return nil, fmt.Errorf("%s: scenario %d: missing or duplicate name %q", fileName, i, s.Name)
+		}
+	}
+	return config, nil
+}
+
+// the optimizer of a scenario over [mMin, mMax], with the analyzer as oracle (the /target
+// endpoint of the Python harness)
+func (s *scenario) optimizer(mMin, mMax int) *analyzer.ConcurrencyOptimizer {
+	return &analyzer.ConcurrencyOptimizer{
+		ServiceParms: &analyzer.ServiceParms{Alpha: s.Alpha, Beta: s.Beta, Gamma: s.Gamma},
+		RequestSize:  &analyzer.RequestSize{AvgInputTokens: s.AvgInputTokens, AvgOutputTokens: s.AvgOutputTokens},
+		Target:       &analyzer.TargetPerf{TargetTTFT: s.TargetTTFT, TargetITL: s.TargetITL},
+		MaxQueueSize: s.MaxQueueSize,
+		MMin:         mMin,
+		MMax:         mMax,
+	}
+}
+
+func (s *scenario) truth(c *analyzer.ConcurrencyCurve) *truth {
+	t := &truth{
+		Scenario:        s.Name,
+		Regime:          s.Regime,
+		MTruth:          c.MTruth,
+		ThroughputTruth: c.ThroughputTruth,
+		FCurve:          make([]curvePoint, len(c.Throughput)),
+	}
+	for i, thr := range c.Throughput {
+		t.FCurve[i] = curvePoint{M: c.MMin + i, Throughput: thr}
+	}
+	return t
+}
+
+func writeJSON(fileName string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, append(b, '\n'), 0o644)
+}
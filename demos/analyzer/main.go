@@ -27,7 +27,8 @@ func main() {
 	// target values
 	targetTTFT := float32(140)
 	targetITL := float32(16.5)
-	targetTPS := float32(8 * 1024)
+	targetTPS := float32(512)
+	targetUserTPS := float32(50)
 
 	// create queue analyzer
 	config := &analyzer.Configuration{
@@ -46,9 +47,10 @@ func main() {
 	}
 
 	targetPerf := &analyzer.TargetPerf{
-		TargetTTFT: targetTTFT,
-		TargetITL:  targetITL,
-		TargetTPS:  targetTPS,
+		TargetTTFT:    targetTTFT,
+		TargetITL:     targetITL,
+		TargetTPS:     targetTPS,
+		TargetUserTPS: targetUserTPS,
 	}
 
 	fmt.Println()
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+	"github.com/llm-inferno/queue-analysis/pkg/sim"
+)
+
+func main() {
+
+	// queue configuration
+	maxBatchSize := 64
+	maxQueueSize := 128
+
+	// prefill and decode parameters
+	alpha := float32(8)
+	beta := float32(0.033)
+	gamma := float32(0.000333)
+
+	// request size
+	avgInputTokens := float32(256)
+	avgOutputTokens := float32(1024)
+
+	// simulation
+	numRequests := 20000
+	seed := uint64(1)
+
+	config := &analyzer.Configuration{
+		MaxBatchSize: maxBatchSize,
+		MaxQueueSize: maxQueueSize,
+		ServiceParms: &analyzer.ServiceParms{
+			Alpha: alpha,
+			Beta:  beta,
+			Gamma: gamma,
+		},
+	}
+
+	requestSize := &analyzer.RequestSize{
+		AvgInputTokens:  avgInputTokens,
+		AvgOutputTokens: avgOutputTokens,
+	}
+
+	fmt.Println()
+	fmt.Printf("configuration=%v\n", config)
+	fmt.Printf("requestSize=%v\n", requestSize)
+	fmt.Println()
+
+	queueAnalyzer, err := analyzer.NewLLMQueueAnalyzer(config, requestSize)
+	if err != nil {
+		fmt.Printf("NewLLMQueueAnalyzer() failed: %v\n", err)
+		return
+	}
+
+	// analytic and simulated metrics side by side, over fractions of the maximum rate
+	fmt.Println("rate \t tput(model) \t tput(sim) \t batch(model) \t batch(sim) \t ITL(model) \t ITL(sim) \t TTFT(model) \t TTFT(sim)")
+	for _, fraction := range []float32{0.2, 0.4, 0.6, 0.8, 0.9, 0.95} {
+		requestRate := fraction * queueAnalyzer.RateRange.Max
+		metrics, err := queueAnalyzer.Analyze(requestRate)
+		if err != nil {
+			fmt.Printf("Analyze() %v\n", err)
+			return
+		}
+		arrivals, err := sim.NewPoissonArrivals(requestRate, requestSize, seed)
+		if err != nil {
+			fmt.Printf("NewPoissonArrivals() failed: %v\n", err)
+			return
+		}
+		result, err := sim.Simulate(&sim.Config{Configuration: config, NumRequests: numRequests}, arrivals)
+		if err != nil {
+			fmt.Printf("Simulate() %v\n", err)
+			return
+		}
+		fmt.Printf("%5.2f \t %6.3f \t %s \t %6.2f \t %6.2f \t %6.2f \t %s \t %7.2f \t %s\n",
+			requestRate, metrics.Throughput, result.Throughput, metrics.AvgNumInServ, result.Metrics.AvgNumInServ,
+			metrics.AvgTokenTime, result.AvgTokenTime, metrics.AvgTTFT, result.AvgTTFT)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,67 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+func newAbandonmentAnalyzer(t *testing.T, a *Abandonment) *LLMQueueAnalyzer {
+	t.Helper()
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, Abandonment: a}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	return qa
+}
+
+func TestAbandonmentUnderOverload(t *testing.T) {
+	for _, patience := range []queue.PatienceKind{queue.ExponentialPatience, queue.DeterministicPatience} {
+		qa := newAbandonmentAnalyzer(t, &Abandonment{Patience: patience, PatienceTime: 2000})
+		rate := 1.2 * qa.RateRange.Max
+		m, err := qa.Analyze(rate)
+		if err != nil {
+			t.Fatalf("%s: Analyze: %v", patience, err)
+		}
+		if m.AbandonRate <= 0 || m.AbandonFraction <= 0 || m.AbandonFraction >= 1 {
+			t.Errorf("%s: overload should cause abandonment: %s", patience, m)
+		}
+		if m.Goodput > m.Throughput || m.RetryRate != 0 {
+			t.Errorf("%s: goodput %v above throughput %v, retries %v", patience, m.Goodput, m.Throughput, m.RetryRate)
+		}
+		if m.AvgWaitTime > 2000 {
+			t.Errorf("%s: mean wait %v beyond the patience", patience, m.AvgWaitTime)
+		}
+
+		// retried requests add to the load
+		qa.Abandonment.RetryProb = 0.5
+		retried, err := qa.Analyze(rate)
+		if err != nil {
+			t.Fatalf("%s: Analyze with retries: %v", patience, err)
+		}
+		if math.Abs(float64(retried.RetryRate-0.5*retried.AbandonRate)) > 1e-3*float64(retried.AbandonRate) {
+			t.Errorf("%s: retry rate %v, want half the abandon rate %v", patience, retried.RetryRate, retried.AbandonRate)
+		}
+		if retried.AbandonRate <= m.AbandonRate {
+			t.Errorf("%s: retries should raise abandonment: %v <= %v", patience, retried.AbandonRate, m.AbandonRate)
+		}
+	}
+}
+
+func TestSizeBoundsAbandonFraction(t *testing.T) {
+	qa := newAbandonmentAnalyzer(t, &Abandonment{Patience: queue.ExponentialPatience, PatienceTime: 5000})
+	target := &TargetPerf{MaxAbandonFraction: 0.01}
+	targetRate, metrics, achieved, err := qa.Size(target)
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if math.Abs(float64(achieved.MaxAbandonFraction-0.01)) > 1e-4 {
+		t.Errorf("achieved abandonment fraction %v, want 0.01", achieved.MaxAbandonFraction)
+	}
+	if targetRate.RateTargetAbandon != metrics.OfferedRate {
+		t.Errorf("rate %v, want binding abandonment rate %v", metrics.OfferedRate, targetRate.RateTargetAbandon)
+	}
+}
@@ -0,0 +1,103 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+// Markov-modulated Poisson arrival process (MMPP): while in phase j, requests arrive at
+// rate Rates[j]; the phase switches from i to j != i at rate SwitchRates[i][j].
+// For example, a 2-state MMPP alternating between bursts and quiet periods:
+//
+//	&ArrivalProcess{Rates: []float32{8, 1}, SwitchRates: [][]float32{{0, 0.1}, {0.05, 0}}}
+type ArrivalProcess struct {
+	Rates       []float32   // arrival rate in each phase (requests/sec)
+	SwitchRates [][]float32 // phase switching rates (1/sec), diagonal ignored
+}
+
+// evaluate performance metrics given an arrival process
+func (qa *LLMQueueAnalyzer) AnalyzeArrivals(arrivals *ArrivalProcess) (metrics *AnalysisMetrics, err error) {
+	aqa, err := qa.withArrivals(arrivals)
+	if err != nil {
+		return nil, err
+	}
+	return aqa.Analyze(arrivals.MeanRate())
+}
+
+// evaluate max request rates to achieve a given target performance, given the shape of an arrival process.
+// Rates are mean rates of the arrival process, with phase rates scaled proportionally and switching rates kept.
+func (qa *LLMQueueAnalyzer) SizeArrivals(arrivals *ArrivalProcess, targetPerf *TargetPerf) (targetRate *TargetRate,
+	metrics *AnalysisMetrics, achieved *TargetPerf, err error) {
+	aqa, err := qa.withArrivals(arrivals)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return aqa.Size(targetPerf)
+}
+
+// copy of the analyzer whose queueing model is fed by the given arrival process
+func (qa *LLMQueueAnalyzer) withArrivals(arrivals *ArrivalProcess) (*LLMQueueAnalyzer, error) {
+	if err := arrivals.check(); err != nil {
+		return nil, err
+	}
+	if qa.Abandonment != nil {
+		return nil, fmt.Errorf("abandonment is not supported with arrival process %s", arrivals)
+	}
+	if qa.GeneralService {
+		return nil, fmt.Errorf("general service is not supported with arrival process %s", arrivals)
+	}
+	model, err := queue.NewMMPPModelStateDependent(qa.Model.K, qa.Model.GetServRate(), arrivals.toMMPP())
+	if err != nil {
+		return nil, err
+	}
+	aqa := *qa
+	aqa.Model = model.MM1ModelStateDependent
+	return &aqa, nil
+}
+
+// mean arrival rate (requests/sec)
+func (ap *ArrivalProcess) MeanRate() float32 {
+	return ap.toMMPP().MeanRate() * 1000
+}
+
+// MMPP with rates per msec, as used by the queueing model
+func (ap *ArrivalProcess) toMMPP() *queue.MMPP {
+	mmpp := &queue.MMPP{
+		Rates:  make([]float32, len(ap.Rates)),
+		Switch: make([][]float32, len(ap.SwitchRates)),
+	}
+	for i, r := range ap.Rates {
+		mmpp.Rates[i] = r / 1000
+	}
+	for i, row := range ap.SwitchRates {
+		mmpp.Switch[i] = make([]float32, len(row))
+		for j, s := range row {
+			mmpp.Switch[i][j] = s / 1000
+		}
+	}
+	return mmpp
+}
+
+// check validity of arrival process
+func (ap *ArrivalProcess) check() error {
+	if ap == nil || len(ap.Rates) == 0 || len(ap.SwitchRates) != len(ap.Rates) {
+		return fmt.Errorf("invalid arrival process %s", ap)
+	}
+	for i, r := range ap.Rates {
+		if r < 0 || len(ap.SwitchRates[i]) != len(ap.Rates) {
+			return fmt.Errorf("invalid arrival process %s", ap)
+		}
+	}
+	if ap.MeanRate() <= 0 {
+		return fmt.Errorf("invalid arrival process mean rate %s", ap)
+	}
+	return nil
+}
+
+func (ap *ArrivalProcess) String() string {
+	if ap == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{rates=%v, switchRates=%v}", ap.Rates, ap.SwitchRates)
+}
@@ -0,0 +1,55 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalyzeArrivalsPoissonLimit(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	rate := 0.8 * qa.RateRange.Max
+	poisson, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	flat := &ArrivalProcess{
+		Rates:       []float32{rate, rate},
+		SwitchRates: [][]float32{{0, 1}, {1, 0}},
+	}
+	mmpp, err := qa.AnalyzeArrivals(flat)
+	if err != nil {
+		t.Fatalf("AnalyzeArrivals: %v", err)
+	}
+	if math.Abs(float64(mmpp.AvgTTFT-poisson.AvgTTFT)) > 1e-2*float64(poisson.AvgTTFT) {
+		t.Errorf("TTFT: got %v, want %v", mmpp.AvgTTFT, poisson.AvgTTFT)
+	}
+}
+
+func TestSizeArrivalsBursty(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	target := &TargetPerf{TargetTTFT: 200}
+	poissonRate, _, _, err := qa.Size(target)
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	bursty := &ArrivalProcess{
+		Rates:       []float32{3, 0.5},
+		SwitchRates: [][]float32{{0, 0.02}, {0.02, 0}},
+	}
+	burstyRate, metrics, _, err := qa.SizeArrivals(bursty, target)
+	if err != nil {
+		t.Fatalf("SizeArrivals: %v", err)
+	}
+	if burstyRate.RateTargetTTFT >= poissonRate.RateTargetTTFT {
+		t.Errorf("bursty max rate %v not below Poisson max rate %v",
+			burstyRate.RateTargetTTFT, poissonRate.RateTargetTTFT)
+	}
+	if math.Abs(float64(metrics.AvgTTFT-200)) > 1 {
+		t.Errorf("achieved TTFT: got %v, want 200", metrics.AvgTTFT)
+	}
+
+	// the analyzer itself keeps its Poisson model
+	if again, _, _, _ := qa.Size(target); again.RateTargetTTFT != poissonRate.RateTargetTTFT {
+		t.Errorf("Poisson sizing changed after SizeArrivals: %v != %v", again.RateTargetTTFT, poissonRate.RateTargetTTFT)
+	}
+}
@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+)
+
+// capacity plan: replicas, each with its own queue, sharing the request rate evenly
+type CapacityPlan struct {
+	NumReplicas       int              // min number of replicas meeting the targets
+	MaxBatchSize      int              // concurrency (max batch size) per replica
+	RatePerReplica    float32          // load per replica (requests/sec)
+	MaxRatePerReplica float32          // max rate per replica meeting the targets (requests/sec)
+	Headroom          float32          // fraction of the capacity of the replicas left unused
+	Slack             *SLOSlack        // relative slack of the targets at the load per replica
+	Metrics           *AnalysisMetrics // metrics of a replica at the load per replica
+}
+
+// relative slack of the targets: (target - achieved) / target for latency targets, and
+// (achieved - target) / target for speed targets; positive within target, zero if not targeted
+type SLOSlack struct {
+	TTFT        float32 // average time to first token
+	ITL         float32 // average inter-token latency
+	UserTPS     float32 // per-user decode speed
+	PctTTFT     float32 // percentile time to first token
+	PctRespTime float32 // percentile response time
+}
+
+// Plan the capacity to serve a request rate within the targets: the concurrency per replica is the
+// optimal concurrency under the targets (OptimalConcurrency, with the analyzer's MaxBatchSize as upper
+// bound), the max rate per replica is the sized rate at that concurrency (Size), and the number of
+// replicas is the fewest whose combined max rate covers the request rate, with the targets of a replica
+// (TargetPerf.PerReplica). As the concurrency search sizes plain replicas, the analyzer must model a
+// single replica, without abandonment, general service, KV cache bound, or exact metrics.
+func (qa *LLMQueueAnalyzer) PlanCapacity(requestRate float32, targetPerf *TargetPerf) (plan *CapacityPlan, err error) {
+	if requestRate <= 0 {
+		return nil, fmt.Errorf("invalid request rate %v", requestRate)
+	}
+	if qa.NumReplicas > 1 || qa.Abandonment != nil || qa.GeneralService || qa.kvCache != nil || qa.MetricsMode != MeanFieldMetrics {
+		return nil, fmt.Errorf("capacity planning not supported for analyzer %s", qa)
+	}
+	if err := targetPerf.check(); err != nil {
+		return nil, err
+	}
+	target := targetPerf.PerReplica()
+
+	res, err := qa.OptimalConcurrency(target)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Feasible || res.Metrics == nil {
+		return nil, fmt.Errorf("targets %s not achievable at any concurrency up to %d", targetPerf, qa.MaxBatchSize)
+	}
+	maxRate := res.Metrics.OfferedRate
+	numReplicas := int(math.Ceil(float64(requestRate / maxRate)))
+
+	// a replica at its share of the load
+	cfg := &Configuration{
+		MaxBatchSize: res.Concurrency,
+		MaxNumTokens: qa.MaxNumTokens,
+		MaxQueueSize: qa.MaxQueueSize,
+		ServiceParms: qa.ServiceParms,
+	}
+	replica, err := NewLLMQueueAnalyzer(cfg, qa.RequestSize)
+	if err != nil {
+		return nil, err
+	}
+	replica.Percentile = qa.Percentile
+	percentile := target.Percentile
+	if percentile == 0 {
+		percentile = qa.Percentile
+	}
+	ratePerReplica := requestRate / float32(numReplicas)
+	metrics, err := replica.analyze(ratePerReplica, percentile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CapacityPlan{
+		NumReplicas:       numReplicas,
+		MaxBatchSize:      res.Concurrency,
+		RatePerReplica:    ratePerReplica,
+		MaxRatePerReplica: maxRate,
+		Headroom:          1 - ratePerReplica/maxRate,
+		Slack:             newSLOSlack(target, metrics),
+		Metrics:           metrics,
+	}, nil
+}
+
+// relative slack of the targets given the metrics
+func newSLOSlack(target *TargetPerf, metrics *AnalysisMetrics) *SLOSlack {
+	slack := func(target, achieved float32) float32 {
+		if target <= 0 {
+			return 0
+		}
+		return (target - achieved) / target
+	}
+	slo := &SLOSlack{
+		TTFT:        slack(target.TargetTTFT, metrics.AvgTTFT),
+		ITL:         slack(target.TargetITL, metrics.AvgTokenTime),
+		PctTTFT:     slack(target.TargetPctTTFT, metrics.PctTTFT),
+		PctRespTime: slack(target.TargetPctRespTime, metrics.PctRespTime),
+	}
+	if target.TargetUserTPS > 0 && metrics.AvgTokenTime > 0 {
+		slo.UserTPS = -slack(target.TargetUserTPS, 1000/metrics.AvgTokenTime)
+	}
+	return slo
+}
+
+func (cp *CapacityPlan) String() string {
+	return fmt.Sprintf("{replicas=%d, maxBatch=%d, ratePerReplica=%.3f, maxRatePerReplica=%.3f, headroom=%.3f, slack=%s, metrics=%s}",
+		cp.NumReplicas, cp.MaxBatchSize, cp.RatePerReplica, cp.MaxRatePerReplica, cp.Headroom, cp.Slack, cp.Metrics)
+}
+
+func (s *SLOSlack) String() string {
+	return fmt.Sprintf("{ttft=%.3f, itl=%.3f, userTPS=%.3f, pctTTFT=%.3f, pctRespTime=%.3f}",
+		s.TTFT, s.ITL, s.UserTPS, s.PctTTFT, s.PctRespTime)
+}
@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPlanCapacityBaseline(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 256, 128)
+	target := &TargetPerf{TargetTTFT: 60, TargetITL: 20}
+	plan, err := qa.PlanCapacity(40, target)
+	if err != nil {
+		t.Fatalf("PlanCapacity: %v", err)
+	}
+	res, err := qa.OptimalConcurrency(target)
+	if err != nil {
+		t.Fatalf("OptimalConcurrency: %v", err)
+	}
+	if plan.MaxBatchSize != res.Concurrency {
+		t.Errorf("concurrency: got %d, want %d", plan.MaxBatchSize, res.Concurrency)
+	}
+
+	// fewest replicas: one less would exceed the max rate per replica
+	if want := int(math.Ceil(40 / float64(plan.MaxRatePerReplica))); plan.NumReplicas != want || plan.NumReplicas < 2 {
+		t.Errorf("replicas: got %d, want %d (>= 2)", plan.NumReplicas, want)
+	}
+	if got := plan.RatePerReplica * float32(plan.NumReplicas); math.Abs(float64(got-40)) > 1e-3 {
+		t.Errorf("load split: %v per replica over %d replicas", plan.RatePerReplica, plan.NumReplicas)
+	}
+	if plan.Headroom < 0 || plan.Headroom >= 1 {
+		t.Errorf("headroom %v not in [0, 1)", plan.Headroom)
+	}
+
+	// at its share of the load, a replica meets the targets
+	if plan.Slack.TTFT < -1e-3 || plan.Slack.ITL < -1e-3 || plan.Slack.PctTTFT != 0 {
+		t.Errorf("slack: %s", plan.Slack)
+	}
+	if plan.Metrics.AvgTTFT > target.TargetTTFT*1.001 || plan.Metrics.AvgTokenTime > target.TargetITL*1.001 {
+		t.Errorf("metrics %s violate targets %s", plan.Metrics, target)
+	}
+}
+
+func TestPlanCapacityInfeasible(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 128)
+	// ITL below the unloaded decode time
+	if _, err := qa.PlanCapacity(10, &TargetPerf{TargetITL: 1}); err == nil {
+		t.Errorf("expected error for an unachievable target")
+	}
+	if _, err := qa.PlanCapacity(0, &TargetPerf{TargetITL: 20}); err == nil {
+		t.Errorf("expected error for a zero rate")
+	}
+}
+
+func TestPlanCapacityRejectsUnsupportedOptions(t *testing.T) {
+	sp, rs := baselineParts()
+	for _, cfg := range []*Configuration{
+		{NumReplicas: 2},
+		{Abandonment: &Abandonment{PatienceTime: 1000}},
+		{GeneralService: true, ServiceSCV: 2},
+		{KVCacheBlocks: 1600},
+	} {
+		cfg.MaxBatchSize, cfg.MaxQueueSize, cfg.ServiceParms = 64, 128, sp
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		if _, err := qa.PlanCapacity(10, &TargetPerf{TargetITL: 20}); err == nil {
+			t.Errorf("expected error for configuration %s", cfg)
+		}
+	}
+	qa := newBaselineAnalyzer(t, 64, 128)
+	qa.MetricsMode = ExactMetrics
+	if _, err := qa.PlanCapacity(10, &TargetPerf{TargetITL: 20}); err == nil {
+		t.Errorf("expected error for exact metrics")
+	}
+}
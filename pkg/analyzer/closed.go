@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+// evaluate performance metrics of a closed population of users, each issuing a request,
+// waiting for its response, and thinking for an average thinkTime (msec) before the next request.
+// The server (or pool) keeps its state-dependent service rates; requests beyond the batch
+// limits wait in the queue, which is assumed to hold all users (no blocking).
+// OfferedRate and Throughput are the resulting request rate; percentile metrics are not reported.
+func (qa *LLMQueueAnalyzer) AnalyzeClosed(numUsers int, thinkTime float32) (metrics *AnalysisMetrics, err error) {
+	if numUsers <= 0 || thinkTime < 0 {
+		return nil, fmt.Errorf("invalid closed population: numUsers=%d, thinkTime=%v", numUsers, thinkTime)
+	}
+	model := queue.NewClosedModelStateDependent(qa.Model.GetServRate())
+	if err = model.Solve(numUsers, thinkTime); err != nil {
+		return nil, err
+	}
+
+	// mean-field at the in-service mean batch size (per replica)
+	avgNumInServ := model.GetAvgNumInServers()
+	batchSize := meanFieldBatchSize(avgNumInServ, qa.NumReplicas, qa.MaxBatchSize, qa.Dispatch)
+	avgPrefillTime := qa.evalFuncData().prefillTime(batchSize)
+	avgDecodeTime := (model.GetAvgServTime() - avgPrefillTime) / qa.RequestSize.AvgOutputTokens
+
+	rho := avgNumInServ / float32(qa.NumReplicas*qa.MaxBatchSize)
+	rho = min(max(rho, 0), 1)
+
+	throughput := model.GetThroughput() * 1000
+	metrics = &AnalysisMetrics{
+		OfferedRate:    throughput,
+		Throughput:     throughput,
+		AvgRespTime:    model.GetAvgRespTime(),
+		AvgWaitTime:    model.GetAvgWaitTime(),
+		AvgNumInServ:   avgNumInServ,
+		AvgPrefillTime: avgPrefillTime,
+		AvgTokenTime:   avgDecodeTime,
+		AvgTTFT:        model.GetAvgWaitTime() + avgPrefillTime + avgDecodeTime,
+		MaxRate:        qa.RateRange.Max,
+		Rho:            rho,
+	}
+	return metrics, nil
+}
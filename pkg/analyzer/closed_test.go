@@ -0,0 +1,35 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalyzeClosedApproachesCapacity(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+
+	light, err := qa.AnalyzeClosed(4, 5000)
+	if err != nil {
+		t.Fatalf("AnalyzeClosed: %v", err)
+	}
+	heavy, err := qa.AnalyzeClosed(400, 5000)
+	if err != nil {
+		t.Fatalf("AnalyzeClosed: %v", err)
+	}
+	if light.Throughput >= heavy.Throughput || light.AvgTTFT >= heavy.AvgTTFT {
+		t.Errorf("more users should raise throughput and TTFT: light %s, heavy %s", light, heavy)
+	}
+	if heavy.Throughput > qa.RateRange.Max/(1-Epsilon)*1.001 {
+		t.Errorf("throughput %v above capacity %v", heavy.Throughput, qa.RateRange.Max)
+	}
+
+	// interactive response time law: N = X * (Z + R)
+	users := heavy.Throughput / 1000 * (5000 + heavy.AvgRespTime)
+	if math.Abs(float64(users-400)) > 0.5 {
+		t.Errorf("users in cycle: got %v, want 400", users)
+	}
+
+	if _, err := qa.AnalyzeClosed(0, 5000); err == nil {
+		t.Errorf("expected error for empty population")
+	}
+}
@@ -10,13 +10,21 @@ const (
 	DefaultMMax          = 256
 )
 
+// Noisy onset-search defaults.
+const (
+	DefaultNoisyProbeBudget     = 32
+	DefaultNoisyRepeats         = 3
+	DefaultNoisyConfidenceLevel = float32(0.9)
+)
+
 // ConcurrencyOracle returns the throughput f(M) sustainable at concurrency cap
 // M while meeting the SLO. feasible == false means the SLO is unreachable at M
 // (mirrors /target HTTP 400 -> throughput 0); such a reading is uncounted.
 type ConcurrencyOracle func(m int) (throughput float32, feasible bool)
 
 // ConcurrencyOptimizer finds the minimum concurrency M* achieving near-peak
-// throughput under the SLO, via the formula-guided onset search.
+// throughput under the SLO, via the formula-guided onset search or another
+// registered SearchStrategy.
 type ConcurrencyOptimizer struct {
 	ServiceParms *ServiceParms
 	RequestSize  *RequestSize
@@ -28,21 +36,33 @@ type ConcurrencyOptimizer struct {
 	Epsilon      float32           // default DefaultOnsetEpsilon
 	MaxIters     int               // default DefaultOnsetMaxIters
 	Oracle       ConcurrencyOracle // nil => Size()-based default oracle
+	Strategy     string            // registered SearchStrategy name; default FormulaGuidedStrategy
+
+	// Noisy search, for measured oracles (live benchmark, simulation): repeated
+	// probes and an isotonic fit over all readings decide the bisection
+	// direction. MaxIters does not apply; ProbeBudget bounds all probes.
+	// Formula-guided strategy only.
+	Noisy           bool
+	ProbeBudget     int     // max probes, incl. anchor and confirmatory; default DefaultNoisyProbeBudget
+	Repeats         int     // max readings per cap; default DefaultNoisyRepeats
+	ConfidenceLevel float32 // a cap is probed again while the threshold is within this confidence interval of its fit; default DefaultNoisyConfidenceLevel
 
 	oracleIsDefault bool // set in applyDefaults; gates Metrics population
 }
 
 // ConcurrencyResult is the outcome of a search.
 type ConcurrencyResult struct {
+	Strategy         string           // search strategy name
 	Concurrency      int              // M*: min concurrency for near-peak throughput under SLO
 	Throughput       float32          // f(M*), confirmatory (from the oracle)
 	Metrics          *AnalysisMetrics // full metrics at M* (default-oracle path only; else nil)
 	MITL             int              // closed-form ITL-binding bracket
 	MTPF             int              // closed-form TTFT-prefill-binding bracket
-	AnchorThroughput float32          // f* probed at m_max
+	AnchorThroughput float32          // f* probed at m_max (0 if not probed)
 	Calls            int              // feasible oracle calls (incl. confirmatory)
 	Probes           []int            // probe sequence (diagnostics)
 	Feasible         bool
+	Confidence       float32 // noisy search only: estimated probability that M* is the onset
 }
 
 func (o *ConcurrencyOptimizer) applyDefaults() {
@@ -61,6 +81,15 @@ func (o *ConcurrencyOptimizer) applyDefaults() {
 	if o.MaxIters <= 0 {
 		o.MaxIters = DefaultOnsetMaxIters
 	}
+	if o.ProbeBudget <= 0 {
+		o.ProbeBudget = DefaultNoisyProbeBudget
+	}
+	if o.Repeats <= 0 {
+		o.Repeats = DefaultNoisyRepeats
+	}
+	if o.ConfidenceLevel <= 0 {
+		o.ConfidenceLevel = DefaultNoisyConfidenceLevel
+	}
 	if o.Oracle == nil {
 		o.Oracle = o.defaultOracle
 		o.oracleIsDefault = true
@@ -103,6 +132,10 @@ func (o *ConcurrencyOptimizer) check() error {
 	if o.ServiceParms == nil || o.RequestSize == nil || o.Target == nil {
 		return fmt.Errorf("optimizer requires ServiceParms, RequestSize, and Target")
 	}
+	if o.ProbeBudget < 0 || o.Repeats < 0 || o.ConfidenceLevel < 0 || o.ConfidenceLevel >= 1 {
+		return fmt.Errorf("invalid noisy search parameters: budget=%d, repeats=%d, confidence=%v",
+			o.ProbeBudget, o.Repeats, o.ConfidenceLevel)
+	}
 	if err := o.RequestSize.check(); err != nil {
 		return err
 	}
@@ -126,81 +159,150 @@ func (o *ConcurrencyOptimizer) closedFormBrackets() (mITL, mTPF int) {
 	tpf := func(B int) float32 {
 		return prefillNew(o.ServiceParms, o.RequestSize, float32(B), numChunks[B])
 	}
-	mITL = largestFeasibleBatch(itl, o.Target.TargetITL, o.MMax)
+	// a per-user decode speed target is an ITL target
+	targetITL := o.Target.TargetITL
+	if userTPS := o.Target.TargetUserTPS; userTPS > 0 && (targetITL == 0 || 1000/userTPS < targetITL) {
+		targetITL = 1000 / userTPS
+	}
+	mITL = largestFeasibleBatch(itl, targetITL, o.MMax)
 	mTPF = largestFeasibleBatch(tpf, o.Target.TargetTTFT, o.MMax)
 	return mITL, mTPF
 }
 
-// Find runs the formula-guided onset search and returns M* with diagnostics.
+// Find runs the search strategy and returns M* with diagnostics.
 func (o *ConcurrencyOptimizer) Find() (*ConcurrencyResult, error) {
 	if err := o.check(); err != nil {
 		return nil, err
 	}
 	o.applyDefaults()
+	strategy, err := SearchStrategyByName(o.Strategy)
+	if err != nil {
+		return nil, err
+	}
+	if o.Noisy && strategy.Name() != FormulaGuidedStrategy {
+		return nil, fmt.Errorf("noisy search not supported by strategy %s", strategy.Name())
+	}
+	if usesRatio(strategy) && !o.oracleIsDefault {
+		return nil, fmt.Errorf("strategy %s requires the default oracle", strategy.Name())
+	}
 
-	res := &ConcurrencyResult{Probes: []int{}}
+	res := &ConcurrencyResult{Strategy: strategy.Name(), Probes: []int{}}
 	res.MITL, res.MTPF = o.closedFormBrackets()
 
 	// A counting wrapper: records every probe; counts a call only when the
 	// reading is feasible (throughput > 0), matching the Python harness.
-	probe := func(m int) float32 {
+	anchored := false
+	probe := func(m int) SearchReading {
 		res.Probes = append(res.Probes, m)
-		thr, _ := o.Oracle(m)
-		if thr > 0 {
+		r := o.read(m)
+		if r.Throughput > 0 {
 			res.Calls++
 		}
-		return thr
+		if m == o.MMax && !anchored {
+			res.AnchorThroughput, anchored = r.Throughput, true
+		}
+		return r
 	}
-
-	// High anchor: f* at m_max, always on the plateau for a monotone-to-plateau
-	// f. A constraint-endpoint seed can undershoot the peak (RP-2).
-	seed := o.MMax
-	fstar := probe(seed)
-	res.AnchorThroughput = fstar
-	if fstar <= 0 {
-		// Infeasible everywhere: truth convention sets M_truth = m_min.
-		res.Concurrency = o.MMin
-		res.Feasible = false
-		return res, nil
+	if o.Noisy {
+		return o.findNoisy(res, func(m int) float32 { return probe(m).Throughput })
 	}
 
-	threshold := (1 - o.Epsilon/2) * fstar
-	lo := max(o.MMin, min(res.MITL, res.MTPF))
-	U := max(res.MITL, res.MTPF)
-	hi := min(seed, max(3*U, lo+1), o.MMax)
-	hi = max(lo, hi)
-
-	hi = onsetSearch(probe, threshold, lo, hi, o.MaxIters)
-
-	mStar := max(o.MMin, min(o.MMax, hi))
+	mStar, feasible := strategy.Search(&SearchContext{
+		ServiceParms: o.ServiceParms,
+		RequestSize:  o.RequestSize,
+		Target:       o.Target,
+		MMin:         o.MMin,
+		MMax:         o.MMax,
+		Epsilon:      o.Epsilon,
+		MaxIters:     o.MaxIters,
+		MITL:         res.MITL,
+		MTPF:         res.MTPF,
+		probe:        probe,
+	})
+	if mStar < o.MMin || mStar > o.MMax {
+		return nil, fmt.Errorf("strategy %s returned M=%d outside [%d, %d]", strategy.Name(), mStar, o.MMin, o.MMax)
+	}
 	res.Concurrency = mStar
+	if !feasible {
+		return res, nil
+	}
 
 	// Confirmatory call through the oracle (counted if feasible).
-	res.Throughput = probe(mStar)
+	res.Throughput = probe(mStar).Throughput
 	res.Feasible = res.Throughput > 0
 	if res.Feasible && o.oracleIsDefault {
 		// Re-solve at M* for full metrics: the ConcurrencyOracle contract
 		// returns only throughput, so the default oracle's solve at mStar is
 		// not reused here. One extra deterministic solve; acceptable.
-		res.Metrics = o.sizeAt(mStar)
+		_, res.Metrics = o.sizeAt(mStar)
 	}
 	return res, nil
 }
 
+// formulaGuided is the formula-guided onset search: anchor f* at m_max,
+// always on the plateau for a monotone-to-plateau f, then bisection over the
+// closed-form bracket for the smallest M with f(M) >= (1-epsilon/2) f*.
+func formulaGuided(s *SearchContext) (int, bool) {
+	// High anchor: a constraint-endpoint seed can undershoot the peak (RP-2).
+	fstar := s.Throughput(s.MMax)
+	if fstar <= 0 {
+		// Infeasible everywhere: truth convention sets M_truth = m_min.
+		return s.MMin, false
+	}
+
+	threshold := (1 - s.Epsilon/2) * fstar
+	lo, hi := onsetBracket(s.MMin, s.MMax, s.MITL, s.MTPF)
+	hi = onsetSearch(s.Throughput, threshold, lo, hi, s.MaxIters)
+	return max(s.MMin, min(s.MMax, hi)), true
+}
+
+// onsetBracket narrows the search to [lo, hi] from the closed-form brackets:
+// lo at the tighter bracket, hi at 3x the looser one (capped at MMax).
+func onsetBracket(mMin, mMax, mITL, mTPF int) (lo, hi int) {
+	lo = max(mMin, min(mITL, mTPF))
+	U := max(mITL, mTPF)
+	hi = min(max(3*U, lo+1), mMax)
+	return lo, max(lo, hi)
+}
+
 // defaultOracle: f(M) = throughput from Size() at MaxBatchSize = M.
 func (o *ConcurrencyOptimizer) defaultOracle(m int) (float32, bool) {
-	metrics := o.sizeAt(m)
+	_, metrics := o.sizeAt(m)
 	if metrics == nil || metrics.Throughput <= 0 {
 		return 0, false
 	}
 	return metrics.Throughput, true
 }
 
+// read returns the reading at cap m: the oracle's throughput, and with the
+// default oracle also R(M) from the same solve (mirrors a /target response).
+func (o *ConcurrencyOptimizer) read(m int) SearchReading {
+	if !o.oracleIsDefault {
+		thr, _ := o.Oracle(m)
+		return SearchReading{Throughput: thr}
+	}
+	targetRate, metrics := o.sizeAt(m)
+	if metrics == nil || metrics.Throughput <= 0 {
+		return SearchReading{}
+	}
+	r := SearchReading{Throughput: metrics.Throughput}
+	if targetRate.RateTargetITL > 0 {
+		r.Ratio = targetRate.RateTargetTTFT / targetRate.RateTargetITL
+	}
+	return r
+}
+
 // OptimalConcurrency finds the minimum concurrency (max batch size) achieving
 // near-peak throughput under the given SLO targets. It uses the analyzer's own
 // service/request parameters and its MaxBatchSize as the upper bound m_max.
 func (qa *LLMQueueAnalyzer) OptimalConcurrency(target *TargetPerf) (*ConcurrencyResult, error) {
-	opt := &ConcurrencyOptimizer{
+	return qa.ConcurrencyOptimizer(target).Find()
+}
+
+// ConcurrencyOptimizer returns the optimizer used by OptimalConcurrency, to be
+// customized (e.g. its Strategy) before Find.
+func (qa *LLMQueueAnalyzer) ConcurrencyOptimizer(target *TargetPerf) *ConcurrencyOptimizer {
+	return &ConcurrencyOptimizer{
 		ServiceParms: qa.ServiceParms,
 		RequestSize:  qa.RequestSize,
 		Target:       target,
@@ -209,13 +311,12 @@ func (qa *LLMQueueAnalyzer) OptimalConcurrency(target *TargetPerf) (*Concurrency
 		MMin:         DefaultMMin,
 		MMax:         qa.MaxBatchSize,
 	}
-	return opt.Find()
 }
 
-// sizeAt builds an analyzer at MaxBatchSize = m and returns its SLO-bound
-// operating-point metrics, or nil if construction / sizing fails (mirrors
-// /target HTTP 400).
-func (o *ConcurrencyOptimizer) sizeAt(m int) *AnalysisMetrics {
+// sizeAt builds an analyzer at MaxBatchSize = m and returns its max rates per
+// target and SLO-bound operating-point metrics, or nils if construction /
+// sizing fails (mirrors /target HTTP 400).
+func (o *ConcurrencyOptimizer) sizeAt(m int) (*TargetRate, *AnalysisMetrics) {
 	cfg := &Configuration{
 		MaxBatchSize: m,
 		MaxNumTokens: o.MaxNumTokens,
@@ -224,11 +325,11 @@ func (o *ConcurrencyOptimizer) sizeAt(m int) *AnalysisMetrics {
 	}
 	qa, err := NewLLMQueueAnalyzer(cfg, o.RequestSize)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
-	_, metrics, _, err := qa.Size(o.Target)
+	targetRate, metrics, _, err := qa.Size(o.Target)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
-	return metrics
+	return targetRate, metrics
 }
@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -33,7 +34,8 @@ type fCurvePoint struct {
 }
 
 type truthCache struct {
-	FCurve []fCurvePoint `json:"f_curve"`
+	ThroughputTruth float32       `json:"throughput_truth"`
+	FCurve          []fCurvePoint `json:"f_curve"`
 }
 
 type goldenRecord struct {
@@ -146,3 +148,113 @@ func TestFormulaGuidedParity(t *testing.T) {
 		t.Errorf("checked %d formula_guided records, want 36", checked)
 	}
 }
+
+// TestStrategyParity reproduces every M_chosen and calls value of the naive
+// strategies (naive_ternary, naive_max) in paper/data/eval_results.json from
+// the Go search strategies.
+func TestStrategyParity(t *testing.T) {
+	var golden evalResults
+	if err := json.Unmarshal(readJSON(t, "paper", "data", "eval_results.json"), &golden); err != nil {
+		t.Fatalf("parse eval_results.json: %v", err)
+	}
+
+	// Load scenario params for both sets, keyed by name.
+	scen := map[string]parityScenario{} // name -> params (names are unique across sets)
+	for _, f := range []string{"scenarios.json", "scenarios_benchmark.json"} {
+		var sf parityScenarioFile
+		if err := json.Unmarshal(readJSON(t, "nous", f), &sf); err != nil {
+			t.Fatalf("parse %s: %v", f, err)
+		}
+		for _, s := range sf.Scenarios {
+			scen[s.Name] = s
+		}
+	}
+
+	// cache path differs by set.
+	cachePath := func(set, name string) []string {
+		if set == "benchmark" {
+			return []string{"nous", "cache", "bench", name + ".json"}
+		}
+		return []string{"nous", "cache", "truth-" + name + ".json"}
+	}
+
+	checked := map[string]int{}
+	for _, g := range golden.Records {
+		if g.Strategy == FormulaGuidedStrategy {
+			continue // TestFormulaGuidedParity
+		}
+		s, ok := scen[g.Scenario]
+		if !ok {
+			t.Fatalf("scenario %q not found in scenario files", g.Scenario)
+		}
+		var cache truthCache
+		if err := json.Unmarshal(readJSON(t, cachePath(g.Set, g.Scenario)...), &cache); err != nil {
+			t.Fatalf("parse cache for %s/%s: %v", g.Set, g.Scenario, err)
+		}
+
+		opt := optimizerFor(s, cacheOracle(&cache))
+		opt.Strategy = g.Strategy
+		res, err := opt.Find()
+		if err != nil {
+			t.Fatalf("Find %s %s/%s: %v", g.Strategy, g.Set, g.Scenario, err)
+		}
+		if res.Concurrency != g.MChosen {
+			t.Errorf("%s %s/%s: M_chosen got %d, want %d", g.Strategy, g.Set, g.Scenario, res.Concurrency, g.MChosen)
+		}
+		if res.Calls != g.Calls {
+			t.Errorf("%s %s/%s: calls got %d, want %d", g.Strategy, g.Set, g.Scenario, res.Calls, g.Calls)
+		}
+		// Go's Feasible is f(M*)>0 (oracle at the chosen point); the golden's is
+		// f_truth>0 (the scenario peak). These coincide for monotone-to-plateau f:
+		// peak>0 iff the chosen point >0, and infeasible-everywhere drives both to 0.
+		if res.Feasible != g.Feasible {
+			t.Errorf("%s %s/%s: feasible got %v, want %v", g.Strategy, g.Set, g.Scenario, res.Feasible, g.Feasible)
+		}
+		checked[g.Strategy]++
+	}
+	for _, name := range []string{NaiveTernaryStrategy, NaiveMaxStrategy} {
+		if checked[name] != 36 {
+			t.Errorf("checked %d %s records, want 36", checked[name], name)
+		}
+	}
+}
+
+// TestCurveParity reproduces the truth caches of nous/harness/baseline_truth.py
+// (both sets) from ConcurrencyCurve. The caches were generated through the
+// /target endpoint by an earlier build, whose rate solver differs in the last
+// float32 digits: feasibility must match at every M, throughput to a relative
+// 1e-3. M_truth is not compared, as such digits decide the argmax on a plateau,
+// but the peak throughput is.
+func TestCurveParity(t *testing.T) {
+	for _, set := range []struct {
+		file  string
+		cache func(name string) []string
+	}{
+		{"scenarios.json", func(name string) []string { return []string{"nous", "cache", "truth-" + name + ".json"} }},
+		{"scenarios_benchmark.json", func(name string) []string { return []string{"nous", "cache", "bench", name + ".json"} }},
+	} {
+		var sf parityScenarioFile
+		if err := json.Unmarshal(readJSON(t, "nous", set.file), &sf); err != nil {
+			t.Fatalf("parse %s: %v", set.file, err)
+		}
+		for _, s := range sf.Scenarios {
+			var cache truthCache
+			if err := json.Unmarshal(readJSON(t, set.cache(s.Name)...), &cache); err != nil {
+				t.Fatalf("parse cache for %s: %v", s.Name, err)
+			}
+			curve, err := optimizerFor(s, nil).Curve()
+			if err != nil {
+				t.Fatalf("Curve %s: %v", s.Name, err)
+			}
+			if len(curve.Throughput) != len(cache.FCurve) {
+				t.Fatalf("%s: %d points, want %d", s.Name, len(curve.Throughput), len(cache.FCurve))
+			}
+			for _, pt := range append(cache.FCurve, fCurvePoint{M: curve.MTruth, Throughput: cache.ThroughputTruth}) {
+				got := curve.At(pt.M)
+				if (got > 0) != (pt.Throughput > 0) || math.Abs(float64(got-pt.Throughput)) > 1e-3*float64(pt.Throughput) {
+					t.Errorf("%s: f(%d) got %v, want %v", s.Name, pt.M, got, pt.Throughput)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// configuration of a prefill/decode (P/D) disaggregated server: a prefill pool and a decode pool
+// in tandem, each with its own service parameters, batch limit, queue and replica count, and the
+// transfer of the KV cache from the prefill to the decode pool in between
+type DisaggregatedConfig struct {
+	Prefill            *Configuration // prefill pool
+	Decode             *Configuration // decode pool
+	KVTransferTime     float32        // fixed KV cache transfer delay per request (msec)
+	KVTransferPerToken float32        // KV cache transfer time per input token (msec/token)
+}
+
+// Analyzer of a P/D disaggregated server as two queues in tandem.
+// The prefill pool processes the input tokens and generates the first token (RequestSize{in, 1}).
+// The decode pool generates the remaining tokens, with the KV cache of the input tokens as context,
+// which adds gamma*in to the per-token cost (decode ServiceParms with Beta + Gamma*in, RequestSize{0, out-1}),
+// without prefill iterations. The decode pool is fed by the throughput of the prefill pool.
+// Requests of a single output token have no decode work: the prefill pool serves them alone.
+type DisaggregatedAnalyzer struct {
+	Config      *DisaggregatedConfig // pools configuration
+	RequestSize *RequestSize         // number of input and output tokens per request
+	Prefill     *LLMQueueAnalyzer    // analyzer of the prefill pool
+	Decode      *LLMQueueAnalyzer    // analyzer of the decode pool (nil => no decode work)
+	RateRange   *RateRange           // range of request rates for stability of both pools
+}
+
+// analysis solution metrics of a disaggregated server
+type DisaggregatedMetrics struct {
+	OfferedRate    float32          // offered arrival rate (requests/sec)
+	Throughput     float32          // effective throughput, out of the decode pool (requests/sec)
+	AvgRespTime    float32          // average end-to-end response time (msec)
+	AvgKVTransfer  float32          // average KV cache transfer time (msec)
+	AvgTTFT        float32          // average time to first token streamed by the decode pool (msec)
+	AvgTokenTime   float32          // average inter-token latency (msec)
+	MaxRate        float32          // maximum throughput, that of the bottleneck pool (requests/sec)
+	PrefillMetrics *AnalysisMetrics // metrics of the prefill pool
+	DecodeMetrics  *AnalysisMetrics // metrics of the decode pool
+}
+
+// replica counts of the pools of a disaggregated server
+type DisaggregatedPlan struct {
+	PrefillReplicas int                   // number of prefill replicas
+	DecodeReplicas  int                   // number of decode replicas
+	Ratio           float32               // prefill:decode replica ratio (0 => no decode replicas)
+	Metrics         *DisaggregatedMetrics // metrics at the given rate
+}
+
+// create a new disaggregated analyzer from pools configuration and request size
+func NewDisaggregatedAnalyzer(dc *DisaggregatedConfig, r *RequestSize) (*DisaggregatedAnalyzer, error) {
+	if err := dc.check(); err != nil {
+		return nil, err
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	prefill, err := NewLLMQueueAnalyzer(dc.Prefill, &RequestSize{AvgInputTokens: r.AvgInputTokens, AvgOutputTokens: 1})
+	if err != nil {
+		return nil, err
+	}
+	da := &DisaggregatedAnalyzer{
+		Config:      dc,
+		RequestSize: r,
+		Prefill:     prefill,
+		RateRange:   prefill.RateRange,
+	}
+	if r.AvgOutputTokens <= 1 {
+		return da, nil
+	}
+
+	decodeConfig := *dc.Decode
+	decodeConfig.ServiceParms = &ServiceParms{
+		Alpha: dc.Decode.ServiceParms.Alpha,
+		Beta:  dc.Decode.ServiceParms.Beta + dc.Decode.ServiceParms.Gamma*r.AvgInputTokens,
+		Gamma: dc.Decode.ServiceParms.Gamma,
+	}
+	decodeConfig.noPrefill = true
+	decode, err := NewLLMQueueAnalyzer(&decodeConfig, &RequestSize{AvgInputTokens: 0, AvgOutputTokens: r.AvgOutputTokens - 1})
+	if err != nil {
+		return nil, err
+	}
+	da.Decode = decode
+	da.RateRange = &RateRange{
+		Min: max(prefill.RateRange.Min, decode.RateRange.Min),
+		Max: min(prefill.RateRange.Max, decode.RateRange.Max),
+	}
+	return da, nil
+}
+
+// evaluate end-to-end performance metrics given request rate
+func (da *DisaggregatedAnalyzer) Analyze(requestRate float32) (metrics *DisaggregatedMetrics, err error) {
+	pm, err := da.Prefill.Analyze(requestRate)
+	if err != nil {
+		return nil, fmt.Errorf("prefill pool: %v", err)
+	}
+	if da.Decode == nil {
+		// the first token is the last
+		return &DisaggregatedMetrics{
+			OfferedRate:    requestRate,
+			Throughput:     pm.Throughput,
+			AvgRespTime:    pm.AvgRespTime,
+			AvgTTFT:        pm.AvgTTFT,
+			MaxRate:        pm.MaxRate,
+			PrefillMetrics: pm,
+		}, nil
+	}
+	dm, err := da.Decode.Analyze(pm.Throughput)
+	if err != nil {
+		return nil, fmt.Errorf("decode pool: %v", err)
+	}
+	kvTransfer := da.Config.KVTransferTime + da.Config.KVTransferPerToken*da.RequestSize.AvgInputTokens
+
+	metrics = &DisaggregatedMetrics{
+		OfferedRate:    requestRate,
+		Throughput:     dm.Throughput,
+		AvgRespTime:    pm.AvgRespTime + kvTransfer + dm.AvgRespTime,
+		AvgKVTransfer:  kvTransfer,
+		AvgTTFT:        pm.AvgWaitTime + pm.AvgPrefillTime + kvTransfer + dm.AvgWaitTime + dm.AvgTokenTime,
+		AvgTokenTime:   dm.AvgTokenTime,
+		MaxRate:        min(pm.MaxRate, dm.MaxRate),
+		PrefillMetrics: pm,
+		DecodeMetrics:  dm,
+	}
+	return metrics, nil
+}
+
+// evaluate max request rate such that the end-to-end TTFT and ITL targets are met
+func (da *DisaggregatedAnalyzer) Size(targetPerf *TargetPerf) (requestRate float32, metrics *DisaggregatedMetrics, err error) {
+	if err := targetPerf.check(); err != nil {
+		return 0, nil, err
+	}
+	rateMin := da.RateRange.Min
+	rateMax := da.RateRange.Max
+
+	search := func(name string, target float32, metric func(m *DisaggregatedMetrics) float32) (float32, error) {
+		eval := func(x float32) (float32, error) {
+			m, err := da.Analyze(x)
+			if err != nil {
+				return 0, err
+			}
+			return metric(m), nil
+		}
+		rateStar, ind, err := utils.BinarySearch(rateMin, rateMax, target, eval)
+		if ind < 0 {
+			err = fmt.Errorf("target is below the bounded region")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to calculate rateStar%s, target%s=%v, range=[%v, %v], ind=%d, err=%v",
+				name, name, target, rateMin, rateMax, ind, err)
+		}
+		return rateStar, nil
+	}
+
+	rateStarTTFT := rateMax
+	if targetPerf.TargetTTFT > 0 {
+		if rateStarTTFT, err = search("TTFT", targetPerf.TargetTTFT,
+			func(m *DisaggregatedMetrics) float32 { return m.AvgTTFT }); err != nil {
+			return 0, nil, err
+		}
+	}
+	rateStarITL := rateMax
+	if targetPerf.TargetITL > 0 && da.Decode != nil {
+		if rateStarITL, err = search("ITL", targetPerf.TargetITL,
+			func(m *DisaggregatedMetrics) float32 { return m.AvgTokenTime }); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	requestRate = min(rateStarTTFT, rateStarITL)
+	if metrics, err = da.Analyze(requestRate); err != nil {
+		return 0, nil, err
+	}
+	return requestRate, metrics, nil
+}
+
+// find the numbers of prefill and decode replicas, with the smallest total of at most maxReplicas,
+// that sustain the given request rate within the TTFT and ITL targets (ties go to fewer prefill replicas)
+func (da *DisaggregatedAnalyzer) SizeRatio(requestRate float32, targetPerf *TargetPerf, maxReplicas int) (plan *DisaggregatedPlan, err error) {
+	if err := targetPerf.check(); err != nil {
+		return nil, err
+	}
+	if requestRate <= 0 || maxReplicas < 2 {
+		return nil, fmt.Errorf("invalid sizing input: requestRate=%v, maxReplicas=%d", requestRate, maxReplicas)
+	}
+
+	// metrics at the given replica counts, nil if a pool is overloaded or a target missed
+	feasible := func(numPrefill, numDecode int) *DisaggregatedMetrics {
+		dc := *da.Config
+		prefill, decode := *dc.Prefill, *dc.Decode
+		prefill.NumReplicas, decode.NumReplicas = numPrefill, numDecode
+		dc.Prefill, dc.Decode = &prefill, &decode
+		pda, err := NewDisaggregatedAnalyzer(&dc, da.RequestSize)
+		if err != nil || requestRate > pda.RateRange.Max {
+			return nil
+		}
+		m, err := pda.Analyze(requestRate)
+		if err != nil ||
+			targetPerf.TargetTTFT > 0 && m.AvgTTFT > targetPerf.TargetTTFT ||
+			targetPerf.TargetITL > 0 && m.AvgTokenTime > targetPerf.TargetITL {
+			return nil
+		}
+		return m
+	}
+
+	// more replicas in either pool never hurt, so for each prefill count take the fewest decode replicas;
+	// without decode work, the decode pool needs none
+	minDecode := 1
+	if da.Decode == nil {
+		minDecode = 0
+	}
+	for numPrefill := 1; numPrefill <= maxReplicas-minDecode; numPrefill++ {
+		maxDecode := maxReplicas - numPrefill
+		if plan != nil {
+			maxDecode = min(maxDecode, plan.PrefillReplicas+plan.DecodeReplicas-numPrefill-1)
+		}
+		for numDecode := minDecode; numDecode <= maxDecode; numDecode++ {
+			if m := feasible(numPrefill, numDecode); m != nil {
+				plan = &DisaggregatedPlan{
+					PrefillReplicas: numPrefill,
+					DecodeReplicas:  numDecode,
+					Metrics:         m,
+				}
+				if numDecode > 0 {
+					plan.Ratio = float32(numPrefill) / float32(numDecode)
+				}
+				break
+			}
+		}
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("no split of %d replicas meets targets %s at rate %v", maxReplicas, targetPerf, requestRate)
+	}
+	return plan, nil
+}
+
+// check validity of disaggregated configuration
+func (dc *DisaggregatedConfig) check() error {
+	if dc == nil || dc.Prefill == nil || dc.Decode == nil || dc.KVTransferTime < 0 || dc.KVTransferPerToken < 0 {
+		return fmt.Errorf("invalid disaggregated configuration %s", dc)
+	}
+	if err := dc.Prefill.check(); err != nil {
+		return err
+	}
+	return dc.Decode.check()
+}
+
+func (dc *DisaggregatedConfig) String() string {
+	if dc == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{prefill:%s, decode:%s, kvTransfer=%v+%v/token}",
+		dc.Prefill, dc.Decode, dc.KVTransferTime, dc.KVTransferPerToken)
+}
+
+func (dm *DisaggregatedMetrics) String() string {
+	return fmt.Sprintf("{rate=%.3f, tput=%.3f, lat=%.3f, kv=%.3f, ttft=%.3f, itl=%.3f, maxRate=%.3f, prefill:%s, decode:%s}",
+		dm.OfferedRate, dm.Throughput, dm.AvgRespTime, dm.AvgKVTransfer, dm.AvgTTFT, dm.AvgTokenTime, dm.MaxRate,
+		dm.PrefillMetrics, dm.DecodeMetrics)
+}
@@ -0,0 +1,111 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func newDisaggregatedConfig() *DisaggregatedConfig {
+	sp, _ := baselineParts()
+	return &DisaggregatedConfig{
+		Prefill:        &Configuration{MaxBatchSize: 8, MaxQueueSize: 128, ServiceParms: sp},
+		Decode:         &Configuration{MaxBatchSize: 64, MaxQueueSize: 128, ServiceParms: sp},
+		KVTransferTime: 5,
+	}
+}
+
+func TestDisaggregatedAnalyzeComposesPools(t *testing.T) {
+	_, rs := baselineParts()
+	da, err := NewDisaggregatedAnalyzer(newDisaggregatedConfig(), rs)
+	if err != nil {
+		t.Fatalf("NewDisaggregatedAnalyzer: %v", err)
+	}
+	m, err := da.Analyze(0.5 * da.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	pm, dm := m.PrefillMetrics, m.DecodeMetrics
+	want := pm.AvgWaitTime + pm.AvgPrefillTime + 5 + dm.AvgWaitTime + dm.AvgTokenTime
+	if math.Abs(float64(m.AvgTTFT-want)) > 1e-3 {
+		t.Errorf("TTFT: got %v, want %v", m.AvgTTFT, want)
+	}
+	if m.AvgTokenTime != dm.AvgTokenTime || m.Throughput != dm.Throughput {
+		t.Errorf("ITL and throughput should be those of the decode pool: %s", m)
+	}
+	if dm.OfferedRate != pm.Throughput {
+		t.Errorf("decode pool offered %v, want prefill throughput %v", dm.OfferedRate, pm.Throughput)
+	}
+}
+
+func TestDisaggregatedSize(t *testing.T) {
+	_, rs := baselineParts()
+	da, err := NewDisaggregatedAnalyzer(newDisaggregatedConfig(), rs)
+	if err != nil {
+		t.Fatalf("NewDisaggregatedAnalyzer: %v", err)
+	}
+	target := &TargetPerf{TargetTTFT: 100, TargetITL: 15}
+	rate, m, err := da.Size(target)
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if rate <= 0 || m.AvgTTFT > 100*1.001 || m.AvgTokenTime > 15*1.001 {
+		t.Errorf("rate %v misses targets: %s", rate, m)
+	}
+
+	// twice the rate needs more replicas, split between the pools
+	plan, err := da.SizeRatio(2*rate, target, 16)
+	if err != nil {
+		t.Fatalf("SizeRatio: %v", err)
+	}
+	if plan.PrefillReplicas+plan.DecodeReplicas <= 2 {
+		t.Errorf("plan %+v should add replicas", plan)
+	}
+	if plan.Metrics.AvgTTFT > 100 || plan.Metrics.AvgTokenTime > 15 {
+		t.Errorf("plan misses targets: %s", plan.Metrics)
+	}
+	if _, err := da.SizeRatio(2*rate, target, 2); err == nil {
+		t.Errorf("expected error with too few replicas")
+	}
+}
+
+func TestDisaggregatedDecodePoolHasNoPrefill(t *testing.T) {
+	_, rs := baselineParts()
+	da, err := NewDisaggregatedAnalyzer(newDisaggregatedConfig(), rs)
+	if err != nil {
+		t.Fatalf("NewDisaggregatedAnalyzer: %v", err)
+	}
+	for B, nc := range da.Decode.NumChunks[1:] {
+		if nc != 0 {
+			t.Fatalf("decode pool: %d prefill chunks at batch size %d", nc, B+1)
+		}
+	}
+	m, err := da.Analyze(0.5 * da.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if m.DecodeMetrics.AvgPrefillTime != 0 {
+		t.Errorf("decode pool prefill time %v, want 0", m.DecodeMetrics.AvgPrefillTime)
+	}
+
+	// a single output token is generated by the prefill pool alone
+	da, err = NewDisaggregatedAnalyzer(newDisaggregatedConfig(), &RequestSize{AvgInputTokens: rs.AvgInputTokens, AvgOutputTokens: 1})
+	if err != nil {
+		t.Fatalf("NewDisaggregatedAnalyzer: %v", err)
+	}
+	if da.Decode != nil {
+		t.Fatalf("decode pool without decode work")
+	}
+	if m, err = da.Analyze(0.5 * da.RateRange.Max); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if m.AvgTokenTime != 0 || m.AvgTTFT != m.PrefillMetrics.AvgTTFT || m.DecodeMetrics != nil {
+		t.Errorf("single-token metrics: %s", m)
+	}
+	plan, err := da.SizeRatio(m.OfferedRate, &TargetPerf{TargetTTFT: 2 * m.AvgTTFT, TargetITL: 15}, 4)
+	if err != nil {
+		t.Fatalf("SizeRatio: %v", err)
+	}
+	if plan.PrefillReplicas != 1 || plan.DecodeReplicas != 0 {
+		t.Errorf("plan %+v, want a single prefill replica", plan)
+	}
+}
@@ -0,0 +1,109 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+func TestExactMetricsMode(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	rate := 0.7 * qa.RateRange.Max
+	meanField, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	// the default mode shares the mean-field prefill time, and takes the decode time from the service time
+	if math.Abs(float64(meanField.AvgWaitTime+meanField.AvgPrefillTime+meanField.MeanFieldITL-meanField.MeanFieldTTFT)) > 1e-4 {
+		t.Errorf("default mode should report the mean-field prefill time: %+v", meanField)
+	}
+	if math.Abs(float64(meanField.AvgTokenTime-meanField.MeanFieldITL)) > 0.1*float64(meanField.MeanFieldITL) {
+		t.Errorf("default-mode ITL %v far from mean-field ITL %v", meanField.AvgTokenTime, meanField.MeanFieldITL)
+	}
+
+	qa.MetricsMode = ExactMetrics
+	exact, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if exact.AvgTTFT != exact.ExactTTFT || exact.AvgTokenTime != exact.ExactITL {
+		t.Errorf("exact mode should report exact values: %+v", exact)
+	}
+	if exact.ExactTTFT != meanField.ExactTTFT || exact.MeanFieldITL != meanField.MeanFieldITL {
+		t.Errorf("both estimates should be reported in either mode")
+	}
+	// ITL is increasing in the batch size, and requests see larger batches than the time average
+	if exact.ExactITL <= exact.MeanFieldITL {
+		t.Errorf("exact ITL %v not above mean-field ITL %v", exact.ExactITL, exact.MeanFieldITL)
+	}
+	if math.Abs(float64(exact.ExactITL-exact.MeanFieldITL)) > 0.1*float64(exact.MeanFieldITL) {
+		t.Errorf("exact ITL %v far from mean-field ITL %v", exact.ExactITL, exact.MeanFieldITL)
+	}
+
+	_, _, achieved, err := qa.Size(&TargetPerf{TargetITL: exact.ExactITL})
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if math.Abs(float64(achieved.TargetITL-exact.ExactITL)) > 1e-3*float64(exact.ExactITL) {
+		t.Errorf("exact-mode sizing achieved ITL %v, want %v", achieved.TargetITL, exact.ExactITL)
+	}
+}
+
+func TestReplicaBatchSizes(t *testing.T) {
+	for _, c := range []struct {
+		n        int
+		dispatch queue.DispatchPolicy
+		want     map[int]int
+	}{
+		{7, queue.BalancedDispatch, map[int]int{2: 3, 1: 1}},
+		{8, queue.BalancedDispatch, map[int]int{2: 4}},
+		{7, queue.PackedDispatch, map[int]int{3: 2, 1: 1}},
+	} {
+		got := map[int]int{}
+		total := 0
+		for _, rb := range replicaBatchSizes(c.n, 4, 3, c.dispatch) {
+			if rb.batchSize > 0 && rb.numReplicas > 0 {
+				got[rb.batchSize] += rb.numReplicas
+				total += rb.batchSize * rb.numReplicas
+			}
+		}
+		if total != c.n || len(got) != len(c.want) {
+			t.Errorf("n=%d %s: got %v, want %v", c.n, c.dispatch, got, c.want)
+		}
+		for b, k := range c.want {
+			if got[b] != k {
+				t.Errorf("n=%d %s: got %v, want %v", c.n, c.dispatch, got, c.want)
+			}
+		}
+	}
+}
+
+func TestMeanFieldBatchSize(t *testing.T) {
+	if got := meanFieldBatchSize(7, 4, 3, queue.BalancedDispatch); got != 1.75 {
+		t.Errorf("balanced: got %v, want 1.75", got)
+	}
+	// two full batches of 3 and one request alone
+	if got := meanFieldBatchSize(7, 4, 3, queue.PackedDispatch); math.Abs(float64(got-19.0/7)) > 1e-6 {
+		t.Errorf("packed: got %v, want %v", got, 19.0/7)
+	}
+
+	sp, rs := baselineParts()
+	var prefill [2]float32
+	for i, dispatch := range []queue.DispatchPolicy{queue.BalancedDispatch, queue.PackedDispatch} {
+		cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, NumReplicas: 4, Dispatch: dispatch, ServiceParms: sp}
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		metrics, err := qa.Analyze(0.5 * qa.RateRange.Max)
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		prefill[i] = metrics.AvgPrefillTime
+	}
+	// packed replicas run larger batches than the even share
+	if prefill[1] <= prefill[0] {
+		t.Errorf("packed mean-field prefill %v not above balanced %v", prefill[1], prefill[0])
+	}
+}
@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeneralServiceScalesWait(t *testing.T) {
+	sp, rs := baselineParts()
+	exponential := newBaselineAnalyzer(t, 16, 256)
+	rate := 0.9 * exponential.RateRange.Max
+	want, err := exponential.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	for _, scv := range []float32{1, 0.25, 3} {
+		cfg := &Configuration{MaxBatchSize: 16, MaxQueueSize: 256, ServiceParms: sp, GeneralService: true, ServiceSCV: scv}
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		got, err := qa.Analyze(rate)
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		factor := float64(1+scv) / 2
+		if math.Abs(float64(got.AvgWaitTime)-factor*float64(want.AvgWaitTime)) > 1e-3*float64(want.AvgWaitTime) {
+			t.Errorf("scv=%v: wait got %v, want %v", scv, got.AvgWaitTime, factor*float64(want.AvgWaitTime))
+		}
+		if got.AvgPrefillTime != want.AvgPrefillTime || got.Throughput != want.Throughput {
+			t.Errorf("scv=%v: service metrics changed: got %s, want %s", scv, got, want)
+		}
+	}
+}
+
+func TestGeneralServiceSCVFromTokenDistributions(t *testing.T) {
+	sp, rs := baselineParts()
+	spread := &RequestSize{
+		AvgInputTokens: rs.AvgInputTokens,
+		OutputTokens:   NewLognormalDistribution(rs.AvgOutputTokens, 2),
+	}
+	cfg := &Configuration{MaxBatchSize: 16, MaxQueueSize: 256, ServiceParms: sp}
+	exponential, err := NewLLMQueueAnalyzer(cfg, spread)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	general := *cfg
+	general.GeneralService = true
+	qa, err := NewLLMQueueAnalyzer(&general, spread)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	scv := qa.ServiceSCV[qa.MaxBatchSize]
+	if scv <= 1 {
+		t.Fatalf("expected a service scv above 1 for heavy-tailed outputs, got %v", scv)
+	}
+
+	rate := 0.9 * qa.RateRange.Max
+	want, err := exponential.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	got, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got.AvgWaitTime <= want.AvgWaitTime || got.PctWaitTime <= want.PctWaitTime {
+		t.Errorf("variable service should lengthen the wait: got %s, want above %s", got, want)
+	}
+
+	general.Abandonment = &Abandonment{PatienceTime: 1000}
+	if _, err := NewLLMQueueAnalyzer(&general, spread); err == nil {
+		t.Errorf("expected error for general service with abandonment")
+	}
+}
+
+func TestGeneralServiceDefaultSCV(t *testing.T) {
+	sp, rs := baselineParts()
+	exponential := newBaselineAnalyzer(t, 16, 256)
+	rate := 0.9 * exponential.RateRange.Max
+	want, err := exponential.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	// without token distributions, the default SCV is that of exponential service
+	cfg := &Configuration{MaxBatchSize: 16, MaxQueueSize: 256, ServiceParms: sp, GeneralService: true}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	got, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if math.Abs(float64(got.AvgWaitTime-want.AvgWaitTime)) > 1e-3*float64(want.AvgWaitTime) {
+		t.Errorf("wait got %v, want %v", got.AvgWaitTime, want.AvgWaitTime)
+	}
+
+	mca, err := NewMultiClassAnalyzer(cfg, []*RequestClass{{Name: "chat", Rate: rate, RequestSize: rs}})
+	if err != nil {
+		t.Fatalf("NewMultiClassAnalyzer: %v", err)
+	}
+	mixed, err := mca.Analyze()
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if math.Abs(float64(mixed.Aggregate.AvgWaitTime-want.AvgWaitTime)) > 1e-3*float64(want.AvgWaitTime) {
+		t.Errorf("multi-class wait got %v, want %v", mixed.Aggregate.AvgWaitTime, want.AvgWaitTime)
+	}
+
+	arrivals := &ArrivalProcess{Rates: []float32{2, 1}, SwitchRates: [][]float32{{0, 1}, {1, 0}}}
+	if _, err := qa.AnalyzeArrivals(arrivals); err == nil {
+		t.Errorf("expected error for general service with an arrival process")
+	}
+}
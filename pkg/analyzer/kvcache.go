@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+)
+
+// number of tokens per KV cache block, unless otherwise specified
+const DefaultKVBlockSize = 16
+
+// KV cache capacity model of a server (replica).
+//
+// A request in service holds the KV cache of its context, growing from the input tokens to the
+// input and output tokens, on average in + out/2 tokens (plus half a block of allocation waste).
+// The effective batch limit is the number of average requests that fit in the cache. In a batch
+// of B requests, the cache overflows when the sum of the contexts, approximately normal with mean
+// B*footprint and variance B*spread^2 (contexts spread uniformly over the output tokens), exceeds
+// the capacity. In overflow, the B new tokens of an iteration need cache space, freed by preempting
+// requests, each releasing a footprint worth of tokens; the KV cache of a preempted request is
+// dropped and later recomputed, costing a prefill of its context.
+type kvCache struct {
+	capacity  float32 // KV cache capacity (tokens)
+	footprint float32 // average KV cache tokens held by a request in service
+	spread    float32 // standard deviation of the KV cache tokens held by a request in service
+	recompute float32 // work of recomputing the KV cache of a preempted request (msec)
+}
+
+// KV cache model of a configuration and request size, nil if the KV cache capacity is not bounded
+func newKVCache(c *Configuration, r *RequestSize) *kvCache {
+	capacity := c.KVCacheTokens
+	if capacity == 0 {
+		capacity = c.KVCacheBlocks * c.kvBlockSize()
+	}
+	if capacity == 0 {
+		return nil
+	}
+	p := c.ServiceParms
+	context := r.AvgInputTokens + r.AvgOutputTokens/2
+	return &kvCache{
+		capacity:  float32(capacity),
+		footprint: context + float32(c.kvBlockSize())/2,
+		spread:    r.AvgOutputTokens / float32(math.Sqrt(12)),
+		recompute: (p.Beta + p.Gamma*(context+1)/2) * context,
+	}
+}
+
+// max number of requests in service that fit in the KV cache, on average
+func (kv *kvCache) batchLimit() int {
+	return max(int(kv.capacity/kv.footprint), 1)
+}
+
+// probability that the contexts of a batch of requests overflow the KV cache
+func (kv *kvCache) overflowProbability(batchSize float32) float32 {
+	mean := batchSize * kv.footprint
+	sd := float32(math.Sqrt(float64(batchSize))) * kv.spread
+	if sd == 0 {
+		if mean > kv.capacity {
+			return 1
+		}
+		return 0
+	}
+	z := float64((kv.capacity - mean) / sd)
+	return float32(0.5 * math.Erfc(z/math.Sqrt2))
+}
+
+// average number of preemptions per request served in a batch of the given size: over the
+// numChunks + AvgOutputTokens iterations of a request, the batch preempts batchSize/footprint
+// requests per iteration in overflow, shared by the batchSize requests
+func (kv *kvCache) preemptionsPerRequest(r *RequestSize, batchSize float32, numChunks int) float32 {
+	if batchSize <= 0 {
+		return 0
+	}
+	return (float32(numChunks) + r.AvgOutputTokens) * kv.overflowProbability(batchSize) / kv.footprint
+}
+
+// recompute work per iteration of a batch of the given size: the batchSize/footprint requests
+// preempted per iteration in overflow, each recomputing its context; over the numChunks +
+// AvgOutputTokens iterations of a request, this is the recompute work added to its service time
+func (kv *kvCache) recomputePerIteration(batchSize float32) float32 {
+	if batchSize <= 0 {
+		return 0
+	}
+	return batchSize * kv.overflowProbability(batchSize) / kv.footprint * kv.recompute
+}
+
+// number of tokens per KV cache block
+func (c *Configuration) kvBlockSize() int {
+	if c.KVBlockSize > 0 {
+		return c.KVBlockSize
+	}
+	return DefaultKVBlockSize
+}
+
+func (kv *kvCache) String() string {
+	return fmt.Sprintf("{capacity=%.0f, footprint=%.1f, spread=%.1f, recompute=%.3f}",
+		kv.capacity, kv.footprint, kv.spread, kv.recompute)
+}
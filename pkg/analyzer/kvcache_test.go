@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKVCacheBoundsBatchAndPreempts(t *testing.T) {
+	sp, rs := baselineParts()
+	// an average request holds 256 + 512 tokens, plus half a block
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, KVCacheBlocks: 1600}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	if want := 1600 * 16 / (256 + 512 + 8); qa.MaxBatchSize != want || qa.KVBatchLimit != want {
+		t.Errorf("effective batch limit: got %d (KVBatchLimit %d), want %d", qa.MaxBatchSize, qa.KVBatchLimit, want)
+	}
+	unbounded := newBaselineAnalyzer(t, 64, 256)
+	if qa.RateRange.Max >= unbounded.RateRange.Max {
+		t.Errorf("KV cache should lower the max rate: %v >= %v", qa.RateRange.Max, unbounded.RateRange.Max)
+	}
+
+	light, err := qa.Analyze(0.1 * qa.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	heavy, err := qa.Analyze(0.95 * qa.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if heavy.PreemptionRate <= light.PreemptionRate || heavy.PreemptionRate <= 0 {
+		t.Errorf("preemptions should grow with load: light %v, heavy %v", light.PreemptionRate, heavy.PreemptionRate)
+	}
+}
+
+func TestLargeKVCacheMatchesUnbounded(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, KVCacheTokens: 1 << 24}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	unbounded := newBaselineAnalyzer(t, 64, 256)
+	rate := 0.8 * unbounded.RateRange.Max
+	got, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	want, err := unbounded.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if qa.KVBatchLimit != 0 {
+		t.Errorf("KV batch limit %d reported above the max batch size", qa.KVBatchLimit)
+	}
+	if got.AvgTTFT != want.AvgTTFT || got.AvgTokenTime != want.AvgTokenTime || got.PreemptionRate != 0 {
+		t.Errorf("got %s (preemptions %v), want %s", got, got.PreemptionRate, want)
+	}
+}
+
+func TestKVCacheRecomputeInDecodeTimes(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, KVCacheBlocks: 1600}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	B := float32(qa.MaxBatchSize)
+	nc := qa.numChunksAt(B)
+	overhead := qa.kvCache.recomputePerIteration(B)
+	if overhead <= 0 {
+		t.Fatalf("no recompute work at the full batch")
+	}
+	// the recompute work per request is spread over its iterations
+	tau := (float32(nc) + rs.AvgOutputTokens) * overhead
+	if want := tauNew(sp, rs, qa.MaxBatchSize, nc) + tau; math.Abs(float64(B/qa.servRate[qa.MaxBatchSize-1]-want)) > 1e-4*float64(want) {
+		t.Errorf("service time %v, want %v", B/qa.servRate[qa.MaxBatchSize-1], want)
+	}
+	data := qa.evalFuncData()
+	if got, want := data.itlTime(B), itlNew(sp, rs, B, nc)+overhead; math.Abs(float64(got-want)) > 1e-5*float64(want) {
+		t.Errorf("ITL %v, want %v", got, want)
+	}
+	if got, want := data.prefillTime(B), prefillNew(sp, rs, B, nc)+float32(nc)*overhead; math.Abs(float64(got-want)) > 1e-5*float64(want) {
+		t.Errorf("prefill time %v, want %v", got, want)
+	}
+
+	qa.MetricsMode = ExactMetrics
+	rate := 0.95 * qa.RateRange.Max
+	metrics, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	data.kvCache = nil
+	if err := data.solve(rate / 1000); err != nil {
+		t.Fatalf("solve: %v", err)
+	}
+	if _, itl := data.exactTimes(); metrics.ExactITL <= itl {
+		t.Errorf("exact ITL %v should include the recompute work, above %v", metrics.ExactITL, itl)
+	}
+}
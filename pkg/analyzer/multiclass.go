@@ -0,0 +1,279 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// class of requests with its own load, token profile, and performance targets
+type RequestClass struct {
+	Name        string       // class name (e.g. chat, summarization, RAG)
+	Rate        float32      // request arrival rate (requests/sec)
+	RequestSize *RequestSize // number of input and output tokens per request
+	TargetPerf  *TargetPerf  // targets of the class, only TargetTTFT and TargetITL (nil => none)
+}
+
+// Analyzer of inference server queue shared (FCFS) by a mix of request classes
+type MultiClassAnalyzer struct {
+	MaxBatchSize int                           // maximum batch size
+	MaxNumTokens int                           // maximum number of tokens per batch
+	MaxQueueSize int                           // maximum queue size
+	NumReplicas  int                           // number of servers (replicas) sharing the queue
+	Dispatch     queue.DispatchPolicy          // dispatching of requests in service to replicas (NumReplicas > 1)
+	ServiceParms *ServiceParms                 // request processing parameters
+	Classes      []*RequestClass               // request classes
+	Model        *queue.MM1ModelStateDependent // queueing model of the mix
+	RateRange    *RateRange                    // range of total request rates, at the given class mix
+	NumChunks    [][]int                       // NumChunks[c][B] = number of prefill chunks of class c at batch size B
+
+	totalRate float32   // total request rate of the classes (requests/sec)
+	deltaMix  []float32 // deltaMix[B] = per-request work per iteration, averaged over the batch mix at batch size B
+}
+
+// per-class performance metrics
+type ClassMetrics struct {
+	Name           string  // class name
+	OfferedRate    float32 // offered arrival rate (requests/sec)
+	Throughput     float32 // effective throughput (requests/sec)
+	AvgRespTime    float32 // average request response time (msec)
+	AvgWaitTime    float32 // average request queueing time (msec)
+	AvgPrefillTime float32 // average request prefill time (msec)
+	AvgTokenTime   float32 // average token decode time (msec)
+	AvgTTFT        float32 // average time to first token (msec)
+}
+
+// analysis solution metrics of a request mix
+type MultiClassMetrics struct {
+	Scale     float32          // scale factor applied to the class rates
+	Aggregate *AnalysisMetrics // metrics of the mix (TTFT and prefill request-weighted, ITL token-weighted)
+	Classes   []*ClassMetrics  // metrics of each class
+}
+
+// create a new multi-class queue analyzer from config and request classes
+func NewMultiClassAnalyzer(qConfig *Configuration, classes []*RequestClass) (*MultiClassAnalyzer, error) {
+	if err := qConfig.check(); err != nil {
+		return nil, err
+	}
+	if qConfig.KVCacheTokens > 0 || qConfig.KVCacheBlocks > 0 {
+		return nil, fmt.Errorf("KV cache capacity is not supported with request classes %s", qConfig)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("no request classes")
+	}
+	var totalRate float32
+	for _, rc := range classes {
+		if err := rc.check(); err != nil {
+			return nil, err
+		}
+		totalRate += rc.Rate
+	}
+	if totalRate <= 0 {
+		return nil, fmt.Errorf("invalid total request rate %v", totalRate)
+	}
+	return BuildMultiClassModel(qConfig, classes, totalRate), nil
+}
+
+// build queueing model of the class mix.
+//
+// A batch of B requests holds class c in proportion f_c = lambda_c * L_c / sum(lambda * L),
+// where L_c = nc_c + m_c is the number of iterations of a class-c request (Little's law per
+// class). The iteration time is T(B) = alpha + B * deltaMix(B), with deltaMix the f-weighted
+// average of the per-class work per iteration, and the total service rate at batch size B is
+// B / (T(B) * Lbar), with Lbar the request-weighted average of L_c.
+func BuildMultiClassModel(c *Configuration, classes []*RequestClass, totalRate float32) *MultiClassAnalyzer {
+	parms := c.ServiceParms
+
+	numChunks := make([][]int, len(classes))
+	for k, rc := range classes {
+		numChunks[k] = NumIterationsPerPrefill(c, rc.RequestSize)
+	}
+
+	servRate := make([]float32, c.MaxBatchSize)
+	deltaMix := make([]float32, c.MaxBatchSize+1)
+	for B := 1; B <= c.MaxBatchSize; B++ {
+		var weightedIters, weightedDelta, avgIters float32
+		for k, rc := range classes {
+			nc := numChunks[k][B]
+			iters := float32(nc) + rc.RequestSize.AvgOutputTokens
+			weightedIters += rc.Rate * iters
+			weightedDelta += rc.Rate * iters * delta(parms, rc.RequestSize, nc)
+			avgIters += rc.Rate / totalRate * iters
+		}
+		deltaMix[B] = weightedDelta / weightedIters
+		servRate[B-1] = float32(B) / ((parms.Alpha + float32(B)*deltaMix[B]) * avgIters)
+	}
+
+	return &MultiClassAnalyzer{
+		MaxBatchSize: c.MaxBatchSize,
+		MaxNumTokens: c.MaxNumTokens,
+		MaxQueueSize: c.MaxQueueSize,
+		NumReplicas:  max(c.NumReplicas, 1),
+		Dispatch:     c.Dispatch,
+		ServiceParms: parms,
+		Classes:      classes,
+		Model:        newQueueModel(c, servRate, c.ServiceSCV),
+		RateRange:    newRateRange(c, servRate),
+		NumChunks:    numChunks,
+		totalRate:    totalRate,
+		deltaMix:     deltaMix,
+	}
+}
+
+// evaluate performance metrics at the class rates
+func (mca *MultiClassAnalyzer) Analyze() (*MultiClassMetrics, error) {
+	return mca.AnalyzeScaled(1)
+}
+
+// evaluate performance metrics with all class rates multiplied by a scale factor
+func (mca *MultiClassAnalyzer) AnalyzeScaled(scale float32) (*MultiClassMetrics, error) {
+	if scale <= 0 {
+		return nil, fmt.Errorf("invalid scale factor %v", scale)
+	}
+	requestRate := scale * mca.totalRate
+	model := mca.Model
+	model.Solve(requestRate/1000, 1)
+	if !model.IsValid() {
+		return nil, fmt.Errorf("invalid model %s", model)
+	}
+
+	avgNumInServ := model.GetAvgNumInServers()
+	batchSize := meanFieldBatchSize(avgNumInServ, mca.NumReplicas, mca.MaxBatchSize, mca.Dispatch)
+	B := min(max(int(batchSize+0.5), 1), mca.MaxBatchSize)
+	background := max(mca.ServiceParms.Alpha+(batchSize-1)*mca.deltaMix[B], 0)
+
+	// per-class service times are proportional to the number of iterations
+	var avgIters float32
+	for k, rc := range mca.Classes {
+		avgIters += rc.Rate / mca.totalRate * (float32(mca.NumChunks[k][B]) + rc.RequestSize.AvgOutputTokens)
+	}
+	avgWaitTime := model.GetAvgWaitTime()
+	admitted := model.GetThroughput() * 1000 / requestRate
+
+	aggregate := &AnalysisMetrics{
+		OfferedRate:  requestRate,
+		Throughput:   model.GetThroughput() * 1000,
+		AvgRespTime:  model.GetAvgRespTime(),
+		AvgWaitTime:  avgWaitTime,
+		AvgNumInServ: avgNumInServ,
+		MaxRate:      mca.RateRange.Max,
+		Rho:          min(max(avgNumInServ/float32(mca.NumReplicas*mca.MaxBatchSize), 0), 1),
+	}
+	metrics := &MultiClassMetrics{
+		Scale:     scale,
+		Aggregate: aggregate,
+		Classes:   make([]*ClassMetrics, len(mca.Classes)),
+	}
+	var outputRate float32
+	for k, rc := range mca.Classes {
+		r := rc.RequestSize
+		nc := mca.NumChunks[k][B]
+		iters := float32(nc) + r.AvgOutputTokens
+		servTime := model.GetAvgServTime() * iters / avgIters
+		prefillTime := float32(nc)*background + wPrefill(mca.ServiceParms, r, nc)
+		tokenTime := (servTime - prefillTime) / r.AvgOutputTokens
+		cm := &ClassMetrics{
+			Name:           rc.Name,
+			OfferedRate:    scale * rc.Rate,
+			Throughput:     scale * rc.Rate * admitted,
+			AvgRespTime:    avgWaitTime + servTime,
+			AvgWaitTime:    avgWaitTime,
+			AvgPrefillTime: prefillTime,
+			AvgTokenTime:   tokenTime,
+			AvgTTFT:        avgWaitTime + prefillTime + tokenTime,
+		}
+		metrics.Classes[k] = cm
+
+		share := rc.Rate / mca.totalRate
+		aggregate.AvgPrefillTime += share * prefillTime
+		aggregate.AvgTTFT += share * cm.AvgTTFT
+		aggregate.AvgTokenTime += rc.Rate * r.AvgOutputTokens * tokenTime
+		outputRate += rc.Rate * r.AvgOutputTokens
+	}
+	aggregate.AvgTokenTime /= outputRate
+	return metrics, nil
+}
+
+// evaluate the max scale factor of the class rates such that every class meets its targets
+func (mca *MultiClassAnalyzer) Size() (scale float32, metrics *MultiClassMetrics, err error) {
+	scaleMin := mca.RateRange.Min / mca.totalRate
+	scaleMax := mca.RateRange.Max / mca.totalRate
+
+	scale = scaleMax
+	for k, rc := range mca.Classes {
+		if rc.TargetPerf == nil {
+			continue
+		}
+		targets := []struct {
+			name   string
+			target float32
+			metric func(cm *ClassMetrics) float32
+		}{
+			{"TTFT", rc.TargetPerf.TargetTTFT, func(cm *ClassMetrics) float32 { return cm.AvgTTFT }},
+			{"ITL", rc.TargetPerf.TargetITL, func(cm *ClassMetrics) float32 { return cm.AvgTokenTime }},
+		}
+		for _, t := range targets {
+			if t.target <= 0 {
+				continue
+			}
+			eval := func(x float32) (float32, error) {
+				m, err := mca.AnalyzeScaled(x)
+				if err != nil {
+					return 0, err
+				}
+				return t.metric(m.Classes[k]), nil
+			}
+			scaleStar, ind, err := utils.BinarySearch(scaleMin, scaleMax, t.target, eval)
+			if ind < 0 {
+				err = fmt.Errorf("target is below the bounded region")
+			}
+			if err != nil {
+				return 0, nil, fmt.Errorf("failed to calculate scale for class %s, target%s=%v, ind=%d, err=%v",
+					rc.Name, t.name, t.target, ind, err)
+			}
+			scale = min(scale, scaleStar)
+		}
+	}
+
+	if metrics, err = mca.AnalyzeScaled(scale); err != nil {
+		return 0, nil, err
+	}
+	return scale, metrics, nil
+}
+
+// check validity of request class
+func (rc *RequestClass) check() error {
+	if rc == nil || rc.RequestSize == nil || rc.Rate < 0 {
+		return fmt.Errorf("invalid request class %s", rc)
+	}
+	if rc.RequestSize.InputTokens != nil || rc.RequestSize.OutputTokens != nil {
+		return fmt.Errorf("token distributions are not supported in request class %s", rc)
+	}
+	if err := rc.RequestSize.check(); err != nil {
+		return err
+	}
+	if t := rc.TargetPerf; t != nil {
+		if err := t.check(); err != nil {
+			return err
+		}
+		// only the average TTFT and ITL targets are sized per class
+		if t.TargetTPS > 0 || t.TargetUserTPS > 0 || t.TargetPctTTFT > 0 || t.TargetPctRespTime > 0 ||
+			t.MaxAbandonFraction > 0 {
+			return fmt.Errorf("only TTFT and ITL targets are supported in request class %s", rc)
+		}
+	}
+	return nil
+}
+
+func (rc *RequestClass) String() string {
+	if rc == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{name=%s, rate=%.3f, reqSize:%s, target:%s}", rc.Name, rc.Rate, rc.RequestSize, rc.TargetPerf)
+}
+
+func (cm *ClassMetrics) String() string {
+	return fmt.Sprintf("{name=%s, rate=%.3f, tput=%.3f, lat=%.3f, wait=%.3f, prefill=%.3f, ttft=%.3f, itl=%.3f}",
+		cm.Name, cm.OfferedRate, cm.Throughput, cm.AvgRespTime, cm.AvgWaitTime, cm.AvgPrefillTime, cm.AvgTTFT, cm.AvgTokenTime)
+}
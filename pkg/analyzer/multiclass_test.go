@@ -0,0 +1,134 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+func TestMultiClassSingleClassMatchesAnalyzer(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	rate := 0.8 * qa.RateRange.Max
+	want, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	mca, err := NewMultiClassAnalyzer(cfg, []*RequestClass{{Name: "chat", Rate: rate, RequestSize: rs}})
+	if err != nil {
+		t.Fatalf("NewMultiClassAnalyzer: %v", err)
+	}
+	got, err := mca.Analyze()
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	cm := got.Classes[0]
+	for _, c := range []struct {
+		name      string
+		got, want float32
+	}{
+		{"throughput", cm.Throughput, want.Throughput},
+		{"wait", cm.AvgWaitTime, want.AvgWaitTime},
+		{"prefill", cm.AvgPrefillTime, want.AvgPrefillTime},
+		{"itl", cm.AvgTokenTime, want.AvgTokenTime},
+		{"ttft", cm.AvgTTFT, want.AvgTTFT},
+		{"aggregate ttft", got.Aggregate.AvgTTFT, want.AvgTTFT},
+	} {
+		if math.Abs(float64(c.got-c.want)) > 1e-3*math.Max(1, math.Abs(float64(c.want))) {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestMultiClassSizeMeetsClassTargets(t *testing.T) {
+	sp, _ := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp}
+	classes := []*RequestClass{
+		{Name: "chat", Rate: 2, RequestSize: &RequestSize{AvgInputTokens: 128, AvgOutputTokens: 512},
+			TargetPerf: &TargetPerf{TargetITL: 20}},
+		{Name: "summarization", Rate: 1, RequestSize: &RequestSize{AvgInputTokens: 2048, AvgOutputTokens: 128},
+			TargetPerf: &TargetPerf{TargetTTFT: 500}},
+	}
+	mca, err := NewMultiClassAnalyzer(cfg, classes)
+	if err != nil {
+		t.Fatalf("NewMultiClassAnalyzer: %v", err)
+	}
+	scale, metrics, err := mca.Size()
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if scale <= 0 || metrics.Scale != scale {
+		t.Fatalf("scale: got %v (metrics %v)", scale, metrics.Scale)
+	}
+	chat, summ := metrics.Classes[0], metrics.Classes[1]
+	if chat.AvgTokenTime > 20*1.01 || summ.AvgTTFT > 500*1.01 {
+		t.Errorf("targets violated at scale %v: chat %s, summarization %s", scale, chat, summ)
+	}
+	// one of the targets is binding
+	if chat.AvgTokenTime < 20*0.99 && summ.AvgTTFT < 500*0.99 && scale < mca.RateRange.Max/3*0.99 {
+		t.Errorf("no binding target at scale %v: chat %s, summarization %s", scale, chat, summ)
+	}
+	// long prompts take longer to prefill
+	if summ.AvgPrefillTime <= chat.AvgPrefillTime {
+		t.Errorf("prefill: summarization %v not above chat %v", summ.AvgPrefillTime, chat.AvgPrefillTime)
+	}
+}
+
+func TestMultiClassRejectsUnsupported(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp}
+	for _, target := range []*TargetPerf{
+		{TargetTPS: 1000}, {TargetUserTPS: 50}, {TargetPctTTFT: 500}, {TargetPctRespTime: 10000}, {MaxAbandonFraction: 0.1},
+	} {
+		classes := []*RequestClass{{Name: "chat", Rate: 1, RequestSize: rs, TargetPerf: target}}
+		if _, err := NewMultiClassAnalyzer(cfg, classes); err == nil {
+			t.Errorf("expected error for class target %s", target)
+		}
+	}
+	spread := &RequestSize{AvgInputTokens: 256, OutputTokens: NewLognormalDistribution(512, 1)}
+	if _, err := NewMultiClassAnalyzer(cfg, []*RequestClass{{Name: "chat", Rate: 1, RequestSize: spread}}); err == nil {
+		t.Errorf("expected error for token distributions")
+	}
+	kv := *cfg
+	kv.KVCacheBlocks = 1600
+	if _, err := NewMultiClassAnalyzer(&kv, []*RequestClass{{Name: "chat", Rate: 1, RequestSize: rs}}); err == nil {
+		t.Errorf("expected error for a KV cache bound")
+	}
+}
+
+func TestMultiClassDispatchMatchesAnalyzer(t *testing.T) {
+	sp, rs := baselineParts()
+	for _, dispatch := range []queue.DispatchPolicy{queue.BalancedDispatch, queue.PackedDispatch} {
+		cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, NumReplicas: 4, Dispatch: dispatch, ServiceParms: sp}
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		rate := 0.5 * qa.RateRange.Max
+		want, err := qa.Analyze(rate)
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		mca, err := NewMultiClassAnalyzer(cfg, []*RequestClass{{Name: "chat", Rate: rate, RequestSize: rs}})
+		if err != nil {
+			t.Fatalf("NewMultiClassAnalyzer: %v", err)
+		}
+		got, err := mca.Analyze()
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		// class times are evaluated at the batch size of the dispatching rule
+		cm := got.Classes[0]
+		if math.Abs(float64(cm.AvgPrefillTime-want.AvgPrefillTime)) > 1e-3*float64(want.AvgPrefillTime) ||
+			math.Abs(float64(cm.AvgTokenTime-want.AvgTokenTime)) > 1e-3*float64(want.AvgTokenTime) {
+			t.Errorf("%s: got prefill %v, itl %v, want %v, %v", dispatch, cm.AvgPrefillTime, cm.AvgTokenTime,
+				want.AvgPrefillTime, want.AvgTokenTime)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// isotonicCurve is the non-decreasing least-squares fit of all oracle readings
+// over the probed caps (pool-adjacent-violators, weighted by readings per cap).
+type isotonicCurve struct {
+	caps   []int     // probed caps, increasing
+	fit    []float64 // fitted throughput at caps, non-decreasing
+	weight []int     // number of readings pooled into the fit at caps
+	sigma  float64   // std of the reading noise, from the residuals of the fit
+	dof    int       // degrees of freedom of sigma
+}
+
+// isotonicFit fits a non-decreasing curve to the readings at each cap. The
+// noise is estimated from the residuals over all readings, with one degree of
+// freedom per pooled block; sigma is zero if no reading is replicated.
+func isotonicFit(readings map[int][]float64) *isotonicCurve {
+	caps := make([]int, 0, len(readings))
+	for m := range readings {
+		caps = append(caps, m)
+	}
+	slices.Sort(caps)
+
+	// blocks of consecutive caps pooled to their weighted mean
+	type block struct {
+		sum   float64
+		n     int
+		first int // index into caps of the first cap in the block
+	}
+	blocks := make([]block, 0, len(caps))
+	for i, m := range caps {
+		b := block{first: i}
+		for _, y := range readings[m] {
+			b.sum += y
+			b.n++
+		}
+		blocks = append(blocks, b)
+		for k := len(blocks) - 1; k > 0; k-- {
+			prev, last := blocks[k-1], blocks[k]
+			if prev.sum*float64(last.n) <= last.sum*float64(prev.n) {
+				break
+			}
+			blocks[k-1] = block{sum: prev.sum + last.sum, n: prev.n + last.n, first: prev.first}
+			blocks = blocks[:k]
+		}
+	}
+
+	c := &isotonicCurve{
+		caps:   caps,
+		fit:    make([]float64, len(caps)),
+		weight: make([]int, len(caps)),
+	}
+	for k, b := range blocks {
+		end := len(caps)
+		if k+1 < len(blocks) {
+			end = blocks[k+1].first
+		}
+		for i := b.first; i < end; i++ {
+			c.fit[i] = b.sum / float64(b.n)
+			c.weight[i] = b.n
+		}
+	}
+
+	rss, n := 0.0, 0
+	for i, m := range caps {
+		for _, y := range readings[m] {
+			rss += (y - c.fit[i]) * (y - c.fit[i])
+			n++
+		}
+	}
+	if c.dof = n - len(blocks); c.dof > 0 {
+		c.sigma = math.Sqrt(rss / float64(c.dof))
+	}
+	return c
+}
+
+// at returns the fitted throughput at a probed cap m and its standard error.
+func (c *isotonicCurve) at(m int) (fit, se float64) {
+	i, found := slices.BinarySearch(c.caps, m)
+	if !found {
+		return 0, 0
+	}
+	return c.fit[i], c.sigma / math.Sqrt(float64(c.weight[i]))
+}
+
+// probAbove returns the probability that f(m) >= threshold at a probed cap m,
+// given the standard error seThr of the threshold, under normal noise around
+// the fit (a step function if both errors are zero).
+func (c *isotonicCurve) probAbove(m int, threshold, seThr float64) float64 {
+	fit, se := c.at(m)
+	se = math.Hypot(se, seThr)
+	if se == 0 {
+		if fit >= threshold {
+			return 1
+		}
+		return 0
+	}
+	return 0.5 * math.Erfc(-(fit-threshold)/se/math.Sqrt2)
+}
+
+// quantile returns the p-quantile of the Student t distribution with the
+// degrees of freedom of sigma, so that a noise estimate from few readings
+// widens the interval (the normal quantile without a noise estimate).
+func (c *isotonicCurve) quantile(p float64) float64 {
+	if c.dof <= 0 {
+		return math.Sqrt2 * math.Erfinv(2*p-1)
+	}
+	return utils.TQuantile(p, c.dof)
+}
+
+// findNoisy is the onset search for noisy, possibly non-monotone oracles. The
+// anchor f* is the mean of Repeats readings at m_max. Each bisection step
+// probes the midpoint, and probes it again (up to Repeats readings) while its
+// isotonic fit over all readings so far lies within the ConfidenceLevel
+// interval of the threshold, accounting for the error of both the fit and f*;
+// the direction follows the fit. M* is the smallest cap whose final fit
+// reaches the threshold, confirmed up to Repeats readings. Confidence is the
+// probability, under normal noise, that f(M*) reaches the threshold and f at
+// the next lower probed cap does not.
+func (o *ConcurrencyOptimizer) findNoisy(res *ConcurrencyResult, probe func(m int) float32) (*ConcurrencyResult, error) {
+	if o.ProbeBudget < 2*o.Repeats {
+		return nil, fmt.Errorf("probe budget %d below twice the repeats %d", o.ProbeBudget, o.Repeats)
+	}
+	readings := map[int][]float64{}
+	used := 0
+	read := func(m int) {
+		readings[m] = append(readings[m], float64(probe(m)))
+		used++
+	}
+	// probes kept for confirming M*
+	searchBudget := o.ProbeBudget - o.Repeats
+
+	seed := o.MMax
+	for range o.Repeats {
+		read(seed)
+	}
+	fstar := mean(readings[seed])
+	res.AnchorThroughput = float32(fstar)
+	if fstar <= 0 {
+		res.Concurrency = o.MMin
+		res.Feasible = false
+		return res, nil
+	}
+
+	threshold := float64(1-o.Epsilon/2) * fstar
+	// standard error of the threshold, through the mean of the anchor readings
+	seThreshold := func(c *isotonicCurve) float64 {
+		return float64(1-o.Epsilon/2) * c.sigma / math.Sqrt(float64(o.Repeats))
+	}
+	lo, hi := onsetBracket(o.MMin, o.MMax, res.MITL, res.MTPF)
+	for lo < hi && used < searchBudget {
+		mid := (lo + hi) / 2
+		read(mid)
+		curve := isotonicFit(readings)
+		for len(readings[mid]) < o.Repeats && used < searchBudget {
+			fit, se := curve.at(mid)
+			t := curve.quantile(float64(o.ConfidenceLevel))
+			if math.Abs(fit-threshold) >= t*math.Hypot(se, seThreshold(curve)) {
+				break
+			}
+			read(mid)
+			curve = isotonicFit(readings)
+		}
+		if fit, _ := curve.at(mid); fit >= threshold {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+
+	// Later readings may overturn earlier directions: pick M* from the final
+	// fit. An unprobed hi is the untested top of the bracket, trusted as in
+	// the exact search.
+	curve := isotonicFit(readings)
+	mStar := seed
+	if _, probed := readings[hi]; !probed {
+		mStar = hi
+	}
+	for i, m := range curve.caps {
+		if m < mStar && curve.fit[i] >= threshold {
+			mStar = m
+			break
+		}
+	}
+	mStar = max(o.MMin, min(o.MMax, mStar))
+	res.Concurrency = mStar
+
+	// Confirmatory readings at M*.
+	read(mStar)
+	for len(readings[mStar]) < o.Repeats && used < o.ProbeBudget {
+		read(mStar)
+	}
+	curve = isotonicFit(readings)
+	res.Throughput = float32(mean(readings[mStar]))
+	res.Feasible = res.Throughput > 0
+
+	confidence := curve.probAbove(mStar, threshold, seThreshold(curve))
+	if i, _ := slices.BinarySearch(curve.caps, mStar); i > 0 {
+		confidence *= 1 - curve.probAbove(curve.caps[i-1], threshold, seThreshold(curve))
+	}
+	res.Confidence = float32(confidence)
+
+	if res.Feasible && o.oracleIsDefault {
+		_, res.Metrics = o.sizeAt(mStar)
+	}
+	return res, nil
+}
+
+// mean of a non-empty slice
+func mean(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
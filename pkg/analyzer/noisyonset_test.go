@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestIsotonicFitPoolsViolators(t *testing.T) {
+	readings := map[int][]float64{1: {1}, 2: {3}, 3: {2}, 4: {4, 4}}
+	c := isotonicFit(readings)
+	want := []float64{1, 2.5, 2.5, 4}
+	for i, m := range []int{1, 2, 3, 4} {
+		if fit, _ := c.at(m); math.Abs(fit-want[i]) > 1e-9 {
+			t.Errorf("fit(%d) = %v, want %v", m, fit, want[i])
+		}
+	}
+	// residuals 0, 0.5, -0.5, 0, 0 over 5 readings in 3 blocks
+	if math.Abs(c.sigma-0.5) > 1e-9 {
+		t.Errorf("sigma = %v, want 0.5", c.sigma)
+	}
+}
+
+// plateauOracle rises linearly to 10 at onset, then stays flat, with normal
+// reading noise of the given std (rng may be nil without noise).
+func plateauOracle(onset int, noise float64, rng *rand.Rand) ConcurrencyOracle {
+	return func(m int) (float32, bool) {
+		f := 10 * float64(min(m, onset)) / float64(onset)
+		if noise > 0 {
+			f += noise * rng.NormFloat64()
+		}
+		return float32(f), true
+	}
+}
+
+func TestFindNoisyExactOracle(t *testing.T) {
+	sp, rs := baselineParts()
+	target := &TargetPerf{TargetTTFT: 60, TargetITL: 20} // bracket [40, 147]
+	exact := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs, Target: target,
+		Oracle: plateauOracle(60, 0, nil)}
+	want, err := exact.Find()
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	noisy := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs, Target: target,
+		Oracle: plateauOracle(60, 0, nil), Noisy: true}
+	got, err := noisy.Find()
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if got.Concurrency != want.Concurrency || got.Confidence != 1 || !got.Feasible {
+		t.Errorf("noisy search on an exact oracle: M*=%d confidence=%v, want M*=%d confidence=1",
+			got.Concurrency, got.Confidence, want.Concurrency)
+	}
+	// a single reading per cap decides the direction when there is no noise
+	if len(got.Probes) > DefaultNoisyRepeats+10 {
+		t.Errorf("used %d probes: %v", len(got.Probes), got.Probes)
+	}
+}
+
+func TestFindNoisyRobustToNoise(t *testing.T) {
+	sp, rs := baselineParts()
+	target := &TargetPerf{TargetTTFT: 60, TargetITL: 20}
+	rng := rand.New(rand.NewSource(1))
+	// noise std half the margin between the plateau (10) and the threshold
+	// (0.99 f* ~ 9.9, reached at m = 59.4)
+	missed := func(res *ConcurrencyResult) bool { return res.Concurrency < 56 || res.Concurrency > 64 }
+	exactMisses, noisyMisses := 0, 0
+	sumConfidence := float32(0)
+	for trial := range 100 {
+		exact := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs, Target: target,
+			Oracle: plateauOracle(60, 0.05, rng)}
+		res, err := exact.Find()
+		if err != nil {
+			t.Fatalf("Find: %v", err)
+		}
+		if missed(res) {
+			exactMisses++
+		}
+
+		noisy := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs, Target: target,
+			Oracle: plateauOracle(60, 0.05, rng), Noisy: true}
+		if res, err = noisy.Find(); err != nil {
+			t.Fatalf("Find: %v", err)
+		}
+		if missed(res) {
+			noisyMisses++
+		}
+		if len(res.Probes) > DefaultNoisyProbeBudget {
+			t.Errorf("trial %d: %d probes exceed the budget", trial, len(res.Probes))
+		}
+		if res.Confidence < 0 || res.Confidence > 1 {
+			t.Errorf("trial %d: confidence %v out of [0, 1]", trial, res.Confidence)
+		}
+		sumConfidence += res.Confidence
+	}
+	if noisyMisses > 5 || 3*noisyMisses > exactMisses {
+		t.Errorf("missed the onset in %d noisy and %d exact searches of 100", noisyMisses, exactMisses)
+	}
+	if sumConfidence < 50 {
+		t.Errorf("mean confidence %v", sumConfidence/100)
+	}
+}
+
+func TestFindNoisyRejectsSmallBudget(t *testing.T) {
+	sp, rs := baselineParts()
+	o := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target: &TargetPerf{TargetTTFT: 60, TargetITL: 20},
+		Oracle: plateauOracle(60, 0, nil), Noisy: true, ProbeBudget: 5, Repeats: 3}
+	if _, err := o.Find(); err == nil {
+		t.Error("expected error for a budget below twice the repeats")
+	}
+	o = &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target: &TargetPerf{TargetTTFT: 60, TargetITL: 20}, Noisy: true, ConfidenceLevel: 1}
+	if _, err := o.Find(); err == nil {
+		t.Error("expected error for confidence level 1")
+	}
+}
@@ -0,0 +1,136 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// analysis solution metrics of priority classes sharing the server
+type PriorityMetrics struct {
+	Scheduling queue.PriorityScheduling // scheduling discipline among priorities
+	Aggregate  *AnalysisMetrics         // metrics of all classes together (as if FCFS)
+	Classes    []*ClassMetrics          // metrics of each priority class, highest priority first
+}
+
+// evaluate performance metrics given the request rate of each priority class (requests/sec),
+// highest priority first.
+// The iteration times are those of the aggregate mean-field batch; under preemptive-resume
+// scheduling, the prefill and decode times of a class are stretched by the ratio of its
+// service time (including preemptions) to the aggregate service time.
+func (qa *LLMQueueAnalyzer) AnalyzePriority(rates []float32, scheduling queue.PriorityScheduling) (metrics *PriorityMetrics, err error) {
+	var requestRate float32
+	lambdas := make([]float32, len(rates))
+	for k, r := range rates {
+		if r < 0 {
+			return nil, fmt.Errorf("invalid request rate %v of priority %d", r, k)
+		}
+		requestRate += r
+		lambdas[k] = r / 1000
+	}
+	aggregate, err := qa.Analyze(requestRate)
+	if err != nil {
+		return nil, err
+	}
+	model := queue.NewPriorityModel(qa.Model.K, qa.Model.GetServRate(), scheduling)
+	if err = model.SolveClasses(lambdas); err != nil {
+		return nil, err
+	}
+
+	avgServTime := qa.Model.GetAvgServTime()
+	metrics = &PriorityMetrics{
+		Scheduling: scheduling,
+		Aggregate:  aggregate,
+		Classes:    make([]*ClassMetrics, len(rates)),
+	}
+	for k, r := range rates {
+		stretch := float32(1)
+		if avgServTime > 0 && model.GetClassThroughput(k) > 0 {
+			stretch = model.GetClassServTime(k) / avgServTime
+		}
+		waitTime := model.GetClassWaitTime(k)
+		prefillTime := stretch * aggregate.AvgPrefillTime
+		tokenTime := stretch * aggregate.AvgTokenTime
+		metrics.Classes[k] = &ClassMetrics{
+			Name:           fmt.Sprintf("priority%d", k),
+			OfferedRate:    r,
+			Throughput:     model.GetClassThroughput(k) * 1000,
+			AvgRespTime:    model.GetClassRespTime(k),
+			AvgWaitTime:    waitTime,
+			AvgPrefillTime: prefillTime,
+			AvgTokenTime:   tokenTime,
+			AvgTTFT:        waitTime + prefillTime + tokenTime,
+		}
+	}
+	return metrics, nil
+}
+
+// evaluate the max request rate of the lowest priority (batch) class such that all higher
+// priority (interactive) classes, at the given rates, meet the TTFT and ITL targets.
+// The rate of the last class in rates is ignored.
+func (qa *LLMQueueAnalyzer) SizePriority(rates []float32, scheduling queue.PriorityScheduling,
+	targetPerf *TargetPerf) (batchRate float32, metrics *PriorityMetrics, err error) {
+	if len(rates) < 2 {
+		return 0, nil, fmt.Errorf("need at least two priority classes, got %d", len(rates))
+	}
+	if err := targetPerf.check(); err != nil {
+		return 0, nil, err
+	}
+	last := len(rates) - 1
+	var interactiveRate float32
+	for _, r := range rates[:last] {
+		interactiveRate += r
+	}
+	rateMin := qa.RateRange.Min
+	rateMax := qa.RateRange.Max - interactiveRate
+	if rateMax <= rateMin {
+		return 0, nil, fmt.Errorf("interactive rate %v leaves no capacity, range=%s", interactiveRate, qa.RateRange)
+	}
+
+	// worst ratio of metric to target over the interactive classes
+	withBatch := append([]float32{}, rates...)
+	eval := func(x float32) (float32, error) {
+		withBatch[last] = x
+		pm, err := qa.AnalyzePriority(withBatch, scheduling)
+		if err != nil {
+			return 0, err
+		}
+		var ratio float32
+		for _, cm := range pm.Classes[:last] {
+			if targetPerf.TargetTTFT > 0 {
+				ratio = max(ratio, cm.AvgTTFT/targetPerf.TargetTTFT)
+			}
+			if targetPerf.TargetITL > 0 {
+				ratio = max(ratio, cm.AvgTokenTime/targetPerf.TargetITL)
+			}
+		}
+		return ratio, nil
+	}
+
+	// the interactive classes may be insensitive to the batch class (preemption), so check the bounds first
+	batchRate = rateMax
+	ratio, err := eval(rateMax)
+	if err != nil {
+		return 0, nil, err
+	}
+	if ratio > 1 {
+		if ratio, err = eval(rateMin); err != nil {
+			return 0, nil, err
+		}
+		if ratio > 1 {
+			return 0, nil, fmt.Errorf("interactive classes miss targets %s at min batch rate %v", targetPerf, rateMin)
+		}
+		var ind int
+		batchRate, ind, err = utils.BinarySearch(rateMin, rateMax, 1, eval)
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to calculate batch rate, target=%s, ind=%d, err=%v", targetPerf, ind, err)
+		}
+	}
+
+	withBatch[last] = batchRate
+	if metrics, err = qa.AnalyzePriority(withBatch, scheduling); err != nil {
+		return 0, nil, err
+	}
+	return batchRate, metrics, nil
+}
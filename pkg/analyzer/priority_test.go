@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+func TestAnalyzePriorityFavorsInteractive(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	total := 0.9 * qa.RateRange.Max
+	rates := []float32{total / 3, 2 * total / 3}
+	fcfs, err := qa.Analyze(total)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	for _, sched := range []queue.PriorityScheduling{queue.NonPreemptive, queue.PreemptiveResume} {
+		pm, err := qa.AnalyzePriority(rates, sched)
+		if err != nil {
+			t.Fatalf("AnalyzePriority(%s): %v", sched, err)
+		}
+		high, low := pm.Classes[0], pm.Classes[1]
+		if high.AvgTTFT >= fcfs.AvgTTFT || low.AvgTTFT <= fcfs.AvgTTFT {
+			t.Errorf("%s: TTFT high %v, low %v, want around FCFS %v", sched, high.AvgTTFT, low.AvgTTFT, fcfs.AvgTTFT)
+		}
+	}
+}
+
+func TestSizePriorityKeepsInteractiveWithinTarget(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	interactive := 0.3 * qa.RateRange.Max
+	target := &TargetPerf{TargetTTFT: 100}
+	rate, pm, err := qa.SizePriority([]float32{interactive, 0}, queue.NonPreemptive, target)
+	if err != nil {
+		t.Fatalf("SizePriority: %v", err)
+	}
+	if rate <= 0 || rate > qa.RateRange.Max-interactive {
+		t.Errorf("batch rate %v out of range", rate)
+	}
+	if math.Abs(float64(pm.Classes[0].AvgTTFT-target.TargetTTFT)) > 0.01*float64(target.TargetTTFT) {
+		t.Errorf("interactive TTFT %v, want binding target %v", pm.Classes[0].AvgTTFT, target.TargetTTFT)
+	}
+	if pm.Classes[1].OfferedRate != rate {
+		t.Errorf("batch class rate %v, want %v", pm.Classes[1].OfferedRate, rate)
+	}
+
+	// preempted batch requests do not delay interactive ones, so capacity is the only limit
+	rate, _, err = qa.SizePriority([]float32{interactive, 0}, queue.PreemptiveResume, target)
+	if err != nil {
+		t.Fatalf("SizePriority: %v", err)
+	}
+	if rate != qa.RateRange.Max-interactive {
+		t.Errorf("preemptive batch rate %v, want remaining capacity %v", rate, qa.RateRange.Max-interactive)
+	}
+}
@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/llm-inferno/queue-analysis/pkg/queue"
 	"github.com/llm-inferno/queue-analysis/pkg/utils"
@@ -11,29 +12,83 @@ import (
 const Epsilon = float32(0.001)
 
 // fraction of maximum server throughput to provide stability (running this fraction below the maximum)
+//
+// Deprecated: Size no longer caps the rate for a TPS target below the maximum; the aggregate TPS
+// target is sized as a lower bound on the request rate.
 const StabilitySafetyFraction = float32(0.1)
 
 // maximum number of tokens per batch (iteration)
 const DefaultMaxNumTokens = 8192
 
+// percentile level of reported percentile metrics, unless otherwise specified
+const DefaultPercentile = float32(0.9)
+
+// limits on the fixed-point iteration of the arrival rate with retries
+const (
+	maxRetryIterations = 100
+	retryTolerance     = float32(1e-5)
+)
+
 // Analyzer of inference server queue
 type LLMQueueAnalyzer struct {
-	MaxBatchSize int                           // maximum batch size
-	MaxNumTokens int                           // maximum number of tokens per batch
-	MaxQueueSize int                           // maximum queue size
-	ServiceParms *ServiceParms                 // request processing parameters
-	RequestSize  *RequestSize                  // number of input and output tokens per request
-	Model        *queue.MM1ModelStateDependent // queueing model
-	RateRange    *RateRange                    // range of request rates for model stability
-	NumChunks    []int                         // NumChunks[B] = number of prefill chunks at batch size B
+	MaxBatchSize   int                           // maximum batch size
+	MaxNumTokens   int                           // maximum number of tokens per batch
+	MaxQueueSize   int                           // maximum queue size
+	NumReplicas    int                           // number of servers (replicas) sharing the queue
+	ServiceParms   *ServiceParms                 // request processing parameters
+	RequestSize    *RequestSize                  // number of input and output tokens per request
+	Model          *queue.MM1ModelStateDependent // queueing model
+	RateRange      *RateRange                    // range of request rates for model stability
+	NumChunks      []int                         // NumChunks[B] = number of prefill chunks at batch size B
+	Percentile     float32                       // percentile level of reported percentile metrics (0 < Percentile < 1)
+	Abandonment    *Abandonment                  // client abandonment and retries (nil => none)
+	Dispatch       queue.DispatchPolicy          // dispatching of requests in service to replicas (NumReplicas > 1)
+	GeneralService bool                          // general (non-exponential) service time correction of the waiting time
+	MetricsMode    MetricsMode                   // evaluation of prefill and decode times from the batch size
+	ServiceSCV     []float32                     // ServiceSCV[B] = squared coefficient of variation of the service time at batch size B
+	KVBatchLimit   int                           // batch limit of the KV cache, replacing a larger configured max batch size (0 => none)
+
+	kvCache  *kvCache      // KV cache capacity model (nil => unbounded KV cache)
+	tokens   *tokenProfile // service quantities integrated over the token distributions (nil => averages)
+	servRate []float32     // per-replica service rate at batch size B (servRate[B-1], req/msec)
 }
 
+// evaluation of the prefill and decode times from the batch size of the solved model
+type MetricsMode int
+
+const (
+	// prefill and decode times at the mean in-service batch size
+	MeanFieldMetrics MetricsMode = iota
+	// prefill and decode times averaged over the stationary batch size distribution,
+	// weighted by the number of requests in the batch
+	ExactMetrics
+)
+
 // queue configuration parameters
 type Configuration struct {
-	MaxBatchSize int           // maximum batch size (limit on the number of requests concurrently receiving service >0)
-	MaxNumTokens int           // maximum number of tokens per batch (limit on the number of tokens per batch >0)
-	MaxQueueSize int           // maximum queue size (limit on the number of requests queued for servive >=0)
-	ServiceParms *ServiceParms // request processing parameters
+	MaxBatchSize   int                  // maximum batch size (limit on the number of requests concurrently receiving service >0)
+	MaxNumTokens   int                  // maximum number of tokens per batch (limit on the number of tokens per batch >0)
+	MaxQueueSize   int                  // maximum queue size (limit on the number of requests queued for servive >=0)
+	ServiceParms   *ServiceParms        // request processing parameters
+	NumReplicas    int                  // number of servers (replicas) behind a shared queue (0 => 1)
+	Dispatch       queue.DispatchPolicy // dispatching of requests in service to replicas (NumReplicas > 1)
+	Abandonment    *Abandonment         // client abandonment and retries (nil => none)
+	KVCacheTokens  int                  // KV cache capacity per replica (tokens, 0 => KVCacheBlocks)
+	KVCacheBlocks  int                  // KV cache capacity per replica (blocks, 0 => unbounded KV cache)
+	KVBlockSize    int                  // number of tokens per KV cache block (0 => DefaultKVBlockSize)
+	GeneralService bool                 // general (non-exponential) service time correction of the waiting time
+	ServiceSCV     float32              // squared coefficient of variation of the service time (0 => from the token distributions, or 1)
+
+	noPrefill bool // requests arrive with the KV cache of their input: no prefill iterations (disaggregated decode pool)
+}
+
+// client abandonment: a client gives up on a request whose first token has not arrived within its
+// patience, and retries it with some probability. Requests abandoning in the queue cost no work;
+// requests abandoning during prefill waste the prefill work done so far.
+type Abandonment struct {
+	Patience     queue.PatienceKind // patience distribution
+	PatienceTime float32            // mean (exponential) or fixed (deterministic) patience (msec)
+	RetryProb    float32            // probability that an abandoned request is retried (0 <= RetryProb < 1)
 }
 
 // request processing parameters:
@@ -44,10 +99,12 @@ type ServiceParms struct {
 	Gamma float32 // slope for memory access time
 }
 
-// request tokens data
+// request tokens data; with token distributions, the averages are set to their means
 type RequestSize struct {
-	AvgInputTokens  float32 // average number of input tokens per request
-	AvgOutputTokens float32 // average number of output tokens per request
+	AvgInputTokens  float32            // average number of input tokens per request
+	AvgOutputTokens float32            // average number of output tokens per request
+	InputTokens     *TokenDistribution // distribution of the number of input tokens (nil => AvgInputTokens)
+	OutputTokens    *TokenDistribution // distribution of the number of output tokens (nil => AvgOutputTokens)
 }
 
 // range of request rates (requests/sec)
@@ -58,30 +115,53 @@ type RateRange struct {
 
 // analysis solution metrics data
 type AnalysisMetrics struct {
-	OfferedRate    float32 // offered arrival rate (requests/sec); equals Throughput when not overloaded
-	Throughput     float32 // effective throughput / goodput (requests/sec)
-	AvgRespTime    float32 // average request response time (aka latency) (msec)
-	AvgWaitTime    float32 // average request queueing time (msec)
-	AvgNumInServ   float32 // average number of requests in service
-	AvgPrefillTime float32 // average request prefill time (msec)
-	AvgTokenTime   float32 // average token decode time (msec)
-	AvgTTFT        float32 // average time to first token (msec)
-	MaxRate        float32 // maximum throughput (requests/sec)
-	Rho            float32 // utilization
+	OfferedRate     float32 // offered arrival rate (requests/sec); equals Throughput when not overloaded
+	Throughput      float32 // effective throughput / goodput (requests/sec)
+	AvgRespTime     float32 // average request response time (aka latency) (msec)
+	AvgWaitTime     float32 // average request queueing time (msec)
+	AvgNumInServ    float32 // average number of requests in service
+	AvgPrefillTime  float32 // average request prefill time (msec)
+	AvgTokenTime    float32 // average token decode time (msec)
+	AvgTTFT         float32 // average time to first token (msec)
+	MaxRate         float32 // maximum throughput (requests/sec)
+	Rho             float32 // utilization
+	Percentile      float32 // percentile level of the percentile metrics below (e.g. 0.9)
+	PctWaitTime     float32 // percentile request queueing time (msec)
+	PctTTFT         float32 // percentile time to first token (msec)
+	PctRespTime     float32 // percentile request response time (msec)
+	MeanFieldTTFT   float32 // average time to first token, prefill and decode at the mean batch size (msec)
+	MeanFieldITL    float32 // average token decode time, at the mean batch size (msec)
+	ExactTTFT       float32 // average time to first token, over the batch size distribution (msec)
+	ExactITL        float32 // average token decode time, over the batch size distribution (msec)
+	RetryRate       float32 // rate of retried requests, included in the arrivals to the server (requests/sec)
+	AbandonRate     float32 // rate of admitted requests abandoned before their first token (requests/sec)
+	AbandonFraction float32 // fraction of admitted requests abandoned before their first token
+	WastedPrefill   float32 // prefill work spent on requests abandoned during prefill (input tokens/sec)
+	Goodput         float32 // rate of requests served without abandonment (requests/sec)
+	PreemptionRate  float32 // rate of preemptions of requests in service on KV cache overflow (preemptions/sec)
 }
 
 // queue performance targets
 type TargetPerf struct {
-	TargetTTFT float32 // target time to first token (queueing + prefill) (msec)
-	TargetITL  float32 // target inter-token latency (msec)
-	TargetTPS  float32 // target token generation throughtput (tokens/sec)
+	TargetTTFT         float32 // target time to first token (queueing + prefill) (msec)
+	TargetITL          float32 // target inter-token latency (msec)
+	TargetTPS          float32 // target token generation throughtput, aggregate over all requests (tokens/sec)
+	TargetUserTPS      float32 // target per-user decode speed, i.e. 1000/ITL (tokens/sec)
+	Percentile         float32 // percentile level of percentile targets (0 => DefaultPercentile)
+	TargetPctTTFT      float32 // target percentile time to first token (msec)
+	TargetPctRespTime  float32 // target percentile response time (msec)
+	MaxAbandonFraction float32 // max fraction of admitted requests abandoned before their first token
 }
 
 // queue max request rates to achieve performance targets
 type TargetRate struct {
-	RateTargetTTFT float32 // max request rate for target TTFT (requests/sec)
-	RateTargetITL  float32 // max request rate for target ITL (requests/sec)
-	RateTargetTPS  float32 // max request rate for target TPS (requests/sec)
+	RateTargetTTFT        float32 // max request rate for target TTFT (requests/sec)
+	RateTargetITL         float32 // max request rate for target ITL (requests/sec)
+	RateTargetTPS         float32 // min request rate for target TPS (requests/sec)
+	RateTargetUserTPS     float32 // max request rate for target per-user TPS (requests/sec)
+	RateTargetPctTTFT     float32 // max request rate for target percentile TTFT (requests/sec)
+	RateTargetPctRespTime float32 // max request rate for target percentile response time (requests/sec)
+	RateTargetAbandon     float32 // max request rate for max abandonment fraction (requests/sec)
 }
 
 // create a new queue analyzer from config
@@ -104,41 +184,128 @@ func NewLLMQueueAnalyzer(qConfig *Configuration, requestSize *RequestSize) (*LLM
 // analysis had tau(B) = prefill_old(B) + m*decode_old(B) which expands to
 // (c+m)*(alpha + (B+1)*delta), double-counting the focal request's own work;
 // removing that double count is the defining change of the new analysis.
+//
+// With NumReplicas > 1, the replicas share one queue and the total service rate
+// at pool occupancy n sums the per-replica rates servRate[b] at the batch sizes
+// b assigned by the dispatching rule (M/M/c/K with state-dependent rates).
+//
+// With a bounded KV cache, the batch limit is also bounded by the number of requests
+// whose average context fits in the cache (reported as KVBatchLimit when it lowers
+// MaxBatchSize), and the recompute work of the requests preempted on cache overflow
+// is added to tau(B), and to the iterations of the prefill and decode times.
+//
+// With token distributions, tau(B) and the prefill and decode times integrate the
+// work decomposition over the input and output tokens (see tokenProfile), and the
+// second moment of the service time gives ServiceSCV[B].
+//
+// With general service, the waiting time of the state-dependent chain is corrected
+// for the service time SCV, either given or, by default, that at the full batch,
+// where requests wait (M/G/1-type two-moment approximation); without token
+// distributions, the default SCV is 1 (exponential service, no correction).
 func BuildModel(c *Configuration, r *RequestSize) (modelData *LLMQueueAnalyzer) {
 	parms := c.ServiceParms
 
+	kv := newKVCache(c, r)
+	kvBatchLimit := 0
+	if kv != nil && kv.batchLimit() < c.MaxBatchSize {
+		kvBatchLimit = kv.batchLimit()
+		kvConfig := *c
+		kvConfig.MaxBatchSize = kvBatchLimit
+		c = &kvConfig
+	}
+
 	numChunks := NumIterationsPerPrefill(c, r)
+	tokens := newTokenProfile(c, r)
+	serviceSCV := make([]float32, c.MaxBatchSize+1)
 
 	servRate := make([]float32, c.MaxBatchSize)
 	for B := 1; B <= c.MaxBatchSize; B++ {
 		nc := numChunks[B]
 		tau := tauNew(parms, r, B, nc)
+		if tokens != nil {
+			tau = tokens.tau(parms, B)
+			serviceSCV[B] = tokens.scv[B]
+		}
+		if kv != nil {
+			tau += (float32(nc) + r.AvgOutputTokens) * kv.recomputePerIteration(float32(B))
+		}
 		servRate[B-1] = float32(B) / tau
 	}
 
-	// set and check limits
-	lambdaMin := servRate[0] * Epsilon
-	lambdaMax := servRate[c.MaxBatchSize-1] * (1 - Epsilon)
-	rateRange := &RateRange{Min: lambdaMin * 1000, Max: lambdaMax * 1000}
-
-	// create and solve model
-	occupancyUpperBound := c.MaxQueueSize + c.MaxBatchSize
-	model := queue.NewMM1ModelStateDependent(occupancyUpperBound, servRate)
+	// set limits, create model
+	scv := c.ServiceSCV
+	if scv == 0 {
+		scv = serviceSCV[c.MaxBatchSize]
+	}
+	rateRange := newRateRange(c, servRate)
+	model := newQueueModel(c, servRate, scv)
+	numReplicas := max(c.NumReplicas, 1)
 
 	return &LLMQueueAnalyzer{
-		MaxBatchSize: c.MaxBatchSize,
-		MaxNumTokens: c.MaxNumTokens,
-		MaxQueueSize: c.MaxQueueSize,
-		ServiceParms: parms,
-		RequestSize:  r,
-		Model:        model,
-		RateRange:    rateRange,
-		NumChunks:    numChunks,
+		MaxBatchSize:   c.MaxBatchSize,
+		MaxNumTokens:   c.MaxNumTokens,
+		MaxQueueSize:   c.MaxQueueSize,
+		NumReplicas:    numReplicas,
+		ServiceParms:   parms,
+		RequestSize:    r,
+		Model:          model,
+		RateRange:      rateRange,
+		NumChunks:      numChunks,
+		Percentile:     DefaultPercentile,
+		Abandonment:    c.Abandonment,
+		Dispatch:       c.Dispatch,
+		GeneralService: c.GeneralService,
+		ServiceSCV:     serviceSCV,
+		KVBatchLimit:   kvBatchLimit,
+		kvCache:        kv,
+		tokens:         tokens,
+		servRate:       servRate,
 	}
 }
 
+// range of request rates of a queue with given per-replica service rates
+// (the maximum rate is that of all replicas at full batch)
+func newRateRange(c *Configuration, servRate []float32) *RateRange {
+	numReplicas := max(c.NumReplicas, 1)
+	lambdaMin := servRate[0] * Epsilon
+	lambdaMax := float32(numReplicas) * servRate[len(servRate)-1] * (1 - Epsilon)
+	return &RateRange{Min: lambdaMin * 1000, Max: lambdaMax * 1000}
+}
+
+// queueing model of the replicas sharing the queue, given per-replica service rates
+// and the service time SCV of the general-service correction
+func newQueueModel(c *Configuration, servRate []float32, scv float32) *queue.MM1ModelStateDependent {
+	numReplicas := max(c.NumReplicas, 1)
+	occupancyUpperBound := c.MaxQueueSize + numReplicas*c.MaxBatchSize
+	if c.GeneralService {
+		if scv == 0 {
+			// unknown service time SCV: that of exponential service
+			scv = 1
+		}
+		if numReplicas > 1 {
+			servRate = queue.PoolServiceRates(servRate, numReplicas, c.Dispatch)
+		}
+		return queue.NewMG1ModelStateDependent(occupancyUpperBound, servRate, scv).MM1ModelStateDependent
+	}
+	if a := c.Abandonment; a != nil {
+		if numReplicas > 1 {
+			servRate = queue.PoolServiceRates(servRate, numReplicas, c.Dispatch)
+		}
+		return queue.NewMM1ModelAbandonment(occupancyUpperBound, servRate, a.Patience, a.PatienceTime).MM1ModelStateDependent
+	}
+	if numReplicas > 1 {
+		return queue.NewMMCKModelStateDependent(occupancyUpperBound, servRate, numReplicas, c.Dispatch).MM1ModelStateDependent
+	}
+	return queue.NewMM1ModelStateDependent(occupancyUpperBound, servRate)
+}
+
 // evaluate performance metrics given request rate
 func (qa *LLMQueueAnalyzer) Analyze(requestRate float32) (metrics *AnalysisMetrics, err error) {
+	return qa.analyze(requestRate, qa.Percentile)
+}
+
+// evaluate performance metrics given request rate, reporting percentile metrics at a given level
+func (qa *LLMQueueAnalyzer) analyze(requestRate float32, percentile float32) (metrics *AnalysisMetrics, err error) {
 	if requestRate <= 0 {
 		return nil, fmt.Errorf("invalid request rate %v", requestRate)
 	}
@@ -147,22 +314,27 @@ func (qa *LLMQueueAnalyzer) Analyze(requestRate float32) (metrics *AnalysisMetri
 	// No upper-bound guard: the finite-K birth-death model handles any arrival rate.
 	// Excess load increases blocking probability p[K]; throughput saturates naturally.
 	// Callers can detect overload via: metrics.OfferedRate > metrics.Throughput
-	model.Solve(requestRate/1000, 1)
-	if !model.IsValid() {
-		err = fmt.Errorf("invalid model %s", model)
+	data := qa.evalFuncData()
+	if err = data.solve(requestRate / 1000); err != nil {
 		return nil, err
 	}
 
-	// mean-field at the in-service mean batch size X
+	// mean-field at the in-service mean batch size X (per replica), or exact over the batch size distribution
 	avgNumInServ := model.GetAvgNumInServers()
-	nc := qa.numChunksAt(avgNumInServ)
-	avgPrefillTime := prefillNew(qa.ServiceParms, qa.RequestSize, avgNumInServ, nc)
-	avgDecodeTime := (model.GetAvgServTime() - avgPrefillTime) / qa.RequestSize.AvgOutputTokens
+	avgPrefillTime, avgDecodeTime := data.meanFieldTimes()
+	meanFieldPrefillTime, meanFieldDecodeTime := data.meanBatchTimes()
+	exactPrefillTime, exactDecodeTime := data.exactTimes()
+	if qa.MetricsMode == ExactMetrics {
+		avgPrefillTime, avgDecodeTime = exactPrefillTime, exactDecodeTime
+	}
 	avgTTFT := model.GetAvgWaitTime() + avgPrefillTime + avgDecodeTime
 
-	rho := avgNumInServ / float32(qa.MaxBatchSize)
+	rho := avgNumInServ / float32(qa.NumReplicas*qa.MaxBatchSize)
 	rho = min(max(rho, 0), 1)
 
+	// waiting time is the only random component of the percentile metrics
+	pctWaitTime := model.GetWaitTimePercentile(percentile)
+
 	// return solution
 	metrics = &AnalysisMetrics{
 		OfferedRate:    requestRate,
@@ -175,6 +347,29 @@ func (qa *LLMQueueAnalyzer) Analyze(requestRate float32) (metrics *AnalysisMetri
 		AvgTTFT:        avgTTFT,
 		MaxRate:        qa.RateRange.Max,
 		Rho:            rho,
+		Percentile:     percentile,
+		PctWaitTime:    pctWaitTime,
+		PctTTFT:        pctWaitTime + avgPrefillTime + avgDecodeTime,
+		PctRespTime:    pctWaitTime + model.GetAvgServTime(),
+		MeanFieldTTFT:  model.GetAvgWaitTime() + meanFieldPrefillTime + meanFieldDecodeTime,
+		MeanFieldITL:   meanFieldDecodeTime,
+		ExactTTFT:      model.GetAvgWaitTime() + exactPrefillTime + exactDecodeTime,
+		ExactITL:       exactDecodeTime,
+		Goodput:        model.GetThroughput() * 1000,
+	}
+	if qa.kvCache != nil {
+		batchSize := meanFieldBatchSize(avgNumInServ, qa.NumReplicas, qa.MaxBatchSize, qa.Dispatch)
+		metrics.PreemptionRate = metrics.Throughput *
+			qa.kvCache.preemptionsPerRequest(qa.RequestSize, batchSize, qa.numChunksAt(batchSize))
+	}
+	if qa.Abandonment != nil {
+		queueAbandonRate, prefillAbandonRate := data.abandonRates()
+		abandonRate := (queueAbandonRate + prefillAbandonRate) * 1000
+		metrics.RetryRate = model.GetLambda()*1000 - requestRate
+		metrics.AbandonRate = abandonRate
+		metrics.AbandonFraction = data.abandonFraction()
+		metrics.WastedPrefill = prefillAbandonRate * 1000 * qa.RequestSize.AvgInputTokens / 2
+		metrics.Goodput -= prefillAbandonRate * 1000
 	}
 	return metrics, nil
 }
@@ -192,6 +387,12 @@ type EvalFuncData struct {
 	serviceParms *ServiceParms                 // request processing parameters for prefill and decode stages
 	maxBatchSize int                           // max batch size
 	numChunks    []int                         // NumChunks[B] for B = 1..maxBatchSize
+	numReplicas  int                           // number of replicas sharing the in-service requests
+	abandonment  *Abandonment                  // client abandonment and retries (nil => none)
+	dispatch     queue.DispatchPolicy          // dispatching of requests in service to replicas
+	metricsMode  MetricsMode                   // evaluation of prefill and decode times
+	tokens       *tokenProfile                 // service quantities integrated over the token distributions (nil => averages)
+	kvCache      *kvCache                      // KV cache capacity model (nil => unbounded KV cache)
 }
 
 // evaluate max request rates to achieve a given target performance
@@ -202,75 +403,140 @@ func (qa *LLMQueueAnalyzer) Size(targetPerf *TargetPerf) (targetRate *TargetRate
 	targetTTFT := targetPerf.TargetTTFT
 	targetITL := targetPerf.TargetITL
 	targetTPS := targetPerf.TargetTPS
+	targetUserTPS := targetPerf.TargetUserTPS
+	targetPctTTFT := targetPerf.TargetPctTTFT
+	targetPctRespTime := targetPerf.TargetPctRespTime
+	percentile := targetPerf.Percentile
+	if percentile == 0 {
+		percentile = qa.Percentile
+	}
 
 	lambdaMin := qa.RateRange.Min / 1000
 	lambdaMax := qa.RateRange.Max / 1000
 
-	var ind int
-
-	lambdaStarTTFT := lambdaMax
-	if targetTTFT > 0 {
-		evalTTF := EvalTTFT(&EvalFuncData{
-			model:        qa.Model,
-			requestSize:  qa.RequestSize,
-			serviceParms: qa.ServiceParms,
-			maxBatchSize: qa.MaxBatchSize,
-			numChunks:    qa.NumChunks,
-		})
-		lambdaStarTTFT, ind, err = utils.BinarySearch(lambdaMin, lambdaMax, targetTTFT, evalTTF)
+	// find max lambda such that eval(lambda) meets the target value
+	search := func(name string, target float32, eval func(x float32) (float32, error)) (float32, error) {
+		lambdaStar, ind, err := utils.BinarySearch(lambdaMin, lambdaMax, target, eval)
 		if ind < 0 {
 			err = fmt.Errorf("target is below the bounded region")
 		}
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to calculate lambdaStarTTFT, targetTTFT=%v, range=%s, ind=%d, err=%v",
-				targetTTFT, qa.RateRange, ind, err)
+			return 0, fmt.Errorf("failed to calculate lambdaStar%s, target%s=%v, range=%s, ind=%d, err=%v",
+				name, name, target, qa.RateRange, ind, err)
+		}
+		return lambdaStar, nil
+	}
+
+	lambdaStarTTFT := lambdaMax
+	if targetTTFT > 0 {
+		if lambdaStarTTFT, err = search("TTFT", targetTTFT, EvalTTFT(qa.evalFuncData())); err != nil {
+			return nil, nil, nil, err
 		}
 	}
 
 	lambdaStarITL := lambdaMax
 	if targetITL > 0 {
-		evalITL := EvalITL(&EvalFuncData{
-			model:        qa.Model,
-			requestSize:  qa.RequestSize,
-			serviceParms: qa.ServiceParms,
-			maxBatchSize: qa.MaxBatchSize,
-			numChunks:    qa.NumChunks,
-		})
-		lambdaStarITL, ind, err = utils.BinarySearch(lambdaMin, lambdaMax, targetITL, evalITL)
-		if ind < 0 {
-			err = fmt.Errorf("target is below the bounded region")
+		if lambdaStarITL, err = search("ITL", targetITL, EvalITL(qa.evalFuncData())); err != nil {
+			return nil, nil, nil, err
 		}
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to calculate lambdaStarITL, targetITL=%v, range=%s, ind=%d, err=%v",
-				targetITL, qa.RateRange, ind, err)
+	}
+
+	lambdaStarUserTPS := lambdaMax
+	if targetUserTPS > 0 {
+		if lambdaStarUserTPS, err = search("UserTPS", targetUserTPS, EvalUserTPS(qa.evalFuncData())); err != nil {
+			return nil, nil, nil, err
 		}
 	}
 
-	lambdaStarTPS := lambdaMax
-	if targetTPS > 0 {
-		lambdaStarTPS = lambdaMax * (1 - StabilitySafetyFraction)
+	lambdaStarPctTTFT := lambdaMax
+	if targetPctTTFT > 0 {
+		if lambdaStarPctTTFT, err = search("PctTTFT", targetPctTTFT,
+			EvalPctTTFT(qa.evalFuncData(), percentile)); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	lambdaStarPctRespTime := lambdaMax
+	if targetPctRespTime > 0 {
+		if lambdaStarPctRespTime, err = search("PctRespTime", targetPctRespTime,
+			EvalPctRespTime(qa.evalFuncData(), percentile)); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	lambdaStarAbandon := lambdaMax
+	if targetPerf.MaxAbandonFraction > 0 {
+		if lambdaStarAbandon, err = search("AbandonFraction", targetPerf.MaxAbandonFraction,
+			EvalAbandonFraction(qa.evalFuncData())); err != nil {
+			return nil, nil, nil, err
+		}
 	}
 
-	lambda := min(lambdaStarTTFT, lambdaStarITL, lambdaStarTPS)
+	lambda := min(lambdaStarTTFT, lambdaStarITL, lambdaStarUserTPS, lambdaStarPctTTFT, lambdaStarPctRespTime,
+		lambdaStarAbandon)
+
+	// aggregate TPS increases with the request rate, hence it is a lower bound on lambda
+	lambdaStarTPS := lambdaMin
+	if targetTPS > 0 {
+		var ind int
+		lambdaStarTPS, ind, err = utils.BinarySearch(lambdaMin, lambdaMax, targetTPS, EvalTPS(qa.evalFuncData()))
+		if ind > 0 {
+			err = fmt.Errorf("target is above the maximum token throughput")
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to calculate lambdaStarTPS, targetTPS=%v, range=%s, ind=%d, err=%v",
+				targetTPS, qa.RateRange, ind, err)
+		}
+		if lambdaStarTPS > lambda {
+			return nil, nil, nil, fmt.Errorf("targetTPS=%v requires request rate %v, above max rate %v meeting latency targets",
+				targetTPS, lambdaStarTPS*1000, lambda*1000)
+		}
+	}
 	requestRate := lambda * 1000
-	if metrics, err = qa.Analyze(requestRate); err != nil {
+	if metrics, err = qa.analyze(requestRate, percentile); err != nil {
 		return nil, nil, nil, err
 	}
 
 	targetRate = &TargetRate{
-		RateTargetTTFT: lambdaStarTTFT * 1000,
-		RateTargetITL:  lambdaStarITL * 1000,
-		RateTargetTPS:  lambdaStarTPS * 1000,
+		RateTargetTTFT:        lambdaStarTTFT * 1000,
+		RateTargetITL:         lambdaStarITL * 1000,
+		RateTargetTPS:         lambdaStarTPS * 1000,
+		RateTargetUserTPS:     lambdaStarUserTPS * 1000,
+		RateTargetPctTTFT:     lambdaStarPctTTFT * 1000,
+		RateTargetPctRespTime: lambdaStarPctRespTime * 1000,
+		RateTargetAbandon:     lambdaStarAbandon * 1000,
 	}
 
 	achieved = &TargetPerf{
-		TargetTTFT: metrics.AvgTTFT,
-		TargetITL:  metrics.AvgTokenTime,
-		TargetTPS:  metrics.Throughput * qa.RequestSize.AvgOutputTokens,
+		TargetTTFT:         metrics.AvgTTFT,
+		TargetITL:          metrics.AvgTokenTime,
+		TargetTPS:          metrics.Throughput * qa.RequestSize.AvgOutputTokens,
+		TargetUserTPS:      1000 / metrics.AvgTokenTime,
+		Percentile:         percentile,
+		TargetPctTTFT:      metrics.PctTTFT,
+		TargetPctRespTime:  metrics.PctRespTime,
+		MaxAbandonFraction: metrics.AbandonFraction,
 	}
 	return targetRate, metrics, achieved, nil
 }
 
+// model and parameters of this analyzer used in functional evaluation
+func (qa *LLMQueueAnalyzer) evalFuncData() *EvalFuncData {
+	return &EvalFuncData{
+		model:        qa.Model,
+		requestSize:  qa.RequestSize,
+		serviceParms: qa.ServiceParms,
+		maxBatchSize: qa.MaxBatchSize,
+		numChunks:    qa.NumChunks,
+		numReplicas:  qa.NumReplicas,
+		abandonment:  qa.Abandonment,
+		dispatch:     qa.Dispatch,
+		metricsMode:  qa.MetricsMode,
+		tokens:       qa.tokens,
+		kvCache:      qa.kvCache,
+	}
+}
+
 // ---------------------------------------------------------------------------
 // New-analysis primitives. The work decomposition (w_prefill, w_decode, delta)
 // is unchanged from the per-iteration work model. The state-dependent service
@@ -349,18 +615,30 @@ func (p *ServiceParms) DecodeTime(r *RequestSize, batchSize float32) float32 {
 	return itlNew(p, r, batchSize, 1)
 }
 
+// Chunked variants of the shims above, at a given number of prefill chunks (e.g. from
+// NumIterationsPerPrefill), for callers outside the analyzer such as calibration. All
+// three are linear in (Alpha, Beta, Gamma) for batchSize >= 1.
+
+func (p *ServiceParms) ChunkedPrefillTime(r *RequestSize, batchSize float32, numChunks int) float32 {
+	return prefillNew(p, r, batchSize, numChunks)
+}
+
+func (p *ServiceParms) ChunkedDecodeTime(r *RequestSize, batchSize float32, numChunks int) float32 {
+	return itlNew(p, r, batchSize, numChunks)
+}
+
+func (p *ServiceParms) ChunkedServiceTime(r *RequestSize, batchSize float32, numChunks int) float32 {
+	return (float32(numChunks) + r.AvgOutputTokens) * tIter(p, r, batchSize, numChunks)
+}
+
 // Function used in binary search (target TTFT)
 //   - x is lambda req/msec
 func EvalTTFT(data *EvalFuncData) func(x float32) (float32, error) {
 	return func(x float32) (float32, error) {
-		data.model.Solve(x, 1)
-		if !data.model.IsValid() {
-			return 0, fmt.Errorf("invalid model %s", data.model)
+		if err := data.solve(x); err != nil {
+			return 0, err
 		}
-		B := data.model.GetAvgNumInServers()
-		nc := numChunksAtFromTable(data.numChunks, B, data.maxBatchSize)
-		avgPrefillTime := prefillNew(data.serviceParms, data.requestSize, B, nc)
-		avgDecodeTime := (data.model.GetAvgServTime() - avgPrefillTime) / data.requestSize.AvgOutputTokens
+		avgPrefillTime, avgDecodeTime := data.prefillDecodeTimes()
 		ttft := data.model.GetAvgWaitTime() + avgPrefillTime + avgDecodeTime
 		return ttft, nil
 	}
@@ -370,19 +648,246 @@ func EvalTTFT(data *EvalFuncData) func(x float32) (float32, error) {
 //   - x is lambda req/msec
 func EvalITL(data *EvalFuncData) func(x float32) (float32, error) {
 	return func(x float32) (float32, error) {
-		data.model.Solve(x, 1)
-		if !data.model.IsValid() {
-			return 0, fmt.Errorf("invalid model %s", data.model)
+		if err := data.solve(x); err != nil {
+			return 0, err
 		}
-		B := data.model.GetAvgNumInServers()
-		nc := numChunksAtFromTable(data.numChunks, B, data.maxBatchSize)
-		avgPrefillTime := prefillNew(data.serviceParms, data.requestSize, B, nc)
-		avgDecodeTime := (data.model.GetAvgServTime() - avgPrefillTime) / data.requestSize.AvgOutputTokens
+		_, avgDecodeTime := data.prefillDecodeTimes()
 		return avgDecodeTime, nil
 	}
 }
 
+// Function used in binary search (target aggregate TPS)
+//   - x is lambda req/msec
+func EvalTPS(data *EvalFuncData) func(x float32) (float32, error) {
+	return func(x float32) (float32, error) {
+		if err := data.solve(x); err != nil {
+			return 0, err
+		}
+		return data.model.GetThroughput() * 1000 * data.requestSize.AvgOutputTokens, nil
+	}
+}
+
+// Function used in binary search (target per-user TPS)
+//   - x is lambda req/msec
+func EvalUserTPS(data *EvalFuncData) func(x float32) (float32, error) {
+	return func(x float32) (float32, error) {
+		if err := data.solve(x); err != nil {
+			return 0, err
+		}
+		_, avgDecodeTime := data.prefillDecodeTimes()
+		if avgDecodeTime <= 0 {
+			return 0, fmt.Errorf("invalid decode time %v", avgDecodeTime)
+		}
+		return 1000 / avgDecodeTime, nil
+	}
+}
+
+// Function used in binary search (target percentile TTFT)
+//   - x is lambda req/msec
+func EvalPctTTFT(data *EvalFuncData, percentile float32) func(x float32) (float32, error) {
+	return func(x float32) (float32, error) {
+		if err := data.solve(x); err != nil {
+			return 0, err
+		}
+		avgPrefillTime, avgDecodeTime := data.prefillDecodeTimes()
+		return data.model.GetWaitTimePercentile(percentile) + avgPrefillTime + avgDecodeTime, nil
+	}
+}
+
+// Function used in binary search (target percentile response time)
+//   - x is lambda req/msec
+func EvalPctRespTime(data *EvalFuncData, percentile float32) func(x float32) (float32, error) {
+	return func(x float32) (float32, error) {
+		if err := data.solve(x); err != nil {
+			return 0, err
+		}
+		return data.model.GetWaitTimePercentile(percentile) + data.model.GetAvgServTime(), nil
+	}
+}
+
+// Function used in binary search (max abandonment fraction)
+//   - x is lambda req/msec
+func EvalAbandonFraction(data *EvalFuncData) func(x float32) (float32, error) {
+	return func(x float32) (float32, error) {
+		if err := data.solve(x); err != nil {
+			return 0, err
+		}
+		return data.abandonFraction(), nil
+	}
+}
+
+// solve the model at lambda (req/msec); abandoned requests that are retried add to lambda,
+// found by fixed-point iteration (the retry rate is bounded as abandonRate <= lambda and RetryProb < 1)
+func (data *EvalFuncData) solve(x float32) error {
+	lambda := x
+	for range maxRetryIterations {
+		data.model.Solve(lambda, 1)
+		if !data.model.IsValid() {
+			return fmt.Errorf("invalid model %s", data.model)
+		}
+		if data.abandonment == nil || data.abandonment.RetryProb == 0 {
+			return nil
+		}
+		queueAbandonRate, prefillAbandonRate := data.abandonRates()
+		next := x + data.abandonment.RetryProb*(queueAbandonRate+prefillAbandonRate)
+		if next-lambda <= retryTolerance*next {
+			return nil
+		}
+		lambda = next
+	}
+	return nil
+}
+
+// rates (req/msec) of requests abandoned in the queue and during prefill (before the first token) of the solved model
+func (data *EvalFuncData) abandonRates() (queueAbandonRate, prefillAbandonRate float32) {
+	a := data.abandonment
+	if a == nil {
+		return 0, 0
+	}
+	avgPrefillTime, avgDecodeTime := data.prefillDecodeTimes()
+	firstToken := avgPrefillTime + avgDecodeTime
+
+	// fraction of requests entering service that abandon before their first token
+	var fraction float32
+	switch a.Patience {
+	case queue.DeterministicPatience:
+		// requests entering service waited at most the patience; those that waited more
+		// than the patience minus the first-token time abandon
+		if served := data.model.GetWaitTimeCDF(a.PatienceTime); served > 0 {
+			fraction = (served - data.model.GetWaitTimeCDF(a.PatienceTime-firstToken)) / served
+		}
+	default:
+		fraction = 1 - float32(math.Exp(float64(-firstToken/a.PatienceTime)))
+	}
+	return data.model.GetAbandonRate(), data.model.GetThroughput() * min(max(fraction, 0), 1)
+}
+
+// fraction of admitted requests abandoned before their first token, of the solved model
+func (data *EvalFuncData) abandonFraction() float32 {
+	queueAbandonRate, prefillAbandonRate := data.abandonRates()
+	admitted := data.model.GetThroughput() + queueAbandonRate
+	if admitted <= 0 {
+		return 0
+	}
+	return (queueAbandonRate + prefillAbandonRate) / admitted
+}
+
+// prefill and decode times of the solved model, according to the metrics mode
+func (data *EvalFuncData) prefillDecodeTimes() (avgPrefillTime, avgDecodeTime float32) {
+	if data.metricsMode == ExactMetrics {
+		return data.exactTimes()
+	}
+	return data.meanFieldTimes()
+}
+
+// exact prefill and decode times of the solved model: prefillNew and itlNew averaged over the
+// per-replica batch sizes b, each weighted by the probability that a request in service is in a
+// batch of size b, i.e. proportionally to b times the probability of such a batch
+func (data *EvalFuncData) exactTimes() (avgPrefillTime, avgDecodeTime float32) {
+	numReplicas := max(data.numReplicas, 1)
+	var prefill, decode, weight float64
+	for j, pj := range data.model.GetBatchSizeProbabilities() {
+		for _, rb := range replicaBatchSizes(j, numReplicas, data.maxBatchSize, data.dispatch) {
+			w := pj * float64(rb.batchSize*rb.numReplicas)
+			if w == 0 {
+				continue
+			}
+			B := float32(rb.batchSize)
+			prefill += w * float64(data.prefillTime(B))
+			decode += w * float64(data.itlTime(B))
+			weight += w
+		}
+	}
+	if weight == 0 {
+		return data.prefillTime(1), data.itlTime(1)
+	}
+	return float32(prefill / weight), float32(decode / weight)
+}
+
+// number of replicas with a given batch size
+type replicaBatch struct {
+	batchSize   int
+	numReplicas int
+}
+
+// batch sizes of the replicas with n requests in service in the pool, as assigned by the dispatching rule
+func replicaBatchSizes(n, numReplicas, maxBatchSize int, dispatch queue.DispatchPolicy) []replicaBatch {
+	if dispatch == queue.PackedDispatch {
+		full := n / maxBatchSize
+		return []replicaBatch{{maxBatchSize, full}, {n - full*maxBatchSize, 1}}
+	}
+	low := n / numReplicas
+	numHigh := n - low*numReplicas
+	return []replicaBatch{{low + 1, numHigh}, {low, numReplicas - numHigh}}
+}
+
+// mean batch size of the replicas at a mean number of requests in service in the pool, as assigned by the
+// dispatching rule: the even share of the requests, or, with packed dispatching, the batch size seen by the
+// average request, the requests filling full batches but for one replica holding the rest
+func meanFieldBatchSize(avgNumInServ float32, numReplicas, maxBatchSize int, dispatch queue.DispatchPolicy) float32 {
+	if dispatch != queue.PackedDispatch || numReplicas <= 1 || avgNumInServ <= 0 {
+		return avgNumInServ / float32(max(numReplicas, 1))
+	}
+	batch := float32(maxBatchSize)
+	full := float32(math.Floor(float64(avgNumInServ / batch)))
+	rest := avgNumInServ - full*batch
+	return (full*batch*batch + rest*rest) / avgNumInServ
+}
+
+// mean-field prefill and decode times of the solved model: the prefill time at the in-service mean batch size
+// (per replica), and the decode time per token as the rest of the average service time
+func (data *EvalFuncData) meanFieldTimes() (avgPrefillTime, avgDecodeTime float32) {
+	B := meanFieldBatchSize(data.model.GetAvgNumInServers(), data.numReplicas, data.maxBatchSize, data.dispatch)
+	avgPrefillTime = data.prefillTime(B)
+	avgDecodeTime = (data.model.GetAvgServTime() - avgPrefillTime) / data.requestSize.AvgOutputTokens
+	return avgPrefillTime, avgDecodeTime
+}
+
+// prefill and decode times of the solved model at the in-service mean batch size (per replica): the counterpart
+// of exactTimes, evaluating prefillTime and itlTime at the mean of the batch size rather than over its distribution
+func (data *EvalFuncData) meanBatchTimes() (avgPrefillTime, avgDecodeTime float32) {
+	B := meanFieldBatchSize(data.model.GetAvgNumInServers(), data.numReplicas, data.maxBatchSize, data.dispatch)
+	return data.prefillTime(B), data.itlTime(B)
+}
+
+// prefill time at a (possibly fractional) batch size, with the chunk count at the rounded batch size,
+// including the recompute work of the preemptions over its iterations
+func (data *EvalFuncData) prefillTime(batchSize float32) float32 {
+	nc := numChunksAtFromTable(data.numChunks, batchSize, data.maxBatchSize)
+	var prefill float32
+	if data.tokens != nil {
+		prefill = data.tokens.prefill(data.serviceParms, batchSize, batchIndex(batchSize, data.maxBatchSize))
+	} else {
+		prefill = prefillNew(data.serviceParms, data.requestSize, batchSize, nc)
+	}
+	if data.kvCache != nil {
+		prefill += float32(nc) * data.kvCache.recomputePerIteration(batchSize)
+	}
+	return prefill
+}
+
+// inter-token latency at a (possibly fractional) batch size, with the chunk count at the rounded batch size,
+// including the recompute work of the preemptions per iteration
+func (data *EvalFuncData) itlTime(batchSize float32) float32 {
+	var itl float32
+	if data.tokens != nil {
+		itl = data.tokens.itl(data.serviceParms, batchSize, batchIndex(batchSize, data.maxBatchSize))
+	} else {
+		nc := numChunksAtFromTable(data.numChunks, batchSize, data.maxBatchSize)
+		itl = itlNew(data.serviceParms, data.requestSize, batchSize, nc)
+	}
+	if data.kvCache != nil {
+		itl += data.kvCache.recomputePerIteration(batchSize)
+	}
+	return itl
+}
+
 func numChunksAtFromTable(table []int, batchSize float32, maxBatchSize int) int {
+	return table[batchIndex(batchSize, maxBatchSize)]
+}
+
+// index of per batch size tables at a (possibly fractional) batch size, rounded to within [1, maxBatchSize]
+func batchIndex(batchSize float32, maxBatchSize int) int {
 	idx := int(batchSize + 0.5)
 	if idx < 1 {
 		idx = 1
@@ -390,5 +895,5 @@ func numChunksAtFromTable(table []int, batchSize float32, maxBatchSize int) int
 	if idx > maxBatchSize {
 		idx = maxBatchSize
 	}
-	return table[idx]
+	return idx
 }
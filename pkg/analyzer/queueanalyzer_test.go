@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func newBaselineAnalyzer(t *testing.T, maxBatchSize, maxQueueSize int) *LLMQueueAnalyzer {
+	t.Helper()
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: maxBatchSize, MaxQueueSize: maxQueueSize, ServiceParms: sp}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	return qa
+}
+
+func TestAnalyzeReportsPercentiles(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	metrics, err := qa.Analyze(0.95 * qa.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if metrics.Percentile != DefaultPercentile {
+		t.Errorf("percentile: got %v, want %v", metrics.Percentile, DefaultPercentile)
+	}
+	if metrics.PctWaitTime <= 0 {
+		t.Errorf("near saturation the P90 wait should be positive, got %v", metrics.PctWaitTime)
+	}
+	if metrics.PctTTFT < metrics.AvgTTFT-metrics.AvgWaitTime {
+		t.Errorf("P90 TTFT %v below its deterministic part", metrics.PctTTFT)
+	}
+
+	qa.Percentile = 0.99
+	p99, err := qa.Analyze(0.95 * qa.RateRange.Max)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if p99.PctTTFT < metrics.PctTTFT {
+		t.Errorf("P99 TTFT %v below P90 TTFT %v", p99.PctTTFT, metrics.PctTTFT)
+	}
+}
+
+func TestSizePercentileTTFT(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	mean, _, _, err := qa.Size(&TargetPerf{TargetTTFT: 200})
+	if err != nil {
+		t.Fatalf("Size (mean): %v", err)
+	}
+	rate, _, achieved, err := qa.Size(&TargetPerf{TargetPctTTFT: 200, Percentile: 0.99})
+	if err != nil {
+		t.Fatalf("Size (P99): %v", err)
+	}
+	if math.Abs(float64(achieved.TargetPctTTFT-200)) > 1 {
+		t.Errorf("achieved P99 TTFT: got %v, want 200", achieved.TargetPctTTFT)
+	}
+	if achieved.Percentile != 0.99 {
+		t.Errorf("achieved percentile: got %v, want 0.99", achieved.Percentile)
+	}
+	if rate.RateTargetPctTTFT > mean.RateTargetTTFT {
+		t.Errorf("P99 target admits a higher rate (%v) than the same mean target (%v)",
+			rate.RateTargetPctTTFT, mean.RateTargetTTFT)
+	}
+}
+
+func TestSizeRejectsInvalidPercentile(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	if _, _, _, err := qa.Size(&TargetPerf{TargetPctTTFT: 200, Percentile: 1}); err == nil {
+		t.Error("expected error for percentile 1, got nil")
+	}
+}
+
+func TestSizeAggregateTPS(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	rs := qa.RequestSize
+
+	// a TPS target within the latency-feasible region sets a lower bound on the rate
+	rate, metrics, achieved, err := qa.Size(&TargetPerf{TargetITL: 20, TargetTPS: 1000})
+	if err != nil {
+		t.Fatalf("Size: %v", err)
+	}
+	if got := rate.RateTargetTPS * rs.AvgOutputTokens; math.Abs(float64(got-1000))/1000 > 1e-3 {
+		t.Errorf("goodput at RateTargetTPS: got %v, want 1000", got)
+	}
+	if achieved.TargetTPS < 1000 {
+		t.Errorf("achieved TPS %v below target", achieved.TargetTPS)
+	}
+	if metrics.OfferedRate != rate.RateTargetITL {
+		t.Errorf("operating rate %v, want the ITL-bound rate %v", metrics.OfferedRate, rate.RateTargetITL)
+	}
+
+	// a TPS target beyond what the latency targets allow is an error
+	if _, _, _, err := qa.Size(&TargetPerf{TargetITL: 20, TargetTPS: 1e5}); err == nil {
+		t.Error("expected error for unattainable TPS, got nil")
+	}
+}
+
+func TestSizeUserTPSMatchesITL(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	byITL, _, _, err := qa.Size(&TargetPerf{TargetITL: 20})
+	if err != nil {
+		t.Fatalf("Size (ITL): %v", err)
+	}
+	byTPS, _, achieved, err := qa.Size(&TargetPerf{TargetUserTPS: 50})
+	if err != nil {
+		t.Fatalf("Size (user TPS): %v", err)
+	}
+	if math.Abs(float64(byTPS.RateTargetUserTPS-byITL.RateTargetITL))/float64(byITL.RateTargetITL) > 1e-3 {
+		t.Errorf("user TPS 50 rate %v, want ITL 20 rate %v", byTPS.RateTargetUserTPS, byITL.RateTargetITL)
+	}
+	if math.Abs(float64(achieved.TargetUserTPS-50)) > 0.1 {
+		t.Errorf("achieved user TPS: got %v, want 50", achieved.TargetUserTPS)
+	}
+}
+
+func TestReplicaPool(t *testing.T) {
+	single := newBaselineAnalyzer(t, 64, 256)
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, NumReplicas: 4, ServiceParms: sp}
+	pool, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	if ratio := pool.RateRange.Max / single.RateRange.Max; math.Abs(float64(ratio-4)) > 1e-3 {
+		t.Errorf("pool max rate ratio: got %v, want 4", ratio)
+	}
+
+	// same per-replica load: pooling the queue cannot increase the waiting time
+	rate := 0.9 * single.RateRange.Max
+	one, err := single.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze (single): %v", err)
+	}
+	four, err := pool.Analyze(4 * rate)
+	if err != nil {
+		t.Fatalf("Analyze (pool): %v", err)
+	}
+	if four.AvgWaitTime > one.AvgWaitTime {
+		t.Errorf("pool wait %v above single-replica wait %v", four.AvgWaitTime, one.AvgWaitTime)
+	}
+	if math.Abs(float64(four.Rho-one.Rho)) > 0.05 {
+		t.Errorf("pool utilization %v, single-replica utilization %v", four.Rho, one.Rho)
+	}
+
+	// sizing answers what the pool sustains
+	targetRate, _, _, err := pool.Size(&TargetPerf{TargetITL: 20})
+	if err != nil {
+		t.Fatalf("Size (pool): %v", err)
+	}
+	singleRate, _, _, err := single.Size(&TargetPerf{TargetITL: 20})
+	if err != nil {
+		t.Fatalf("Size (single): %v", err)
+	}
+	if targetRate.RateTargetITL < 3.5*singleRate.RateTargetITL {
+		t.Errorf("pool ITL rate %v, want about 4x single %v", targetRate.RateTargetITL, singleRate.RateTargetITL)
+	}
+}
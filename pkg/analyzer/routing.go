@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+// default number of replicas sampled per request by power-of-d routing
+const DefaultRoutingChoices = 2
+
+// Routing of requests by a load balancer to the replicas of a pool
+type RoutingPolicy int
+
+const (
+	// replicas share one queue, a request entering service at the first free batch slot (the pool model of Analyze)
+	SharedQueueRouting RoutingPolicy = iota
+	// each request is sent to a replica at random, each replica with its own queue
+	RandomRouting
+	// each request is sent to the replica with the fewest requests among d sampled at random (JSQ(d))
+	PowerOfDRouting
+	// each request is sent to the replica with the fewest outstanding requests (JSQ)
+	LeastOutstandingRouting
+)
+
+// load-balancer routing to the replicas of a pool
+type Routing struct {
+	Policy  RoutingPolicy // routing policy
+	Choices int           // number of replicas sampled per request by PowerOfDRouting (0 => DefaultRoutingChoices)
+}
+
+// Evaluate pool-level performance metrics of the NumReplicas replicas of the analyzer given a
+// request rate and the routing policy of the load balancer.
+//
+// With SharedQueueRouting, this is Analyze. Otherwise each replica has its own queue, holding up to
+// MaxQueueSize waiting requests, and the per-replica state-dependent service rates (as built by
+// BuildModel) of a replica are combined with the routing by the mean-field JSQ(d) model
+// (queue.JSQModelStateDependent): d = 1 for RandomRouting (exact: each replica is an independent
+// queue at its share of the rate), d = Choices for PowerOfDRouting, and d = NumReplicas for
+// LeastOutstandingRouting. The mean-field model is the limit of a large pool, where the total
+// occupancy of the pool does not fluctuate; for small pools it is optimistic for d > 1, and near
+// saturation may even fall below the shared queue. Throughput and the number in service are totals
+// over the pool, and times are per request. Abandonment and the general-service correction are not
+// supported.
+func (qa *LLMQueueAnalyzer) AnalyzeRouting(requestRate float32, routing *Routing) (metrics *AnalysisMetrics, err error) {
+	if err := routing.check(qa.NumReplicas); err != nil {
+		return nil, err
+	}
+	if routing.Policy == SharedQueueRouting {
+		return qa.Analyze(requestRate)
+	}
+	if requestRate <= 0 {
+		return nil, fmt.Errorf("invalid request rate %v", requestRate)
+	}
+	if qa.Abandonment != nil {
+		return nil, fmt.Errorf("abandonment not supported with routing policy %s", routing.Policy)
+	}
+	if qa.GeneralService {
+		return nil, fmt.Errorf("general service not supported with routing policy %s", routing.Policy)
+	}
+
+	// mean-field model of one replica at its share of the rate
+	numReplicas := qa.NumReplicas
+	model := queue.NewJSQModelStateDependent(qa.MaxQueueSize+qa.MaxBatchSize, qa.servRate, routing.choices(numReplicas))
+	data := qa.evalFuncData()
+	data.model = model.MM1ModelStateDependent
+	data.numReplicas = 1
+	if err = data.solve(requestRate / 1000 / float32(numReplicas)); err != nil {
+		return nil, err
+	}
+
+	avgPrefillTime, avgDecodeTime := data.prefillDecodeTimes()
+	meanFieldPrefillTime, meanFieldDecodeTime := data.meanBatchTimes()
+	exactPrefillTime, exactDecodeTime := data.exactTimes()
+	avgWaitTime := model.GetAvgWaitTime()
+	batchSize := model.GetAvgNumInServers()
+	avgNumInServ := batchSize * float32(numReplicas)
+	throughput := model.GetThroughput() * 1000 * float32(numReplicas)
+	pctWaitTime := model.GetWaitTimePercentile(qa.Percentile)
+
+	metrics = &AnalysisMetrics{
+		OfferedRate:    requestRate,
+		Throughput:     throughput,
+		AvgRespTime:    model.GetAvgRespTime(),
+		AvgWaitTime:    avgWaitTime,
+		AvgNumInServ:   avgNumInServ,
+		AvgPrefillTime: avgPrefillTime,
+		AvgTokenTime:   avgDecodeTime,
+		AvgTTFT:        avgWaitTime + avgPrefillTime + avgDecodeTime,
+		MaxRate:        qa.RateRange.Max,
+		Rho:            min(max(batchSize/float32(qa.MaxBatchSize), 0), 1),
+		Percentile:     qa.Percentile,
+		PctWaitTime:    pctWaitTime,
+		PctTTFT:        pctWaitTime + avgPrefillTime + avgDecodeTime,
+		PctRespTime:    pctWaitTime + model.GetAvgServTime(),
+		MeanFieldTTFT:  avgWaitTime + meanFieldPrefillTime + meanFieldDecodeTime,
+		MeanFieldITL:   meanFieldDecodeTime,
+		ExactTTFT:      avgWaitTime + exactPrefillTime + exactDecodeTime,
+		ExactITL:       exactDecodeTime,
+		Goodput:        throughput,
+	}
+	if qa.kvCache != nil {
+		metrics.PreemptionRate = throughput *
+			qa.kvCache.preemptionsPerRequest(qa.RequestSize, batchSize, qa.numChunksAt(batchSize))
+	}
+	return metrics, nil
+}
+
+// number of replicas sampled per request
+func (r *Routing) choices(numReplicas int) int {
+	switch r.Policy {
+	case PowerOfDRouting:
+		if r.Choices == 0 {
+			return DefaultRoutingChoices
+		}
+		return r.Choices
+	case LeastOutstandingRouting:
+		return numReplicas
+	default:
+		return 1
+	}
+}
+
+// check validity of routing over a number of replicas
+func (r *Routing) check(numReplicas int) error {
+	if r == nil || r.Policy < SharedQueueRouting || r.Policy > LeastOutstandingRouting || r.Choices < 0 {
+		return fmt.Errorf("invalid routing %s", r)
+	}
+	if r.Policy == PowerOfDRouting && r.Choices > numReplicas {
+		return fmt.Errorf("routing %s samples more than the %d replicas", r, numReplicas)
+	}
+	return nil
+}
+
+func (r *Routing) String() string {
+	if r == nil {
+		return "<nil>"
+	}
+	if r.Policy == PowerOfDRouting {
+		return fmt.Sprintf("{policy=%s, choices=%d}", r.Policy, r.choices(0))
+	}
+	return fmt.Sprintf("{policy=%s}", r.Policy)
+}
+
+func (p RoutingPolicy) String() string {
+	switch p {
+	case SharedQueueRouting:
+		return "shared-queue"
+	case RandomRouting:
+		return "random"
+	case PowerOfDRouting:
+		return "power-of-d"
+	case LeastOutstandingRouting:
+		return "least-outstanding"
+	default:
+		return fmt.Sprintf("RoutingPolicy(%d)", int(p))
+	}
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+// a pool of replicas of the baseline server
+func newBaselinePool(t *testing.T, numReplicas int) *LLMQueueAnalyzer {
+	t.Helper()
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 128, NumReplicas: numReplicas, ServiceParms: sp}
+	qa, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	return qa
+}
+
+// Random routing splits the rate evenly over independent replicas.
+func TestRandomRoutingIsIndependentReplicas(t *testing.T) {
+	pool := newBaselinePool(t, 4)
+	single := newBaselineAnalyzer(t, 64, 128)
+	rate := 0.8 * pool.RateRange.Max
+	got, err := pool.AnalyzeRouting(rate, &Routing{Policy: RandomRouting})
+	if err != nil {
+		t.Fatalf("AnalyzeRouting: %v", err)
+	}
+	want, err := single.Analyze(rate / 4)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if math.Abs(float64(got.AvgTTFT-want.AvgTTFT)) > 1e-3*float64(want.AvgTTFT) ||
+		math.Abs(float64(got.AvgTokenTime-want.AvgTokenTime)) > 1e-3*float64(want.AvgTokenTime) ||
+		math.Abs(float64(got.Throughput-4*want.Throughput)) > 1e-3*float64(got.Throughput) ||
+		math.Abs(float64(got.AvgNumInServ-4*want.AvgNumInServ)) > 1e-3*float64(got.AvgNumInServ) {
+		t.Errorf("random routing %s, want replica %s", got, want)
+	}
+}
+
+// Smarter routing shortens the wait: random > power-of-two > least-outstanding, and the shared
+// queue is the same as Analyze.
+func TestRoutingPoliciesOrder(t *testing.T) {
+	pool := newBaselinePool(t, 8)
+	rate := 0.995 * pool.RateRange.Max
+	var waits []float32
+	for _, routing := range []*Routing{
+		{Policy: RandomRouting},
+		{Policy: PowerOfDRouting},
+		{Policy: LeastOutstandingRouting},
+	} {
+		metrics, err := pool.AnalyzeRouting(rate, routing)
+		if err != nil {
+			t.Fatalf("%s: AnalyzeRouting: %v", routing, err)
+		}
+		if math.Abs(float64(metrics.Throughput-rate)) > 1e-2*float64(rate) {
+			t.Errorf("%s: throughput %v, want %v", routing, metrics.Throughput, rate)
+		}
+		waits = append(waits, metrics.AvgWaitTime)
+	}
+	if !(waits[0] > waits[1] && waits[1] > waits[2]) {
+		t.Errorf("waits %v should decrease from random to power-of-two to least-outstanding", waits)
+	}
+
+	shared, err := pool.AnalyzeRouting(rate, &Routing{Policy: SharedQueueRouting})
+	if err != nil {
+		t.Fatalf("AnalyzeRouting: %v", err)
+	}
+	want, err := pool.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if shared.AvgTTFT != want.AvgTTFT || shared.AvgWaitTime > waits[0] {
+		t.Errorf("shared queue %s, want %s (wait below random %v)", shared, want, waits[0])
+	}
+}
+
+func TestRoutingInvalid(t *testing.T) {
+	pool := newBaselinePool(t, 4)
+	for _, routing := range []*Routing{nil, {Policy: RoutingPolicy(9)}, {Policy: PowerOfDRouting, Choices: -1},
+		{Policy: PowerOfDRouting, Choices: 5}} {
+		if _, err := pool.AnalyzeRouting(1, routing); err == nil {
+			t.Errorf("%s: expected error", routing)
+		}
+	}
+	if _, err := pool.AnalyzeRouting(0, &Routing{Policy: RandomRouting}); err == nil {
+		t.Errorf("expected error for a zero rate")
+	}
+}
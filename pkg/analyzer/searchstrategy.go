@@ -0,0 +1,203 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SearchStrategy chooses the concurrency M* in [MMin, MMax] of a
+// ConcurrencyOptimizer, probing f(M) through the search context, or reports
+// false if it found the SLO unreachable at every M. The optimizer adds one
+// confirmatory probe at M*, counted in the calls as in the Python harness.
+// Strategies are registered by name (RegisterSearchStrategy) and selected by
+// ConcurrencyOptimizer.Strategy.
+type SearchStrategy interface {
+	Name() string
+	Search(s *SearchContext) (m int, feasible bool)
+}
+
+// SearchReading is a probe reading at concurrency cap M, mirroring a /target
+// response: 0 throughput if the SLO is unreachable at M.
+type SearchReading struct {
+	Throughput float32 // f(M) (requests/sec)
+	Ratio      float32 // R(M) = RPSTargetTTFT / RPSTargetITL; default oracle only, else 0
+}
+
+// SearchContext is the view of the optimizer given to a strategy: its inputs,
+// search parameters, closed-form brackets (zero oracle calls), and the counted
+// probe.
+type SearchContext struct {
+	ServiceParms *ServiceParms
+	RequestSize  *RequestSize
+	Target       *TargetPerf
+	MMin         int
+	MMax         int
+	Epsilon      float32
+	MaxIters     int
+	MITL         int // closed-form ITL-binding bracket
+	MTPF         int // closed-form TTFT-prefill-binding bracket
+
+	probe func(m int) SearchReading
+}
+
+// Probe reads f(M) at cap m; every probe is recorded, and counted as a call if
+// feasible. Probes are not cached: probing the same cap twice costs twice.
+func (s *SearchContext) Probe(m int) SearchReading {
+	return s.probe(m)
+}
+
+// Throughput probes f(M) at cap m.
+func (s *SearchContext) Throughput(m int) float32 {
+	return s.probe(m).Throughput
+}
+
+// Ratio probes R(M) at cap m.
+func (s *SearchContext) Ratio(m int) float32 {
+	return s.probe(m).Ratio
+}
+
+// registered strategies by name
+var searchStrategies = map[string]SearchStrategy{}
+
+// RegisterSearchStrategy makes a strategy selectable by its name, replacing
+// any strategy of the same name. Not safe for concurrent use with Find; meant
+// to be called at init time.
+func RegisterSearchStrategy(strategy SearchStrategy) {
+	searchStrategies[strategy.Name()] = strategy
+}
+
+// SearchStrategyByName returns the registered strategy of the given name; the
+// empty name selects FormulaGuidedStrategy.
+func SearchStrategyByName(name string) (SearchStrategy, error) {
+	if name == "" {
+		name = FormulaGuidedStrategy
+	}
+	strategy, ok := searchStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown search strategy %q (registered: %s)",
+			name, strings.Join(SearchStrategyNames(), ", "))
+	}
+	return strategy, nil
+}
+
+// SearchStrategyNames returns the names of the registered strategies, sorted.
+func SearchStrategyNames() []string {
+	names := make([]string, 0, len(searchStrategies))
+	for name := range searchStrategies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ConcurrencyCurve is the exhaustive f-curve of an optimizer, f(M) at every
+// M in [MMin, MMax], the ground truth against which strategies are scored.
+type ConcurrencyCurve struct {
+	MMin            int
+	Throughput      []float32 // f(MMin + i); 0 if the SLO is unreachable
+	MTruth          int       // argmax of f (smallest on ties); MMin if infeasible everywhere
+	ThroughputTruth float32   // f(MTruth)
+	MOnset          int       // smallest M with f(M) >= (1-epsilon) f(MTruth); MTruth if infeasible everywhere
+}
+
+// At returns f(m) on the curve, 0 outside its range.
+func (c *ConcurrencyCurve) At(m int) float32 {
+	if i := m - c.MMin; i >= 0 && i < len(c.Throughput) {
+		return c.Throughput[i]
+	}
+	return 0
+}
+
+// Curve probes the oracle at every M in [MMin, MMax] (uncounted).
+func (o *ConcurrencyOptimizer) Curve() (*ConcurrencyCurve, error) {
+	if err := o.check(); err != nil {
+		return nil, err
+	}
+	o.applyDefaults()
+	c := &ConcurrencyCurve{
+		MMin:       o.MMin,
+		Throughput: make([]float32, 0, o.MMax-o.MMin+1),
+		MTruth:     o.MMin,
+	}
+	for m := o.MMin; m <= o.MMax; m++ {
+		thr := o.read(m).Throughput
+		c.Throughput = append(c.Throughput, thr)
+		if thr > c.ThroughputTruth {
+			c.MTruth, c.ThroughputTruth = m, thr
+		}
+	}
+	c.MOnset = c.MTruth
+	if c.ThroughputTruth > 0 {
+		threshold := (1 - o.Epsilon) * c.ThroughputTruth
+		for i, thr := range c.Throughput {
+			if thr >= threshold {
+				c.MOnset = c.MMin + i
+				break
+			}
+		}
+	}
+	return c, nil
+}
+
+// StrategyScore is the cost and accuracy of a search against the curve.
+type StrategyScore struct {
+	Strategy         string
+	Concurrency      int     // M* chosen by the strategy
+	Calls            int     // feasible oracle calls, incl. confirmatory
+	Throughput       float32 // f(M*) from the confirmatory probe
+	GapThroughputRel float32 // (f(MTruth) - f(M*)) / f(MTruth), floored at 0 (on the plateau)
+	GapM             int     // |M* - MTruth|
+	GapOnset         int     // |M* - MOnset|
+}
+
+// Score scores a search result against the curve.
+func (c *ConcurrencyCurve) Score(res *ConcurrencyResult) *StrategyScore {
+	score := &StrategyScore{
+		Strategy:    res.Strategy,
+		Concurrency: res.Concurrency,
+		Calls:       res.Calls,
+		Throughput:  res.Throughput,
+		GapM:        abs(res.Concurrency - c.MTruth),
+		GapOnset:    abs(res.Concurrency - c.MOnset),
+	}
+	if c.ThroughputTruth > 0 {
+		score.GapThroughputRel = max(0, (c.ThroughputTruth-res.Throughput)/c.ThroughputTruth)
+	}
+	return score
+}
+
+// CompareStrategies runs each named strategy on a copy of the optimizer and
+// scores it against the curve. Without names, it runs every registered
+// strategy that can run with the optimizer's oracle.
+func (o *ConcurrencyOptimizer) CompareStrategies(names ...string) (*ConcurrencyCurve, []*StrategyScore, error) {
+	curve, err := o.Curve()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(names) == 0 {
+		for _, name := range SearchStrategyNames() {
+			if o.oracleIsDefault || !usesRatio(searchStrategies[name]) {
+				names = append(names, name)
+			}
+		}
+	}
+	scores := make([]*StrategyScore, 0, len(names))
+	for _, name := range names {
+		opt := *o
+		opt.Strategy = name
+		res, err := opt.Find()
+		if err != nil {
+			return nil, nil, err
+		}
+		scores = append(scores, curve.Score(res))
+	}
+	return curve, scores, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+)
+
+// number of rate increments of the traffic split
+const DefaultSplitSteps = 1000
+
+// objective of the traffic split
+type SplitObjective int
+
+const (
+	MinMeanTTFT SplitObjective = iota // minimize the average TTFT over all requests
+	MinMaxTTFT                        // minimize the largest average TTFT over the replicas with traffic
+)
+
+// routing of a request rate to replicas, each with its own queue
+type TrafficSplit struct {
+	Fractions []float32          // routing fraction per replica (sum to 1)
+	Rates     []float32          // request rate per replica (requests/sec)
+	Metrics   []*AnalysisMetrics // operating point per replica (nil => no traffic)
+	AvgTTFT   float32            // average time to first token over all requests (msec)
+	MaxTTFT   float32            // largest average time to first token over the replicas with traffic (msec)
+}
+
+// Split a request rate across heterogeneous replicas (e.g. of different GPU types), each with its own
+// queue and analyzer, minimizing the average (MinMeanTTFT) or the largest (MinMaxTTFT) TTFT, subject
+// to per-replica ITL limits (maxITL, nil or 0 => no limit) and stability of each replica.
+//
+// The rate is routed in DefaultSplitSteps equal increments, each to the replica of least marginal
+// cost: the increase of the total time to first token (rate * TTFT) for MinMeanTTFT, and the TTFT at
+// the increased rate for MinMaxTTFT. The greedy allocation is optimal, up to the increment, when the
+// TTFT increases with the rate (MinMaxTTFT) and the total time to first token is convex in the rate
+// (MinMeanTTFT), as is the case for the queueing model.
+func SplitTraffic(analyzers []*LLMQueueAnalyzer, totalRate float32, maxITL []float32,
+	objective SplitObjective) (*TrafficSplit, error) {
+	if len(analyzers) == 0 || totalRate <= 0 || maxITL != nil && len(maxITL) != len(analyzers) ||
+		objective != MinMeanTTFT && objective != MinMaxTTFT {
+		return nil, fmt.Errorf("invalid split input: replicas=%d, totalRate=%v, maxITL=%v, objective=%d",
+			len(analyzers), totalRate, maxITL, objective)
+	}
+	for _, limit := range maxITL {
+		if limit < 0 {
+			return nil, fmt.Errorf("invalid ITL limit %v", limit)
+		}
+	}
+
+	n := len(analyzers)
+	step := totalRate / DefaultSplitSteps
+	rates := make([]float32, n)
+	current := make([]*AnalysisMetrics, n)
+	next := make([]*AnalysisMetrics, n)
+
+	// operating point of a replica one increment up, nil if unstable or over its ITL limit
+	stepUp := func(i int) *AnalysisMetrics {
+		qa := analyzers[i]
+		rate := rates[i] + step
+		if rate > qa.RateRange.Max {
+			return nil
+		}
+		metrics, err := qa.Analyze(rate)
+		if err != nil || maxITL != nil && maxITL[i] > 0 && metrics.AvgTokenTime > maxITL[i] {
+			return nil
+		}
+		return metrics
+	}
+	// marginal cost of the increment to a replica
+	cost := func(i int) float64 {
+		switch objective {
+		case MinMaxTTFT:
+			return float64(next[i].AvgTTFT)
+		default:
+			total := float64(next[i].OfferedRate) * float64(next[i].AvgTTFT)
+			if current[i] != nil {
+				total -= float64(current[i].OfferedRate) * float64(current[i].AvgTTFT)
+			}
+			return total
+		}
+	}
+
+	for i := range n {
+		next[i] = stepUp(i)
+	}
+	for range DefaultSplitSteps {
+		best, bestCost := -1, math.Inf(1)
+		for i := range n {
+			if next[i] == nil {
+				continue
+			}
+			if c := cost(i); c < bestCost {
+				best, bestCost = i, c
+			}
+		}
+		if best < 0 {
+			return nil, fmt.Errorf("request rate %v exceeds the capacity of the replicas within the ITL limits", totalRate)
+		}
+		rates[best] += step
+		current[best] = next[best]
+		next[best] = stepUp(best)
+	}
+
+	split := &TrafficSplit{
+		Fractions: make([]float32, n),
+		Rates:     rates,
+		Metrics:   current,
+	}
+	var totalTTFT float32
+	for i, metrics := range current {
+		split.Fractions[i] = rates[i] / totalRate
+		if metrics == nil {
+			continue
+		}
+		totalTTFT += rates[i] * metrics.AvgTTFT
+		split.MaxTTFT = max(split.MaxTTFT, metrics.AvgTTFT)
+	}
+	split.AvgTTFT = totalTTFT / totalRate
+	return split, nil
+}
+
+func (ts *TrafficSplit) String() string {
+	return fmt.Sprintf("{fractions=%v, rates=%v, avgTTFT=%.3f, maxTTFT=%.3f}",
+		ts.Fractions, ts.Rates, ts.AvgTTFT, ts.MaxTTFT)
+}
@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+// a baseline replica and one of half its speed
+func newHeterogeneousPair(t *testing.T) []*LLMQueueAnalyzer {
+	t.Helper()
+	fast := newBaselineAnalyzer(t, 64, 128)
+	sp, rs := baselineParts()
+	slow := &ServiceParms{Alpha: 2 * sp.Alpha, Beta: 2 * sp.Beta, Gamma: 2 * sp.Gamma}
+	qa, err := NewLLMQueueAnalyzer(&Configuration{MaxBatchSize: 64, MaxQueueSize: 128, ServiceParms: slow}, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	return []*LLMQueueAnalyzer{fast, qa}
+}
+
+// average and largest TTFT of routing a fraction of the rate to the first of two replicas, NaN if unstable
+func pairTTFT(pair []*LLMQueueAnalyzer, totalRate, fraction float32) (avg, largest float64) {
+	rates := []float32{fraction * totalRate, (1 - fraction) * totalRate}
+	for i, rate := range rates {
+		if rate <= 0 {
+			continue
+		}
+		if rate > pair[i].RateRange.Max {
+			return math.NaN(), math.NaN()
+		}
+		m, err := pair[i].Analyze(rate)
+		if err != nil {
+			return math.NaN(), math.NaN()
+		}
+		avg += float64(rate*m.AvgTTFT) / float64(totalRate)
+		largest = math.Max(largest, float64(m.AvgTTFT))
+	}
+	return avg, largest
+}
+
+func TestSplitTrafficIdenticalReplicas(t *testing.T) {
+	replicas := []*LLMQueueAnalyzer{newBaselineAnalyzer(t, 64, 128), newBaselineAnalyzer(t, 64, 128)}
+	rate := 0.8 * replicas[0].RateRange.Max
+	for _, objective := range []SplitObjective{MinMeanTTFT, MinMaxTTFT} {
+		split, err := SplitTraffic(replicas, rate, nil, objective)
+		if err != nil {
+			t.Fatalf("SplitTraffic: %v", err)
+		}
+		if math.Abs(float64(split.Fractions[0]-0.5)) > 0.01 {
+			t.Errorf("objective %d: fractions %v, want even", objective, split.Fractions)
+		}
+	}
+}
+
+func TestSplitTrafficHeterogeneous(t *testing.T) {
+	pair := newHeterogeneousPair(t)
+	rate := 0.9 * pair[0].RateRange.Max
+
+	// best fractions on a grid
+	bestAvg, bestMax := math.Inf(1), math.Inf(1)
+	for k := 0; k <= 200; k++ {
+		avg, largest := pairTTFT(pair, rate, float32(k)/200)
+		if !math.IsNaN(avg) {
+			bestAvg, bestMax = math.Min(bestAvg, avg), math.Min(bestMax, largest)
+		}
+	}
+
+	mean, err := SplitTraffic(pair, rate, nil, MinMeanTTFT)
+	if err != nil {
+		t.Fatalf("SplitTraffic: %v", err)
+	}
+	if float64(mean.AvgTTFT) > bestAvg*1.001 {
+		t.Errorf("mean TTFT %v above the grid optimum %v (split %s)", mean.AvgTTFT, bestAvg, mean)
+	}
+	if mean.Fractions[0] <= 0.5 || mean.Fractions[1] <= 0 {
+		t.Errorf("fractions %v: the faster replica should carry more, the slower some", mean.Fractions)
+	}
+	if sum := mean.Fractions[0] + mean.Fractions[1]; math.Abs(float64(sum-1)) > 1e-3 {
+		t.Errorf("fractions sum to %v", sum)
+	}
+
+	largest, err := SplitTraffic(pair, rate, nil, MinMaxTTFT)
+	if err != nil {
+		t.Fatalf("SplitTraffic: %v", err)
+	}
+	if float64(largest.MaxTTFT) > bestMax*1.001 {
+		t.Errorf("max TTFT %v above the grid optimum %v (split %s)", largest.MaxTTFT, bestMax, largest)
+	}
+	if largest.MaxTTFT > mean.MaxTTFT*1.001 || mean.AvgTTFT > largest.AvgTTFT*1.001 {
+		t.Errorf("each objective should be best at its own metric: mean %s, max %s", mean, largest)
+	}
+}
+
+func TestSplitTrafficITLLimits(t *testing.T) {
+	pair := newHeterogeneousPair(t)
+	rate := 0.5 * pair[0].RateRange.Max
+	unlimited, err := SplitTraffic(pair, rate, nil, MinMeanTTFT)
+	if err != nil {
+		t.Fatalf("SplitTraffic: %v", err)
+	}
+
+	// cap the ITL of the fast replica below its unlimited operating point
+	limit := 0.95 * unlimited.Metrics[0].AvgTokenTime
+	limited, err := SplitTraffic(pair, rate, []float32{limit, 0}, MinMeanTTFT)
+	if err != nil {
+		t.Fatalf("SplitTraffic: %v", err)
+	}
+	if limited.Metrics[0].AvgTokenTime > limit {
+		t.Errorf("ITL %v over the limit %v", limited.Metrics[0].AvgTokenTime, limit)
+	}
+	if limited.Rates[0] >= unlimited.Rates[0] {
+		t.Errorf("the limit should shift load off the fast replica: %v vs %v", limited.Rates, unlimited.Rates)
+	}
+
+	// ITL limits below the unloaded decode time of both replicas
+	if _, err := SplitTraffic(pair, rate, []float32{1, 1}, MinMeanTTFT); err == nil {
+		t.Errorf("expected error for unachievable ITL limits")
+	}
+	if _, err := SplitTraffic(pair, 2*(pair[0].RateRange.Max+pair[1].RateRange.Max), nil, MinMaxTTFT); err == nil {
+		t.Errorf("expected error for a rate over the capacity")
+	}
+}
@@ -0,0 +1,270 @@
+package analyzer
+
+import "math"
+
+// Names of the built-in search strategies, after their Python counterparts in
+// nous/harness/strategies.
+const (
+	FormulaGuidedStrategy         = "formula_guided"
+	NaiveMaxStrategy              = "naive_max"
+	NaiveTernaryStrategy          = "naive_ternary"
+	LinearScanStrategy            = "linear_scan"
+	RatioBinarySearchStrategy     = "ratio_binary_search"
+	AdaptiveEarlyExitStrategy     = "adaptive_early_exit"
+	AdaptiveBinaryStrategy        = "adaptive_binary"
+	AdaptiveInterpolationStrategy = "adaptive_interpolation"
+	PredictorNaiveStrategy        = "predictor_naive"
+	PredictorDirectStrategy       = "predictor_direct"
+	PredictorHybridStrategy       = "predictor_hybrid"
+)
+
+// Minimum R spread between bracket ends for the interpolation step of
+// adaptive_interpolation; below it, bisection avoids amplifying float noise.
+const minRatioSpread = 0.001
+
+// builtinStrategy is a built-in strategy: a named search function.
+type builtinStrategy struct {
+	name   string
+	search func(s *SearchContext) (int, bool)
+	ratio  bool // reads R(M), available from the default oracle only
+}
+
+func (b *builtinStrategy) Name() string                        { return b.name }
+func (b *builtinStrategy) Search(s *SearchContext) (int, bool) { return b.search(s) }
+
+// always feasible: the search only chooses a concurrency, and the
+// confirmatory probe tells whether the SLO is reachable there
+func always(search func(s *SearchContext) int) func(s *SearchContext) (int, bool) {
+	return func(s *SearchContext) (int, bool) { return search(s), true }
+}
+
+// whether a strategy reads R(M)
+func usesRatio(strategy SearchStrategy) bool {
+	b, ok := strategy.(*builtinStrategy)
+	return ok && b.ratio
+}
+
+func init() {
+	for _, b := range []*builtinStrategy{
+		{name: FormulaGuidedStrategy, search: formulaGuided},
+		{name: NaiveMaxStrategy, search: always(naiveMax)},
+		{name: NaiveTernaryStrategy, search: always(naiveTernary)},
+		{name: LinearScanStrategy, search: always(linearScan)},
+		{name: RatioBinarySearchStrategy, search: always(ratioBinarySearch), ratio: true},
+		{name: AdaptiveEarlyExitStrategy, search: always(adaptiveEarlyExit), ratio: true},
+		{name: AdaptiveBinaryStrategy, search: always(adaptiveBinary), ratio: true},
+		{name: AdaptiveInterpolationStrategy, search: always(adaptiveInterpolation), ratio: true},
+		{name: PredictorNaiveStrategy, search: always(predictorNaive), ratio: true},
+		{name: PredictorDirectStrategy, search: always(predictorDirect), ratio: true},
+		{name: PredictorHybridStrategy, search: always(predictorHybrid), ratio: true},
+	} {
+		RegisterSearchStrategy(b)
+	}
+}
+
+// naiveMax returns m_max unconditionally: the plateau-flatness control.
+func naiveMax(s *SearchContext) int {
+	return s.MMax
+}
+
+// naiveTernary is a parameter-blind ternary search maximizing throughput,
+// finished by a scan of the last (at most 3 wide) bracket.
+func naiveTernary(s *SearchContext) int {
+	lo, hi := s.MMin, s.MMax
+	for hi-lo > 2 {
+		m1 := lo + (hi-lo)/3
+		m2 := hi - (hi-lo)/3
+		if s.Throughput(m1) < s.Throughput(m2) {
+			lo = m1
+		} else {
+			hi = m2
+		}
+	}
+	best, bestThr := lo, float32(0)
+	for m := lo; m <= hi; m++ {
+		if thr := s.Throughput(m); thr > bestThr {
+			best, bestThr = m, thr
+		}
+	}
+	return best
+}
+
+// linearScan probes every M and returns the first argmax of throughput.
+func linearScan(s *SearchContext) int {
+	best, bestThr := s.MMin, float32(-1)
+	for m := s.MMin; m <= s.MMax; m++ {
+		if thr := s.Throughput(m); thr > bestThr {
+			best, bestThr = m, thr
+		}
+	}
+	return best
+}
+
+// firstCrossing returns the smallest m in [lo, hi] with R(m) >= 1, by
+// bisection, or def if none is found.
+func firstCrossing(s *SearchContext, lo, hi, def int) int {
+	best := def
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		if s.Ratio(mid) >= 1 {
+			best, hi = mid, mid-1
+		} else {
+			lo = mid + 1
+		}
+	}
+	return best
+}
+
+// ratioBinarySearch bisects [m_min+1, m_max] (M = 1 is always infeasible) for
+// the TTFT-ITL crossover, the smallest M with R(M) >= 1; m_max (on the
+// plateau) if there is none.
+func ratioBinarySearch(s *SearchContext) int {
+	return firstCrossing(s, s.MMin+1, s.MMax, s.MMax)
+}
+
+// adaptiveEarlyExit is ratio_binary_search after a probe at m_max, which
+// exits at m_max if there is no crossover.
+func adaptiveEarlyExit(s *SearchContext) int {
+	if s.Ratio(s.MMax) < 1 {
+		return s.MMax
+	}
+	return firstCrossing(s, s.MMin+1, s.MMax, s.MMax)
+}
+
+// adaptiveStart probes the midpoint of [m_min+1, m_max] first, saving the
+// m_max probe if the crossover is in the lower half, else probes m_max to
+// detect no crossover (done). It returns the bracket [lo, hi] left to
+// search, its best crossing so far, and the ratios at the bracket ends (rLo
+// is NaN if unmeasured).
+func adaptiveStart(s *SearchContext) (lo, hi, best int, rLo, rHi float32, done bool) {
+	midPoint := (s.MMin + 1 + s.MMax) / 2
+	rMid := s.Ratio(midPoint)
+	if rMid >= 1 {
+		return s.MMin + 1, midPoint - 1, midPoint, float32(math.NaN()), rMid, false
+	}
+	rTop := s.Ratio(s.MMax)
+	if rTop < 1 {
+		return 0, 0, s.MMax, 0, 0, true
+	}
+	return midPoint + 1, s.MMax - 1, s.MMax, rMid, rTop, false
+}
+
+// adaptiveBinary is adaptiveStart followed by bisection.
+func adaptiveBinary(s *SearchContext) int {
+	lo, hi, best, _, _, done := adaptiveStart(s)
+	if done {
+		return best
+	}
+	return firstCrossing(s, lo, hi, best)
+}
+
+// adaptiveInterpolation is adaptiveStart followed by interpolation search on
+// R once both bracket ends are measured, else bisection.
+func adaptiveInterpolation(s *SearchContext) int {
+	lo, hi, best, rLo, rHi, done := adaptiveStart(s)
+	if done {
+		return best
+	}
+	for lo <= hi {
+		width := hi - lo
+		mid := (lo + hi) / 2
+		if !math.IsNaN(float64(rLo)) && width >= 1 && rHi-rLo > minRatioSpread {
+			frac := min(1, max(0, (1-rLo)/(rHi-rLo)))
+			// round half to even, as Python's round
+			mid = lo + int(math.RoundToEven(float64(width)*float64(frac)))
+			mid = max(lo, min(hi, mid))
+		}
+		if r := s.Ratio(mid); r >= 1 {
+			best, hi, rHi = mid, mid-1, r
+		} else {
+			lo, rLo = mid+1, r
+		}
+	}
+	return best
+}
+
+// predictCrossover is the closed-form (regression-fit, RP-9) predictor of the
+// TTFT-ITL crossover, from the service parameters, request size and targets;
+// false if it classifies the scenario as no-crossover or the prediction falls
+// outside [m_min, m_max]. Unlike the Python predictors, which fix the service
+// parameters and read the anchors of all scenarios out of band, it uses the
+// optimizer's own parameters, hence a single anchor.
+func predictCrossover(s *SearchContext) (int, bool) {
+	alpha := float64(s.ServiceParms.Alpha)
+	beta := float64(s.ServiceParms.Beta)
+	gamma := float64(s.ServiceParms.Gamma)
+	in := float64(s.RequestSize.AvgInputTokens)
+	out := float64(s.RequestSize.AvgOutputTokens)
+	targetITL := float64(s.Target.TargetITL)
+	targetTTFT := float64(s.Target.TargetTTFT)
+
+	tc := (in + out) / (out + 1)
+	tm := in + out/2
+	slope := beta*tc + gamma*tm
+	intercept := alpha + beta + gamma*(in+(out+1)/2)
+	if slope <= 0 {
+		return 0, false
+	}
+	nITL := (targetITL - intercept) / slope
+	if nITL <= 0 {
+		return 0, false
+	}
+	waitBudget := targetTTFT - targetITL - (alpha + nITL*slope + (beta+gamma)*in)
+	if waitBudget <= 0 {
+		return 0, false
+	}
+	mEst := int(math.RoundToEven(nITL + 3*math.Sqrt(nITL) + 0.05*waitBudget))
+	return mEst, mEst >= s.MMin && mEst <= s.MMax
+}
+
+// predictorNaive returns the predicted crossover without refinement (the
+// ablation of predictor_direct), after probing it as the anchor.
+func predictorNaive(s *SearchContext) int {
+	mEst, ok := predictCrossover(s)
+	if !ok {
+		return s.MMax
+	}
+	s.Ratio(mEst)
+	return mEst
+}
+
+// predictorDirect trusts the predictor to within 2: it probes the predicted
+// crossover, then scans at most 2 caps below it (crossover at or below) or
+// above it (crossover above), else m_max (no crossover).
+func predictorDirect(s *SearchContext) int {
+	mEst, ok := predictCrossover(s)
+	if !ok {
+		return s.MMax
+	}
+	if s.Ratio(mEst) >= 1 {
+		lo := max(s.MMin, mEst-2)
+		for m := mEst - 1; m >= lo; m-- {
+			if s.Ratio(m) < 1 {
+				return m + 1
+			}
+		}
+		return lo
+	}
+	for m := mEst + 1; m <= min(mEst+2, s.MMax); m++ {
+		if s.Ratio(m) >= 1 {
+			return m
+		}
+	}
+	return s.MMax
+}
+
+// predictorHybrid probes the predicted crossover, then bisects the window of
+// width 3 below or above it; full-range bisection without a prediction.
+func predictorHybrid(s *SearchContext) int {
+	mEst, ok := predictCrossover(s)
+	if !ok {
+		return firstCrossing(s, s.MMin, s.MMax, s.MMin)
+	}
+	if s.Ratio(mEst) >= 1 {
+		lo := max(s.MMin, mEst-2)
+		return firstCrossing(s, lo, mEst, lo)
+	}
+	// without a crossing in the window, its low end sits on the plateau
+	lo := min(mEst+1, s.MMax)
+	return firstCrossing(s, lo, min(s.MMax, lo+2), lo)
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"slices"
+	"testing"
+)
+
+// fixedStrategy returns its concurrency without probing.
+type fixedStrategy int
+
+func (f fixedStrategy) Name() string                        { return "fixed" }
+func (f fixedStrategy) Search(s *SearchContext) (int, bool) { return int(f), true }
+
+func TestSearchStrategyRegistry(t *testing.T) {
+	names := SearchStrategyNames()
+	for _, name := range []string{FormulaGuidedStrategy, NaiveMaxStrategy, NaiveTernaryStrategy,
+		RatioBinarySearchStrategy, AdaptiveBinaryStrategy, PredictorHybridStrategy} {
+		if !slices.Contains(names, name) {
+			t.Errorf("strategy %s not registered: %v", name, names)
+		}
+	}
+	if s, err := SearchStrategyByName(""); err != nil || s.Name() != FormulaGuidedStrategy {
+		t.Errorf("default strategy: got %v, %v", s, err)
+	}
+	if _, err := SearchStrategyByName("no_such_strategy"); err == nil {
+		t.Error("expected error for an unknown strategy")
+	}
+
+	RegisterSearchStrategy(fixedStrategy(7))
+	defer delete(searchStrategies, "fixed")
+	sp, rs := baselineParts()
+	o := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target:   &TargetPerf{TargetTTFT: 60, TargetITL: 20},
+		Strategy: "fixed", Oracle: plateauOracle(60, 0, nil)}
+	res, err := o.Find()
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	// the confirmatory probe only
+	if res.Strategy != "fixed" || res.Concurrency != 7 || res.Calls != 1 || res.AnchorThroughput != 0 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestRatioStrategyRequiresDefaultOracle(t *testing.T) {
+	sp, rs := baselineParts()
+	o := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target:   &TargetPerf{TargetTTFT: 60, TargetITL: 20},
+		Strategy: RatioBinarySearchStrategy, Oracle: plateauOracle(60, 0, nil)}
+	if _, err := o.Find(); err == nil {
+		t.Error("expected error for a ratio strategy with a custom oracle")
+	}
+	o.Strategy, o.Noisy = NaiveMaxStrategy, true
+	if _, err := o.Find(); err == nil {
+		t.Error("expected error for a noisy search by another strategy")
+	}
+}
+
+func TestCurveOfPlateau(t *testing.T) {
+	sp, rs := baselineParts()
+	o := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target: &TargetPerf{TargetTTFT: 60, TargetITL: 20},
+		MMax:   100, Oracle: plateauOracle(60, 0, nil)}
+	c, err := o.Curve()
+	if err != nil {
+		t.Fatalf("Curve: %v", err)
+	}
+	// f(59) = 9.83 < 0.98 * 10 <= f(60)
+	if len(c.Throughput) != 100 || c.MTruth != 60 || c.ThroughputTruth != 10 || c.MOnset != 59 {
+		t.Errorf("got M_truth=%d f=%v onset=%d over %d points", c.MTruth, c.ThroughputTruth, c.MOnset, len(c.Throughput))
+	}
+	if c.At(30) != 5 || c.At(0) != 0 || c.At(101) != 0 {
+		t.Errorf("got f(30)=%v f(0)=%v f(101)=%v", c.At(30), c.At(0), c.At(101))
+	}
+
+	_, scores, err := o.CompareStrategies()
+	if err != nil {
+		t.Fatalf("CompareStrategies: %v", err)
+	}
+	for _, s := range scores {
+		if s.Strategy == RatioBinarySearchStrategy {
+			t.Errorf("ratio strategy run with a custom oracle")
+		}
+		if s.Strategy == LinearScanStrategy && (s.GapM != 0 || s.Calls != 101) {
+			t.Errorf("linear scan: %+v", s)
+		}
+	}
+}
+
+func TestCompareStrategiesBaseline(t *testing.T) {
+	sp, rs := baselineParts()
+	o := &ConcurrencyOptimizer{ServiceParms: sp, RequestSize: rs,
+		Target: &TargetPerf{TargetTTFT: 60, TargetITL: 20}, MaxQueueSize: 128}
+	curve, scores, err := o.CompareStrategies()
+	if err != nil {
+		t.Fatalf("CompareStrategies: %v", err)
+	}
+	if len(scores) != len(SearchStrategyNames()) {
+		t.Fatalf("got %d scores for %d strategies", len(scores), len(SearchStrategyNames()))
+	}
+	for _, s := range scores {
+		if s.Concurrency < 1 || s.Concurrency > 256 || s.Calls < 1 || s.GapThroughputRel < 0 {
+			t.Errorf("%s: %+v", s.Strategy, s)
+		}
+		switch s.Strategy {
+		case FormulaGuidedStrategy:
+			if s.Calls > 8 || s.GapThroughputRel > DefaultOnsetEpsilon {
+				t.Errorf("formula guided: %+v", s)
+			}
+		case NaiveMaxStrategy:
+			if s.Calls != 1 || s.Concurrency != 256 {
+				t.Errorf("naive max: %+v", s)
+			}
+		case LinearScanStrategy:
+			if s.Concurrency != curve.MTruth || s.GapThroughputRel != 0 {
+				t.Errorf("linear scan: %+v, M_truth=%d", s, curve.MTruth)
+			}
+		case RatioBinarySearchStrategy, AdaptiveBinaryStrategy, AdaptiveInterpolationStrategy:
+			// all locate the same TTFT-ITL crossover
+			if s.Concurrency != scores[slices.IndexFunc(scores, func(x *StrategyScore) bool {
+				return x.Strategy == AdaptiveEarlyExitStrategy
+			})].Concurrency {
+				t.Errorf("%s: crossover %d differs from adaptive early exit", s.Strategy, s.Concurrency)
+			}
+		}
+	}
+}
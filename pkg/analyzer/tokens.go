@@ -0,0 +1,228 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// number of points of a discretized lognormal distribution, and max number of points of an
+// empirical distribution (samples are grouped into equally likely points)
+const MaxTokenPoints = 32
+
+// distribution of the number of tokens per request, discretized to weighted points
+type TokenDistribution struct {
+	Values  []float32 // number of tokens at the points
+	Weights []float32 // relative weights of the points (nil => equally likely)
+}
+
+// histogram of the number of tokens: bin values (e.g. bin centers) and counts (or probabilities)
+func NewHistogramDistribution(values, counts []float32) *TokenDistribution {
+	return &TokenDistribution{Values: slices.Clone(values), Weights: slices.Clone(counts)}
+}
+
+// lognormal number of tokens with given mean and coefficient of variation, discretized to
+// MaxTokenPoints equally likely points, each at the conditional mean of its quantile bin
+// (the mean is preserved exactly)
+func NewLognormalDistribution(mean, cv float32) *TokenDistribution {
+	if cv <= 0 {
+		return &TokenDistribution{Values: []float32{mean}}
+	}
+	sigma := math.Sqrt(math.Log(1 + float64(cv*cv)))
+	// E[X; z_i < Z < z_i+1] = mean * (Phi(z_i+1 - sigma) - Phi(z_i - sigma)), with Phi(z_i) = i/n
+	phi := func(z float64) float64 { return 0.5 * math.Erfc(-z/math.Sqrt2) }
+	n := MaxTokenPoints
+	values := make([]float32, n)
+	prev := phi(math.Inf(-1))
+	for i := range n {
+		z := math.Sqrt2 * math.Erfinv(2*float64(i+1)/float64(n)-1)
+		next := phi(z - sigma)
+		values[i] = mean * float32(float64(n)*(next-prev))
+		prev = next
+	}
+	return &TokenDistribution{Values: values}
+}
+
+// empirical number of tokens from samples, grouped into at most MaxTokenPoints equally likely
+// points, each at the mean of its group of sorted samples
+func NewEmpiricalDistribution(samples []float32) *TokenDistribution {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	n := min(len(sorted), MaxTokenPoints)
+	values := make([]float32, n)
+	weights := make([]float32, n)
+	for i := range n {
+		group := sorted[i*len(sorted)/n : (i+1)*len(sorted)/n]
+		var sum float64
+		for _, v := range group {
+			sum += float64(v)
+		}
+		values[i] = float32(sum / float64(len(group)))
+		weights[i] = float32(len(group))
+	}
+	return &TokenDistribution{Values: values, Weights: weights}
+}
+
+// probabilities of the points
+func (d *TokenDistribution) probabilities() []float64 {
+	probs := make([]float64, len(d.Values))
+	var total float64
+	for i := range probs {
+		probs[i] = 1
+		if d.Weights != nil {
+			probs[i] = float64(d.Weights[i])
+		}
+		total += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= total
+	}
+	return probs
+}
+
+// first and second moments of the number of tokens
+func (d *TokenDistribution) moments() (mean, secondMoment float64) {
+	for i, p := range d.probabilities() {
+		v := float64(d.Values[i])
+		mean += p * v
+		secondMoment += p * v * v
+	}
+	return mean, secondMoment
+}
+
+// mean number of tokens
+func (d *TokenDistribution) Mean() float32 {
+	mean, _ := d.moments()
+	return float32(mean)
+}
+
+// squared coefficient of variation of the number of tokens
+func (d *TokenDistribution) SCV() float32 {
+	mean, secondMoment := d.moments()
+	if mean == 0 {
+		return 0
+	}
+	return float32(max(secondMoment/(mean*mean)-1, 0))
+}
+
+// per batch size service quantities of a request size with token distributions, integrating the
+// work decomposition over the input and output tokens (independent). Each input point has its own
+// chunk count at batch size B. With E[.] over the joint points:
+//
+//	delta(B)   = (E[w_prefill] + E[w_decode]) / E[c+m]
+//	tau(B)     = E[c+m] * (alpha + B*delta(B))
+//	prefill(B) = E[c] * (alpha + (B-1)*delta(B)) + E[w_prefill]
+//	itl(B)     = alpha + (B-1)*delta(B) + beta + gamma*(E[n] + (E[m^2]/E[m] + 1)/2)
+//
+// where the decode term of the itl is averaged over tokens rather than requests, so long outputs
+// weigh in proportionally. The squared coefficient of variation of the service time, with the
+// iteration time fixed at batch size B, is Var[c+m] / E[c+m]^2.
+type tokenProfile struct {
+	numChunks    []float32 // numChunks[B] = mean number of prefill chunks at batch size B
+	prefillWork  []float32 // prefillWork[B] = mean prefill work at batch size B
+	numIters     []float32 // numIters[B] = mean number of iterations (chunks and output tokens) at batch size B
+	delta        []float32 // delta[B] = mean work per request per iteration at batch size B
+	scv          []float32 // scv[B] = squared coefficient of variation of the service time at batch size B
+	decodeTokens float32   // token-weighted context of a decode step, E[n] + (E[m^2]/E[m] + 1)/2
+}
+
+// token profile of a configuration and request size, nil if the request size has no token distributions
+func newTokenProfile(c *Configuration, r *RequestSize) *tokenProfile {
+	if r.InputTokens == nil && r.OutputTokens == nil {
+		return nil
+	}
+	p := c.ServiceParms
+	inValues, inProbs := []float32{r.AvgInputTokens}, []float64{1}
+	inMean := float64(r.AvgInputTokens)
+	if r.InputTokens != nil {
+		inValues, inProbs = r.InputTokens.Values, r.InputTokens.probabilities()
+		inMean, _ = r.InputTokens.moments()
+	}
+	outMean, outSecondMoment := float64(r.AvgOutputTokens), float64(r.AvgOutputTokens*r.AvgOutputTokens)
+	if r.OutputTokens != nil {
+		outMean, outSecondMoment = r.OutputTokens.moments()
+	}
+	beta, gamma := float64(p.Beta), float64(p.Gamma)
+
+	// decode work is independent of the chunk count: E[beta*m + gamma*m*(n + (m+1)/2)]
+	decodeWork := beta*outMean + gamma*(outMean*inMean+(outSecondMoment+outMean)/2)
+
+	// chunk count tables of the input points
+	tables := make([][]int, len(inValues))
+	for i, in := range inValues {
+		tables[i] = NumIterationsPerPrefill(c, &RequestSize{AvgInputTokens: in, AvgOutputTokens: r.AvgOutputTokens})
+	}
+
+	tp := &tokenProfile{
+		numChunks:    make([]float32, c.MaxBatchSize+1),
+		prefillWork:  make([]float32, c.MaxBatchSize+1),
+		numIters:     make([]float32, c.MaxBatchSize+1),
+		delta:        make([]float32, c.MaxBatchSize+1),
+		scv:          make([]float32, c.MaxBatchSize+1),
+		decodeTokens: float32(inMean + (outSecondMoment/outMean+1)/2),
+	}
+	outVariance := max(outSecondMoment-outMean*outMean, 0)
+	for B := 1; B <= c.MaxBatchSize; B++ {
+		var chunks, chunksSecondMoment, prefillWork float64
+		for i, in := range inValues {
+			nc := float64(tables[i][B])
+			chunks += inProbs[i] * nc
+			chunksSecondMoment += inProbs[i] * nc * nc
+			prefillWork += inProbs[i] * (beta + gamma*(nc+1)/2) * float64(in)
+		}
+		iters := chunks + outMean
+		tp.numChunks[B] = float32(chunks)
+		tp.prefillWork[B] = float32(prefillWork)
+		tp.numIters[B] = float32(iters)
+		tp.delta[B] = float32((prefillWork + decodeWork) / iters)
+		tp.scv[B] = float32((max(chunksSecondMoment-chunks*chunks, 0) + outVariance) / (iters * iters))
+	}
+	return tp
+}
+
+// mean request service time at batch size B
+func (tp *tokenProfile) tau(p *ServiceParms, batchSize int) float32 {
+	return tp.numIters[batchSize] * (p.Alpha + float32(batchSize)*tp.delta[batchSize])
+}
+
+// background iteration time at a (possibly fractional) batch size, with the chunk statistics at index idx
+func (tp *tokenProfile) background(p *ServiceParms, batchSize float32, idx int) float32 {
+	return max(p.Alpha+(batchSize-1)*tp.delta[idx], 0)
+}
+
+// mean prefill time at a (possibly fractional) batch size, with the chunk statistics at index idx
+func (tp *tokenProfile) prefill(p *ServiceParms, batchSize float32, idx int) float32 {
+	return tp.numChunks[idx]*tp.background(p, batchSize, idx) + tp.prefillWork[idx]
+}
+
+// mean inter-token latency at a (possibly fractional) batch size, with the chunk statistics at index idx
+func (tp *tokenProfile) itl(p *ServiceParms, batchSize float32, idx int) float32 {
+	return tp.background(p, batchSize, idx) + p.Beta + p.Gamma*tp.decodeTokens
+}
+
+// check validity of a token distribution
+func (d *TokenDistribution) check() error {
+	if len(d.Values) == 0 || (d.Weights != nil && len(d.Weights) != len(d.Values)) {
+		return fmt.Errorf("invalid token distribution %s", d)
+	}
+	var total float32
+	for i, v := range d.Values {
+		if v < 0 || (d.Weights != nil && d.Weights[i] < 0) {
+			return fmt.Errorf("invalid token distribution %s", d)
+		}
+		if d.Weights != nil {
+			total += d.Weights[i]
+		}
+	}
+	if d.Weights != nil && total <= 0 {
+		return fmt.Errorf("invalid token distribution %s", d)
+	}
+	return nil
+}
+
+func (d *TokenDistribution) String() string {
+	if d == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{points=%d, mean=%.1f, scv=%.3f}", len(d.Values), d.Mean(), d.SCV())
+}
@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTokenDistributionMoments(t *testing.T) {
+	lognormal := NewLognormalDistribution(1024, 1)
+	if got := lognormal.Mean(); math.Abs(float64(got-1024)) > 0.5 {
+		t.Errorf("lognormal mean: got %v, want 1024", got)
+	}
+	// discretization at the conditional means of the bins loses some of the variance
+	if scv := lognormal.SCV(); scv < 0.8 || scv > 1 {
+		t.Errorf("lognormal scv: got %v, want in [0.8, 1]", scv)
+	}
+
+	samples := make([]float32, 1000)
+	var sum float32
+	for i := range samples {
+		samples[i] = float32((i * 37) % 500)
+		sum += samples[i]
+	}
+	empirical := NewEmpiricalDistribution(samples)
+	if len(empirical.Values) != MaxTokenPoints {
+		t.Errorf("empirical points: got %d, want %d", len(empirical.Values), MaxTokenPoints)
+	}
+	if got, want := empirical.Mean(), sum/float32(len(samples)); math.Abs(float64(got-want)) > 1e-3*float64(want) {
+		t.Errorf("empirical mean: got %v, want %v", got, want)
+	}
+
+	histogram := NewHistogramDistribution([]float32{100, 300}, []float32{3, 1})
+	if got := histogram.Mean(); got != 150 {
+		t.Errorf("histogram mean: got %v, want 150", got)
+	}
+	if err := NewHistogramDistribution([]float32{100}, []float32{1, 2}).check(); err == nil {
+		t.Errorf("expected error for mismatched histogram")
+	}
+}
+
+func TestPointMassDistributionsMatchAverages(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp}
+	base, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	pointMass := &RequestSize{
+		InputTokens:  NewHistogramDistribution([]float32{rs.AvgInputTokens}, []float32{1}),
+		OutputTokens: NewHistogramDistribution([]float32{rs.AvgOutputTokens}, []float32{1}),
+	}
+	qa, err := NewLLMQueueAnalyzer(cfg, pointMass)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	if qa.RequestSize.AvgInputTokens != rs.AvgInputTokens || qa.RequestSize.AvgOutputTokens != rs.AvgOutputTokens {
+		t.Errorf("averages not set from distributions: %s", qa.RequestSize)
+	}
+
+	rate := 0.8 * base.RateRange.Max
+	want, err := base.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	got, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	near := func(a, b float32) bool { return math.Abs(float64(a-b)) <= 1e-4*math.Abs(float64(b)) }
+	if !near(qa.RateRange.Max, base.RateRange.Max) || !near(got.AvgTTFT, want.AvgTTFT) ||
+		!near(got.AvgTokenTime, want.AvgTokenTime) || !near(got.ExactTTFT, want.ExactTTFT) {
+		t.Errorf("point mass: got %s, want %s", got, want)
+	}
+	for B, scv := range qa.ServiceSCV {
+		if scv != 0 {
+			t.Errorf("point mass service scv at B=%d: got %v, want 0", B, scv)
+		}
+	}
+}
+
+func TestOutputVarianceSlowsService(t *testing.T) {
+	sp, rs := baselineParts()
+	cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp}
+	base, err := NewLLMQueueAnalyzer(cfg, rs)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	spread := &RequestSize{
+		AvgInputTokens: rs.AvgInputTokens,
+		OutputTokens:   NewLognormalDistribution(rs.AvgOutputTokens, 1.5),
+	}
+	qa, err := NewLLMQueueAnalyzer(cfg, spread)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+
+	// long outputs decode in longer contexts: more work per request at the same mean
+	if qa.RateRange.Max >= base.RateRange.Max {
+		t.Errorf("output variance should lower the max rate: %v >= %v", qa.RateRange.Max, base.RateRange.Max)
+	}
+	scv := qa.ServiceSCV[qa.MaxBatchSize]
+	if outSCV := spread.OutputTokens.SCV(); scv <= 0 || scv > outSCV {
+		t.Errorf("service scv: got %v, want in (0, %v]", scv, outSCV)
+	}
+
+	rate := 0.5 * qa.RateRange.Max
+	want, err := base.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	got, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got.AvgTokenTime <= want.AvgTokenTime {
+		t.Errorf("output variance should raise the ITL: %v <= %v", got.AvgTokenTime, want.AvgTokenTime)
+	}
+}
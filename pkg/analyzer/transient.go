@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+)
+
+// tolerance on the total probability of an initial state distribution
+const distributionTolerance = 1e-6
+
+// transient performance metrics at a point in time
+type TransientMetrics struct {
+	Time           float32 // time since the start (msec)
+	AvgNumInSystem float32 // average number of requests in system
+	AvgQueueLength float32 // average number of requests waiting
+	AvgNumInServ   float32 // average number of requests in service
+	Throughput     float32 // departure rate (requests/sec)
+	DropRate       float32 // rate of requests blocked by a full queue (requests/sec)
+	AvgWaitTime    float32 // expected queueing time of a request arriving at this time (msec)
+	AvgTTFT        float32 // approximate time to first token of a request arriving at this time (msec)
+}
+
+// evaluate the transient response of the queue from an initial state distribution, under a (new)
+// request rate, at every step (msec) up to the horizon (msec).
+// The initial distribution gives the probability of n requests in system, n = 0, ..., K
+// (see StateDistribution); metrics at time zero are included.
+// The uniformized chain has no reneging and exponential service: abandonment and general service
+// are not supported.
+func (qa *LLMQueueAnalyzer) AnalyzeTransient(initial []float64, requestRate float32, horizon float32,
+	step float32) ([]*TransientMetrics, error) {
+	if requestRate <= 0 {
+		return nil, fmt.Errorf("invalid request rate %v", requestRate)
+	}
+	if horizon <= 0 || step <= 0 {
+		return nil, fmt.Errorf("invalid horizon %v or step %v", horizon, step)
+	}
+	if err := qa.checkDistribution(initial); err != nil {
+		return nil, err
+	}
+	if qa.Abandonment != nil {
+		return nil, fmt.Errorf("abandonment is not supported with transient analysis")
+	}
+	if qa.GeneralService {
+		return nil, fmt.Errorf("general service is not supported with transient analysis")
+	}
+
+	numSteps := int(math.Ceil(float64(horizon / step)))
+	times := make([]float32, numSteps+1)
+	for i := range times {
+		times[i] = min(float32(i)*step, horizon)
+	}
+
+	lambda := requestRate / 1000
+	dists, err := qa.Model.SolveTransient(lambda, initial, times)
+	if err != nil {
+		return nil, err
+	}
+
+	data := qa.evalFuncData()
+	series := make([]*TransientMetrics, len(times))
+	for i, p := range dists {
+		sm := qa.Model.Measures(lambda, p)
+		metrics := &TransientMetrics{
+			Time:           times[i],
+			AvgNumInSystem: sm.AvgNumInSystem,
+			AvgQueueLength: sm.AvgQueueLength,
+			AvgNumInServ:   sm.AvgNumInServers,
+			Throughput:     sm.Throughput * 1000,
+			DropRate:       sm.DropRate * 1000,
+			AvgWaitTime:    sm.AvgWaitTime,
+		}
+
+		// mean-field prefill and decode times at the current batch size; an empty system serves at batch size 1
+		batchSize := max(meanFieldBatchSize(sm.AvgNumInServers, qa.NumReplicas, qa.MaxBatchSize, qa.Dispatch), 1)
+		prefillTime := data.prefillTime(batchSize)
+		decodeTime := data.itlTime(batchSize)
+		if sm.Throughput > 0 && sm.AvgNumInServers > 0 {
+			decodeTime = (sm.AvgNumInServers/sm.Throughput - prefillTime) / qa.RequestSize.AvgOutputTokens
+		}
+		metrics.AvgTTFT = sm.AvgWaitTime + prefillTime + decodeTime
+		series[i] = metrics
+	}
+	return series, nil
+}
+
+// initial state distribution with exactly numInSystem requests in system (waiting and in service),
+// e.g. N waiting requests behind full batches: numInSystem = N + NumReplicas * MaxBatchSize
+func (qa *LLMQueueAnalyzer) StateDistribution(numInSystem int) []float64 {
+	p := make([]float64, qa.Model.K+1)
+	p[min(max(numInSystem, 0), qa.Model.K)] = 1
+	return p
+}
+
+// time at which the TTFT of a transient trajectory is back within target and stays so
+// until the end of the trajectory; returns -1 if not within target at the end
+func RecoveryTime(series []*TransientMetrics, targetTTFT float32) float32 {
+	recovery := float32(-1)
+	for _, m := range series {
+		if m.AvgTTFT > targetTTFT {
+			recovery = -1
+		} else if recovery < 0 {
+			recovery = m.Time
+		}
+	}
+	return recovery
+}
+
+// check validity of a state distribution
+func (qa *LLMQueueAnalyzer) checkDistribution(p []float64) error {
+	if len(p) != qa.Model.K+1 {
+		return fmt.Errorf("invalid state distribution length %d, want %d", len(p), qa.Model.K+1)
+	}
+	var total float64
+	for _, pn := range p {
+		if pn < 0 {
+			return fmt.Errorf("invalid state distribution %v", p)
+		}
+		total += pn
+	}
+	if math.Abs(total-1) > distributionTolerance {
+		return fmt.Errorf("state distribution sums to %v", total)
+	}
+	return nil
+}
+
+func (tm *TransientMetrics) String() string {
+	return fmt.Sprintf("{t=%.1f, num=%.3f, queue=%.3f, conc=%.3f, tput=%.3f, drop=%.3f, wait=%.3f, ttft=%.3f}",
+		tm.Time, tm.AvgNumInSystem, tm.AvgQueueLength, tm.AvgNumInServ, tm.Throughput, tm.DropRate,
+		tm.AvgWaitTime, tm.AvgTTFT)
+}
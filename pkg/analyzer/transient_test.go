@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/queue"
+)
+
+func TestAnalyzeTransientRecovery(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	rate := 0.7 * qa.RateRange.Max
+	steady, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	// 200 requests waiting behind a full batch
+	initial := qa.StateDistribution(200 + qa.MaxBatchSize)
+	series, err := qa.AnalyzeTransient(initial, rate, 600_000, 10_000)
+	if err != nil {
+		t.Fatalf("AnalyzeTransient: %v", err)
+	}
+	if len(series) != 61 || series[0].Time != 0 || series[60].Time != 600_000 {
+		t.Fatalf("unexpected time grid: %d points, last at %v", len(series), series[len(series)-1].Time)
+	}
+	if series[0].AvgQueueLength != 200 {
+		t.Errorf("initial queue length: got %v, want 200", series[0].AvgQueueLength)
+	}
+	last := series[len(series)-1]
+	if math.Abs(float64(last.Throughput-steady.Throughput)) > 1e-2*float64(steady.Throughput) {
+		t.Errorf("final throughput %v, steady state %v", last.Throughput, steady.Throughput)
+	}
+	if math.Abs(float64(last.AvgTTFT-steady.AvgTTFT)) > 0.05*float64(steady.AvgTTFT) {
+		t.Errorf("final TTFT %v, steady state %v", last.AvgTTFT, steady.AvgTTFT)
+	}
+
+	target := 2 * steady.AvgTTFT
+	recovery := RecoveryTime(series, target)
+	if recovery <= 0 || recovery >= 600_000 {
+		t.Errorf("recovery time %v not within the horizon", recovery)
+	}
+	if series[0].AvgTTFT <= target {
+		t.Errorf("initial TTFT %v should exceed target %v", series[0].AvgTTFT, target)
+	}
+}
+
+func TestAnalyzeTransientRejectsInvalidDistribution(t *testing.T) {
+	qa := newBaselineAnalyzer(t, 64, 256)
+	if _, err := qa.AnalyzeTransient([]float64{1}, 1, 1000, 100); err == nil {
+		t.Error("expected error for short distribution, got nil")
+	}
+}
+
+func TestAnalyzeTransientRejectsUnsupported(t *testing.T) {
+	sp, rs := baselineParts()
+	for _, cfg := range []*Configuration{
+		{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, Abandonment: &Abandonment{PatienceTime: 1000}},
+		{MaxBatchSize: 64, MaxQueueSize: 256, ServiceParms: sp, GeneralService: true, ServiceSCV: 2},
+	} {
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		if _, err := qa.AnalyzeTransient(qa.StateDistribution(0), 1, 1000, 100); err == nil {
+			t.Errorf("expected error for configuration %s", cfg)
+		}
+	}
+}
+
+func TestAnalyzeTransientDispatch(t *testing.T) {
+	sp, rs := baselineParts()
+	for _, dispatch := range []queue.DispatchPolicy{queue.BalancedDispatch, queue.PackedDispatch} {
+		cfg := &Configuration{MaxBatchSize: 64, MaxQueueSize: 256, NumReplicas: 4, Dispatch: dispatch, ServiceParms: sp}
+		qa, err := NewLLMQueueAnalyzer(cfg, rs)
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		rate := 0.5 * qa.RateRange.Max
+		steady, err := qa.Analyze(rate)
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		series, err := qa.AnalyzeTransient(qa.StateDistribution(0), rate, 2_000_000, 1_000_000)
+		if err != nil {
+			t.Fatalf("AnalyzeTransient: %v", err)
+		}
+		// in steady state, the transient TTFT is that of the analyzer, at the batch size of the dispatching rule
+		last := series[len(series)-1]
+		if math.Abs(float64(last.AvgTTFT-steady.AvgTTFT)) > 1e-3*float64(steady.AvgTTFT) {
+			t.Errorf("%s: final TTFT %v, steady state %v", dispatch, last.AvgTTFT, steady.AvgTTFT)
+		}
+	}
+}
@@ -38,8 +38,11 @@ func NumIterationsPerPrefillForBatchSize(batchSizes []int, batchSize int) int {
 }
 
 func NumIterationsPerPrefill(cfg *Configuration, req *RequestSize) []int {
-	batchSizes := CalculateBatchSizes(cfg, req)
 	numIters := make([]int, cfg.MaxBatchSize+1)
+	if cfg.noPrefill {
+		return numIters
+	}
+	batchSizes := CalculateBatchSizes(cfg, req)
 	for batchSize := 1; batchSize <= cfg.MaxBatchSize; batchSize++ {
 		numIters[batchSize] = NumIterationsPerPrefillForBatchSize(batchSizes, batchSize)
 	}
@@ -48,18 +51,46 @@ func NumIterationsPerPrefill(cfg *Configuration, req *RequestSize) []int {
 
 // check validity of configuration parameters
 func (c *Configuration) check() error {
-	if c.MaxBatchSize <= 0 || c.MaxQueueSize < 0 || c.MaxNumTokens < 0 ||
-		c.ServiceParms == nil {
+	if c.MaxBatchSize <= 0 || c.MaxQueueSize < 0 || c.MaxNumTokens < 0 || c.NumReplicas < 0 ||
+		c.ServiceParms == nil || c.KVCacheTokens < 0 || c.KVCacheBlocks < 0 || c.KVBlockSize < 0 || c.ServiceSCV < 0 {
 		return fmt.Errorf("invalid configuration %s", c)
 	}
+	if c.GeneralService && c.Abandonment != nil {
+		return fmt.Errorf("general service is not supported with abandonment %s", c)
+	}
 	if c.MaxNumTokens == 0 {
 		c.MaxNumTokens = DefaultMaxNumTokens
 	}
+	if c.Abandonment != nil {
+		return c.Abandonment.check()
+	}
+	return nil
+}
+
+// check validity of abandonment parameters
+func (a *Abandonment) check() error {
+	if a.PatienceTime <= 0 || a.RetryProb < 0 || a.RetryProb >= 1 {
+		return fmt.Errorf("invalid abandonment %s", a)
+	}
 	return nil
 }
 
 // check validity of request size
 func (rq *RequestSize) check() error {
+	for _, d := range []*TokenDistribution{rq.InputTokens, rq.OutputTokens} {
+		if d == nil {
+			continue
+		}
+		if err := d.check(); err != nil {
+			return err
+		}
+	}
+	if rq.InputTokens != nil {
+		rq.AvgInputTokens = rq.InputTokens.Mean()
+	}
+	if rq.OutputTokens != nil {
+		rq.AvgOutputTokens = rq.OutputTokens.Mean()
+	}
 	if rq.AvgInputTokens < 0 || rq.AvgOutputTokens < 1 {
 		return fmt.Errorf("invalid request size %s", rq)
 	}
@@ -70,24 +101,46 @@ func (rq *RequestSize) check() error {
 func (targetPerf *TargetPerf) check() error {
 	if targetPerf.TargetITL < 0 ||
 		targetPerf.TargetTTFT < 0 ||
-		targetPerf.TargetTPS < 0 {
+		targetPerf.TargetTPS < 0 ||
+		targetPerf.TargetUserTPS < 0 ||
+		targetPerf.TargetPctTTFT < 0 ||
+		targetPerf.TargetPctRespTime < 0 ||
+		targetPerf.MaxAbandonFraction < 0 || targetPerf.MaxAbandonFraction >= 1 ||
+		targetPerf.Percentile < 0 || targetPerf.Percentile >= 1 {
 		return fmt.Errorf("invalid target data values %s", targetPerf)
 	}
 	return nil
 }
 
+// targets of a replica serving a share of a request rate: the aggregate TPS target, if any, is met
+// by the request rate itself and is not applied per replica
+func (targetPerf *TargetPerf) PerReplica() *TargetPerf {
+	target := *targetPerf
+	target.TargetTPS = 0
+	return &target
+}
+
 /*
  * toString() functions
  */
 
 func (c *Configuration) String() string {
-	return fmt.Sprintf("{maxBatch=%d, maxNumTokens=%d, maxQueue=%d, servParms:%s}",
-		c.MaxBatchSize, c.MaxNumTokens, c.MaxQueueSize, c.ServiceParms)
+	return fmt.Sprintf("{maxBatch=%d, maxNumTokens=%d, maxQueue=%d, replicas=%d, dispatch=%s, servParms:%s, abandon:%s, "+
+		"kvTokens=%d, kvBlocks=%d, kvBlockSize=%d, generalService=%t, scv=%.3f}",
+		c.MaxBatchSize, c.MaxNumTokens, c.MaxQueueSize, c.NumReplicas, c.Dispatch, c.ServiceParms, c.Abandonment,
+		c.KVCacheTokens, c.KVCacheBlocks, c.KVBlockSize, c.GeneralService, c.ServiceSCV)
+}
+
+func (a *Abandonment) String() string {
+	if a == nil {
+		return "<nil>"
+	}
+	return fmt.Sprintf("{patience=%s, patienceTime=%.3f, retryProb=%.3f}", a.Patience, a.PatienceTime, a.RetryProb)
 }
 
 func (qa *LLMQueueAnalyzer) String() string {
-	return fmt.Sprintf("{maxBatch=%d, maxNumTokens=%d, maxQueue=%d, servParms:%s, reqSize:%s, model:%s, rates:%s}",
-		qa.MaxBatchSize, qa.MaxNumTokens, qa.MaxQueueSize, qa.ServiceParms, qa.RequestSize, qa.Model, qa.RateRange)
+	return fmt.Sprintf("{maxBatch=%d, maxNumTokens=%d, maxQueue=%d, replicas=%d, servParms:%s, reqSize:%s, model:%s, rates:%s}",
+		qa.MaxBatchSize, qa.MaxNumTokens, qa.MaxQueueSize, qa.NumReplicas, qa.ServiceParms, qa.RequestSize, qa.Model, qa.RateRange)
 }
 
 func (sp *ServiceParms) String() string {
@@ -95,6 +148,10 @@ func (sp *ServiceParms) String() string {
 }
 
 func (rq *RequestSize) String() string {
+	if rq.InputTokens != nil || rq.OutputTokens != nil {
+		return fmt.Sprintf("{inTokens=%.1f, outTokens=%.1f, inDist=%s, outDist=%s}",
+			rq.AvgInputTokens, rq.AvgOutputTokens, rq.InputTokens, rq.OutputTokens)
+	}
 	return fmt.Sprintf("{inTokens=%.1f, outTokens=%.1f}", rq.AvgInputTokens, rq.AvgOutputTokens)
 }
 
@@ -103,16 +160,20 @@ func (rr *RateRange) String() string {
 }
 
 func (am *AnalysisMetrics) String() string {
-	return fmt.Sprintf("{tput=%.3f, lat=%.3f, wait=%.3f, conc=%.3f, ttft=%.3f, itl=%.3f, maxRate=%.3f, rho=%0.3f}",
-		am.Throughput, am.AvgRespTime, am.AvgWaitTime, am.AvgNumInServ, am.AvgTTFT, am.AvgTokenTime, am.MaxRate, am.Rho)
+	return fmt.Sprintf("{tput=%.3f, lat=%.3f, wait=%.3f, conc=%.3f, ttft=%.3f, itl=%.3f, maxRate=%.3f, rho=%0.3f, "+
+		"pct=%.2f, pctWait=%.3f, pctTTFT=%.3f, pctLat=%.3f, abandon=%.3f, goodput=%.3f}",
+		am.Throughput, am.AvgRespTime, am.AvgWaitTime, am.AvgNumInServ, am.AvgTTFT, am.AvgTokenTime, am.MaxRate, am.Rho,
+		am.Percentile, am.PctWaitTime, am.PctTTFT, am.PctRespTime, am.AbandonRate, am.Goodput)
 }
 
 func (tp *TargetPerf) String() string {
-	return fmt.Sprintf("{TTFT=%.3f, ITL=%.3f, TPS=%.3f}",
-		tp.TargetTTFT, tp.TargetITL, tp.TargetTPS)
+	return fmt.Sprintf("{TTFT=%.3f, ITL=%.3f, TPS=%.3f, userTPS=%.3f, pct=%.2f, pctTTFT=%.3f, pctLat=%.3f, abandon=%.3f}",
+		tp.TargetTTFT, tp.TargetITL, tp.TargetTPS, tp.TargetUserTPS, tp.Percentile, tp.TargetPctTTFT, tp.TargetPctRespTime,
+		tp.MaxAbandonFraction)
 }
 
 func (tr *TargetRate) String() string {
-	return fmt.Sprintf("{rateTTFT=%.3f, rateITL=%.3f, rateTPS=%.3f}",
-		tr.RateTargetTTFT, tr.RateTargetITL, tr.RateTargetTPS)
+	return fmt.Sprintf("{rateTTFT=%.3f, rateITL=%.3f, rateTPS=%.3f, rateUserTPS=%.3f, ratePctTTFT=%.3f, ratePctLat=%.3f, rateAbandon=%.3f}",
+		tr.RateTargetTTFT, tr.RateTargetITL, tr.RateTargetTPS, tr.RateTargetUserTPS, tr.RateTargetPctTTFT, tr.RateTargetPctRespTime,
+		tr.RateTargetAbandon)
 }
@@ -0,0 +1,412 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// confidence level of the parameter intervals, unless otherwise specified
+const DefaultConfidence = float32(0.95)
+
+// number of fitted service parameters (alpha, beta, gamma)
+const numParms = 3
+
+// measured metric of an observation
+type Metric string
+
+const (
+	MetricITL        Metric = "itl"        // inter-token latency (msec)
+	MetricTTFT       Metric = "ttft"       // time to first token without queueing (msec)
+	MetricThroughput Metric = "throughput" // request throughput (requests/sec)
+)
+
+// benchmark observation at a steady batch size: requests of given size running concurrently
+// without queueing (e.g. a closed-loop benchmark at concurrency BatchSize), with one or more
+// measured metrics (0 => not measured)
+type Observation struct {
+	BatchSize    float32 `json:"batchSize"`    // number of requests in service
+	InputTokens  float32 `json:"inputTokens"`  // number of input tokens per request
+	OutputTokens float32 `json:"outputTokens"` // number of output tokens per request
+	ITL          float32 `json:"itl"`          // measured inter-token latency (msec)
+	TTFT         float32 `json:"ttft"`         // measured time to first token (msec)
+	Throughput   float32 `json:"throughput"`   // measured request throughput (requests/sec)
+	Weight       float32 `json:"weight"`       // relative weight of the observation (0 => 1)
+}
+
+// calibration parameters
+type Config struct {
+	MaxNumTokens int     `json:"maxNumTokens"` // maximum number of tokens per batch (0 => analyzer.DefaultMaxNumTokens)
+	Confidence   float32 `json:"confidence"`   // confidence level of the parameter intervals (0 => DefaultConfidence)
+}
+
+// calibration problem: benchmark observations and calibration parameters
+// (input of the calibrate command and of the /calibrate endpoint)
+type Problem struct {
+	Config
+	Observations []*Observation `json:"observations"` // benchmark observations
+}
+
+// fitted value of a parameter, with its standard error and confidence interval;
+// a parameter fitted at its lower bound of zero has no standard error
+type Estimate struct {
+	Value  float32 `json:"value"`  // fitted value
+	StdErr float32 `json:"stdErr"` // standard error
+	Lower  float32 `json:"lower"`  // lower end of the confidence interval
+	Upper  float32 `json:"upper"`  // upper end of the confidence interval
+}
+
+// fitted and measured value of a metric of an observation
+type Residual struct {
+	Observation int     `json:"observation"` // index of the observation
+	Metric      Metric  `json:"metric"`      // measured metric
+	Observed    float32 `json:"observed"`    // measured value
+	Fitted      float32 `json:"fitted"`      // value predicted by the fitted parameters
+	Relative    float32 `json:"relative"`    // (observed - fitted) / observed
+}
+
+// calibration result
+type Result struct {
+	Alpha            Estimate    `json:"alpha"`            // base iteration time (msec)
+	Beta             Estimate    `json:"beta"`             // slope for compute time (msec/token)
+	Gamma            Estimate    `json:"gamma"`            // slope for memory access time (msec/token*2)
+	Confidence       float32     `json:"confidence"`       // confidence level of the parameter intervals
+	RSquared         float32     `json:"rSquared"`         // weighted coefficient of determination
+	RelativeRMSE     float32     `json:"relativeRMSE"`     // root mean square relative residual
+	NumMeasurements  int         `json:"numMeasurements"`  // number of measured metrics fitted
+	DegreesOfFreedom int         `json:"degreesOfFreedom"` // number of measurements less the number of free parameters
+	Residuals        []*Residual `json:"residuals"`        // residuals of the measured metrics
+}
+
+// fitted service parameters
+func (res *Result) ServiceParms() *analyzer.ServiceParms {
+	return &analyzer.ServiceParms{Alpha: res.Alpha.Value, Beta: res.Beta.Value, Gamma: res.Gamma.Value}
+}
+
+// a measured metric as a linear equation in the parameters: row . (alpha, beta, gamma) = value
+type measurement struct {
+	observation int
+	metric      Metric
+	row         [numParms]float64
+	value       float64 // measured value, in units of the model (msec)
+	observed    float32 // measured value, in units of the observation
+	weight      float64
+}
+
+// Fit the service parameters (Alpha, Beta, Gamma) to benchmark observations by weighted least squares.
+//
+// The model primitives of the analyzer at the chunk count of each observation (ITL, prefill time plus
+// ITL for TTFT, and batch size over the service time for throughput) are linear in the parameters, so
+// each measured metric is a linear equation. Residuals are relative to the measured values, and each
+// equation is weighted by its observation's weight. Parameters are constrained to be non-negative:
+// the fit is the best over the subsets of free parameters, the others being held at zero. Standard
+// errors follow from the weighted residual variance, and intervals from the Student t distribution.
+func Calibrate(observations []*Observation, config *Config) (*Result, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if err := config.check(); err != nil {
+		return nil, err
+	}
+	maxNumTokens := config.MaxNumTokens
+	if maxNumTokens == 0 {
+		maxNumTokens = analyzer.DefaultMaxNumTokens
+	}
+	confidence := config.Confidence
+	if confidence == 0 {
+		confidence = DefaultConfidence
+	}
+
+	var measurements []*measurement
+	for i, obs := range observations {
+		if err := obs.check(); err != nil {
+			return nil, fmt.Errorf("observation %d: %v", i, err)
+		}
+		measurements = append(measurements, obs.measurements(i, maxNumTokens)...)
+	}
+	if len(measurements) <= numParms {
+		return nil, fmt.Errorf("too few measured metrics %d, at least %d needed", len(measurements), numParms+1)
+	}
+
+	// best non-negative fit over the subsets of free parameters
+	var best *fit
+	for mask := 1; mask < 1<<numParms; mask++ {
+		f, err := solveSubset(measurements, mask)
+		if err != nil || !f.nonNegative() {
+			continue
+		}
+		if best == nil || f.rss < best.rss {
+			best = f
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no non-negative fit of the service parameters")
+	}
+	return best.result(measurements, confidence)
+}
+
+// measured metrics of an observation as linear equations in the parameters
+func (obs *Observation) measurements(index, maxNumTokens int) []*measurement {
+	r := &analyzer.RequestSize{AvgInputTokens: obs.InputTokens, AvgOutputTokens: obs.OutputTokens}
+	batchIndex := max(int(obs.BatchSize+0.5), 1)
+	cfg := &analyzer.Configuration{MaxBatchSize: batchIndex, MaxNumTokens: maxNumTokens}
+	nc := analyzer.NumIterationsPerPrefill(cfg, r)[batchIndex]
+
+	// row of coefficients of a linear function of the parameters
+	rowOf := func(f func(p *analyzer.ServiceParms) float32) (row [numParms]float64) {
+		for k, p := range []*analyzer.ServiceParms{{Alpha: 1}, {Beta: 1}, {Gamma: 1}} {
+			row[k] = float64(f(p))
+		}
+		return row
+	}
+	weight := float64(1)
+	if obs.Weight > 0 {
+		weight = float64(obs.Weight)
+	}
+
+	var ms []*measurement
+	add := func(metric Metric, observed float32, value float64, f func(p *analyzer.ServiceParms) float32) {
+		ms = append(ms, &measurement{
+			observation: index,
+			metric:      metric,
+			row:         rowOf(f),
+			value:       value,
+			observed:    observed,
+			// relative residuals: (value - fitted)^2 / value^2
+			weight: weight / (value * value),
+		})
+	}
+	if obs.ITL > 0 {
+		add(MetricITL, obs.ITL, float64(obs.ITL), func(p *analyzer.ServiceParms) float32 {
+			return p.ChunkedDecodeTime(r, obs.BatchSize, nc)
+		})
+	}
+	if obs.TTFT > 0 {
+		add(MetricTTFT, obs.TTFT, float64(obs.TTFT), func(p *analyzer.ServiceParms) float32 {
+			return p.ChunkedPrefillTime(r, obs.BatchSize, nc) + p.ChunkedDecodeTime(r, obs.BatchSize, nc)
+		})
+	}
+	if obs.Throughput > 0 {
+		// throughput = 1000 * B / tau(B), fitted as the service time tau(B) = 1000 * B / throughput
+		tau := 1000 * float64(obs.BatchSize) / float64(obs.Throughput)
+		add(MetricThroughput, obs.Throughput, tau, func(p *analyzer.ServiceParms) float32 {
+			return p.ChunkedServiceTime(r, obs.BatchSize, nc)
+		})
+	}
+	return ms
+}
+
+// weighted least squares fit over a subset of free parameters
+type fit struct {
+	free  []int       // indexes of the free parameters
+	parms []float64   // fitted parameters (zero if not free)
+	inv   [][]float64 // inverse of the normal matrix of the free parameters
+	rss   float64     // weighted residual sum of squares
+}
+
+// solve the normal equations over the free parameters of a mask
+func solveSubset(ms []*measurement, mask int) (*fit, error) {
+	var free []int
+	for k := range numParms {
+		if mask&(1<<k) != 0 {
+			free = append(free, k)
+		}
+	}
+	n := len(free)
+	normal := make([][]float64, n)
+	rhs := make([]float64, n)
+	for i := range normal {
+		normal[i] = make([]float64, n)
+	}
+	for _, m := range ms {
+		for i, ki := range free {
+			rhs[i] += m.weight * m.row[ki] * m.value
+			for j, kj := range free {
+				normal[i][j] += m.weight * m.row[ki] * m.row[kj]
+			}
+		}
+	}
+	inv, err := invert(normal)
+	if err != nil {
+		return nil, err
+	}
+	f := &fit{free: free, parms: make([]float64, numParms), inv: inv}
+	for i, ki := range free {
+		for j := range free {
+			f.parms[ki] += inv[i][j] * rhs[j]
+		}
+	}
+	for _, m := range ms {
+		res := m.value - f.predict(m)
+		f.rss += m.weight * res * res
+	}
+	return f, nil
+}
+
+// value of a measured metric predicted by the fitted parameters, in units of the model
+func (f *fit) predict(m *measurement) float64 {
+	var y float64
+	for k := range numParms {
+		y += m.row[k] * f.parms[k]
+	}
+	return y
+}
+
+// all fitted parameters are non-negative
+func (f *fit) nonNegative() bool {
+	for _, x := range f.parms {
+		if x < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// calibration result of a fit
+func (f *fit) result(ms []*measurement, confidence float32) (*Result, error) {
+	dof := len(ms) - len(f.free)
+	sigma2 := f.rss / float64(dof)
+	t := utils.TQuantile(float64(1+confidence)/2, dof)
+
+	estimates := make([]Estimate, numParms)
+	for k := range numParms {
+		estimates[k].Value = float32(f.parms[k])
+	}
+	for i, k := range f.free {
+		stdErr := math.Sqrt(max(sigma2*f.inv[i][i], 0))
+		estimates[k].StdErr = float32(stdErr)
+		estimates[k].Lower = float32(f.parms[k] - t*stdErr)
+		estimates[k].Upper = float32(f.parms[k] + t*stdErr)
+	}
+	for k := range estimates {
+		if estimates[k].StdErr == 0 {
+			estimates[k].Lower, estimates[k].Upper = estimates[k].Value, estimates[k].Value
+		}
+	}
+
+	// weighted total sum of squares around the weighted mean
+	var sumW, sumWY float64
+	for _, m := range ms {
+		sumW += m.weight
+		sumWY += m.weight * m.value
+	}
+	var tss, sumSq float64
+	residuals := make([]*Residual, len(ms))
+	for i, m := range ms {
+		d := m.value - sumWY/sumW
+		tss += m.weight * d * d
+		fitted := f.predict(m)
+		observed := float64(m.observed)
+		if m.metric == MetricThroughput {
+			// back from service time to throughput
+			fitted = observed * m.value / fitted
+		}
+		rel := (observed - fitted) / observed
+		sumSq += rel * rel
+		residuals[i] = &Residual{
+			Observation: m.observation,
+			Metric:      m.metric,
+			Observed:    m.observed,
+			Fitted:      float32(fitted),
+			Relative:    float32(rel),
+		}
+	}
+	rSquared := float32(1)
+	if tss > 0 {
+		rSquared = float32(1 - f.rss/tss)
+	}
+
+	return &Result{
+		Alpha:            estimates[0],
+		Beta:             estimates[1],
+		Gamma:            estimates[2],
+		Confidence:       confidence,
+		RSquared:         rSquared,
+		RelativeRMSE:     float32(math.Sqrt(sumSq / float64(len(ms)))),
+		NumMeasurements:  len(ms),
+		DegreesOfFreedom: dof,
+		Residuals:        residuals,
+	}, nil
+}
+
+// inverse of a small symmetric positive definite matrix, by Gauss-Jordan elimination with partial pivoting
+func invert(a [][]float64) ([][]float64, error) {
+	n := len(a)
+	aug := make([][]float64, n)
+	for i := range aug {
+		aug[i] = make([]float64, 2*n)
+		copy(aug[i], a[i])
+		aug[i][n+i] = 1
+	}
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(aug[row][col]) > math.Abs(aug[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(aug[pivot][col]) < 1e-300 {
+			return nil, fmt.Errorf("singular normal matrix")
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+		scale := aug[col][col]
+		for j := range aug[col] {
+			aug[col][j] /= scale
+		}
+		for row := range n {
+			if row == col || aug[row][col] == 0 {
+				continue
+			}
+			factor := aug[row][col]
+			for j := range aug[row] {
+				aug[row][j] -= factor * aug[col][j]
+			}
+		}
+	}
+	inv := make([][]float64, n)
+	for i := range inv {
+		inv[i] = aug[i][n:]
+	}
+	return inv, nil
+}
+
+// check validity of calibration parameters
+func (c *Config) check() error {
+	if c.MaxNumTokens < 0 || c.Confidence < 0 || c.Confidence >= 1 {
+		return fmt.Errorf("invalid calibration config %s", c)
+	}
+	return nil
+}
+
+// check validity of an observation
+func (obs *Observation) check() error {
+	if obs.BatchSize < 1 || obs.InputTokens < 0 || obs.OutputTokens < 1 || obs.ITL < 0 || obs.TTFT < 0 ||
+		obs.Throughput < 0 || obs.Weight < 0 {
+		return fmt.Errorf("invalid observation %s", obs)
+	}
+	if obs.ITL == 0 && obs.TTFT == 0 && obs.Throughput == 0 {
+		return fmt.Errorf("no measured metric in observation %s", obs)
+	}
+	return nil
+}
+
+func (c *Config) String() string {
+	return fmt.Sprintf("{maxNumTokens=%d, confidence=%.3f}", c.MaxNumTokens, c.Confidence)
+}
+
+func (obs *Observation) String() string {
+	return fmt.Sprintf("{batch=%.1f, inTokens=%.1f, outTokens=%.1f, itl=%.3f, ttft=%.3f, throughput=%.3f, weight=%.3f}",
+		obs.BatchSize, obs.InputTokens, obs.OutputTokens, obs.ITL, obs.TTFT, obs.Throughput, obs.Weight)
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%.6g ± %.3g [%.6g, %.6g]", e.Value, e.StdErr, e.Lower, e.Upper)
+}
+
+func (res *Result) String() string {
+	return fmt.Sprintf("{alpha=%s, beta=%s, gamma=%s, confidence=%.2f, R2=%.4f, relRMSE=%.4f, n=%d, dof=%d}",
+		res.Alpha, res.Beta, res.Gamma, res.Confidence, res.RSquared, res.RelativeRMSE, res.NumMeasurements,
+		res.DegreesOfFreedom)
+}
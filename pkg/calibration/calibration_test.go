@@ -0,0 +1,138 @@
+package calibration
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// observations of the model with given parameters, with relative noise of given magnitude
+func syntheticObservations(p *analyzer.ServiceParms, noise float64, seed int64) []*Observation {
+	rng := rand.New(rand.NewSource(seed))
+	perturb := func(x float32) float32 {
+		return x * float32(1+noise*(2*rng.Float64()-1))
+	}
+	var observations []*Observation
+	for _, size := range [][2]float32{{128, 256}, {512, 512}, {2048, 128}, {4096, 1024}} {
+		for _, B := range []int{1, 4, 16, 64} {
+			r := &analyzer.RequestSize{AvgInputTokens: size[0], AvgOutputTokens: size[1]}
+			cfg := &analyzer.Configuration{MaxBatchSize: B, MaxNumTokens: analyzer.DefaultMaxNumTokens}
+			nc := analyzer.NumIterationsPerPrefill(cfg, r)[B]
+			itl := p.ChunkedDecodeTime(r, float32(B), nc)
+			observations = append(observations, &Observation{
+				BatchSize:    float32(B),
+				InputTokens:  size[0],
+				OutputTokens: size[1],
+				ITL:          perturb(itl),
+				TTFT:         perturb(p.ChunkedPrefillTime(r, float32(B), nc) + itl),
+				Throughput:   perturb(1000 * float32(B) / p.ChunkedServiceTime(r, float32(B), nc)),
+			})
+		}
+	}
+	return observations
+}
+
+func TestCalibrateRecoversParameters(t *testing.T) {
+	want := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333}
+	res, err := Calibrate(syntheticObservations(want, 0, 1), nil)
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	got := res.ServiceParms()
+	for _, pair := range [][2]float32{{got.Alpha, want.Alpha}, {got.Beta, want.Beta}, {got.Gamma, want.Gamma}} {
+		if math.Abs(float64(pair[0]-pair[1])) > 1e-3*float64(pair[1]) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	if res.RSquared < 0.9999 || res.RelativeRMSE > 1e-4 {
+		t.Errorf("exact observations should fit perfectly: %s", res)
+	}
+	if res.NumMeasurements != 48 || res.DegreesOfFreedom != 45 || len(res.Residuals) != 48 {
+		t.Errorf("counts: %s", res)
+	}
+}
+
+func TestCalibrateIntervalsCoverNoisyParameters(t *testing.T) {
+	want := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333}
+	res, err := Calibrate(syntheticObservations(want, 0.03, 7), &Config{Confidence: 0.99})
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	for _, pair := range []struct {
+		name string
+		e    Estimate
+		want float32
+	}{{"alpha", res.Alpha, want.Alpha}, {"beta", res.Beta, want.Beta}, {"gamma", res.Gamma, want.Gamma}} {
+		if pair.e.StdErr <= 0 || pair.e.Lower > pair.want || pair.e.Upper < pair.want {
+			t.Errorf("%s: interval %s does not cover %v", pair.name, pair.e, pair.want)
+		}
+	}
+	if res.RSquared < 0.99 || res.RelativeRMSE > 0.03 {
+		t.Errorf("noisy fit: %s", res)
+	}
+}
+
+func TestCalibrateNonNegative(t *testing.T) {
+	// no memory access cost: gamma is held at its bound rather than fitted negative by the noise
+	want := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033}
+	res, err := Calibrate(syntheticObservations(want, 0.01, 3), nil)
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	if res.Gamma.Value < 0 || res.Alpha.Value <= 0 || res.Beta.Value <= 0 {
+		t.Errorf("negative parameter: %s", res)
+	}
+
+	if _, err := Calibrate(syntheticObservations(want, 0, 1)[:1], nil); err == nil {
+		t.Errorf("expected error for too few measurements")
+	}
+	if _, err := Calibrate([]*Observation{{BatchSize: 4, OutputTokens: 10}}, nil); err == nil {
+		t.Errorf("expected error for an observation without measured metrics")
+	}
+}
+
+func TestCalibrateFractionalBatchSize(t *testing.T) {
+	want := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333}
+	r := &analyzer.RequestSize{AvgInputTokens: 512, AvgOutputTokens: 256}
+	var observations []*Observation
+	for _, B := range []float32{1.6, 6.3, 12.4, 40.7} {
+		index := int(B + 0.5)
+		cfg := &analyzer.Configuration{MaxBatchSize: index, MaxNumTokens: analyzer.DefaultMaxNumTokens}
+		nc := analyzer.NumIterationsPerPrefill(cfg, r)[index]
+		itl := want.ChunkedDecodeTime(r, B, nc)
+		observations = append(observations, &Observation{
+			BatchSize:    B,
+			InputTokens:  r.AvgInputTokens,
+			OutputTokens: r.AvgOutputTokens,
+			ITL:          itl,
+			TTFT:         want.ChunkedPrefillTime(r, B, nc) + itl,
+			Throughput:   1000 * B / want.ChunkedServiceTime(r, B, nc),
+		})
+	}
+	res, err := Calibrate(observations, nil)
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	// all metrics are fitted at the observed (mean) batch size
+	if res.RSquared < 0.9999 || res.RelativeRMSE > 1e-4 {
+		t.Errorf("exact observations at fractional batch sizes should fit perfectly: %s", res)
+	}
+
+	// with one degree of freedom, the interval is 12.706 standard errors wide on each side
+	noisy := syntheticObservations(want, 0.03, 5)[:2]
+	for _, obs := range noisy {
+		obs.Throughput = 0
+	}
+	res, err = Calibrate(noisy, &Config{Confidence: 0.95})
+	if err != nil {
+		t.Fatalf("Calibrate: %v", err)
+	}
+	if res.DegreesOfFreedom != 1 || res.Alpha.StdErr <= 0 {
+		t.Fatalf("expected a noisy fit with one degree of freedom: %s", res)
+	}
+	if got := (res.Alpha.Upper - res.Alpha.Value) / res.Alpha.StdErr; math.Abs(float64(got)-12.706) > 0.01 {
+		t.Errorf("t quantile at one degree of freedom: got %v, want 12.706", got)
+	}
+}
@@ -0,0 +1,321 @@
+package calibration
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// default relative standard deviations of the tracker
+const (
+	DefaultInitialUncertainty = float32(0.5)  // of the initial parameter estimates
+	DefaultProcessNoise       = float32(0.01) // of the parameter drift between samples
+	DefaultMeasurementNoise   = float32(0.05) // of the measured metrics
+)
+
+// relative step of the finite-difference Jacobian of the observation function
+const jacobianStep = 1e-3
+
+// online tracking parameters
+type TrackerConfig struct {
+	Configuration      *analyzer.Configuration // server configuration; its ServiceParms are the initial estimates (> 0)
+	InitialUncertainty float32                 // relative std dev of the initial estimates (0 => DefaultInitialUncertainty)
+	ProcessNoise       float32                 // relative std dev of the parameter drift per sample (0 => DefaultProcessNoise)
+	MeasurementNoise   float32                 // relative std dev of the measured metrics (0 => DefaultMeasurementNoise)
+}
+
+// streaming observation of a server over an interval, with one or more measured metrics (0 => not measured)
+type Sample struct {
+	RPS             float32 `json:"RPS"`             // request arrival rate (requests/sec)
+	AvgInputTokens  float32 `json:"avgInputTokens"`  // average number of input tokens per request
+	AvgOutputTokens float32 `json:"avgOutputTokens"` // average number of output tokens per request
+	TTFT            float32 `json:"ttft"`            // measured average time to first token (msec)
+	ITL             float32 `json:"itl"`             // measured average inter-token latency (msec)
+	AvgBatchSize    float32 `json:"avgBatchSize"`    // measured average batch size (per replica)
+}
+
+// Online tracker of the service parameters (Alpha, Beta, Gamma) by an extended Kalman filter.
+//
+// The state is the parameter vector, drifting as a random walk with relative standard deviation
+// ProcessNoise per sample. The observation function is the queue analyzer: given the parameters and
+// the load of a sample (rate and request size), it predicts the average TTFT, ITL and batch size,
+// linearized by finite differences around the current estimates. The measured metrics have relative
+// standard deviation MeasurementNoise. Estimates are kept positive by projection after each update.
+type Tracker struct {
+	config     TrackerConfig
+	state      []float64   // current estimates of (alpha, beta, gamma)
+	covariance [][]float64 // covariance of the estimates
+	scale      []float64   // initial estimates, scaling the process noise and the positivity floor
+	numUpdates int         // number of samples ingested
+	innovation []float32   // relative residuals of the measured metrics of the last sample, before the update
+}
+
+func NewTracker(config *TrackerConfig) (*Tracker, error) {
+	if err := config.check(); err != nil {
+		return nil, err
+	}
+	c := *config
+	if c.InitialUncertainty == 0 {
+		c.InitialUncertainty = DefaultInitialUncertainty
+	}
+	if c.ProcessNoise == 0 {
+		c.ProcessNoise = DefaultProcessNoise
+	}
+	if c.MeasurementNoise == 0 {
+		c.MeasurementNoise = DefaultMeasurementNoise
+	}
+	p := c.Configuration.ServiceParms
+	state := []float64{float64(p.Alpha), float64(p.Beta), float64(p.Gamma)}
+	covariance := newSquare(numParms)
+	for k, x := range state {
+		sd := float64(c.InitialUncertainty) * x
+		covariance[k][k] = sd * sd
+	}
+	return &Tracker{
+		config:     c,
+		state:      state,
+		covariance: covariance,
+		scale:      append([]float64(nil), state...),
+	}, nil
+}
+
+// ingest a sample: predict the parameter drift, then correct the estimates by the measured metrics;
+// on error, the tracker is left unchanged
+func (t *Tracker) Update(s *Sample) error {
+	if err := s.check(); err != nil {
+		return err
+	}
+
+	// predict: random walk of the parameters
+	prior := newSquare(numParms)
+	for k := range numParms {
+		copy(prior[k], t.covariance[k])
+		sd := float64(t.config.ProcessNoise) * math.Max(math.Abs(t.state[k]), t.scale[k])
+		prior[k][k] += sd * sd
+	}
+
+	// measured metrics and their predictions, with the Jacobian by forward differences
+	observed := s.measured()
+	predicted, err := t.observe(t.state, s)
+	if err != nil {
+		return err
+	}
+	m := len(observed)
+	jacobian := make([][]float64, m)
+	for i := range jacobian {
+		jacobian[i] = make([]float64, numParms)
+	}
+	for k := range numParms {
+		step := jacobianStep * math.Max(math.Abs(t.state[k]), t.scale[k])
+		shifted := append([]float64(nil), t.state...)
+		shifted[k] += step
+		values, err := t.observe(shifted, s)
+		if err != nil {
+			return err
+		}
+		for i := range m {
+			jacobian[i][k] = (values[i] - predicted[i]) / step
+		}
+	}
+
+	// innovation covariance S = H P H' + R
+	ph := matMul(prior, transpose(jacobian))
+	innovationCov := matMul(jacobian, ph)
+	residual := make([]float64, m)
+	innovation := make([]float32, m)
+	for i := range m {
+		sd := float64(t.config.MeasurementNoise) * observed[i]
+		innovationCov[i][i] += sd * sd
+		residual[i] = observed[i] - predicted[i]
+		innovation[i] = float32(residual[i] / observed[i])
+	}
+	inv, err := invert(innovationCov)
+	if err != nil {
+		return fmt.Errorf("tracker update failed: %v", err)
+	}
+
+	// gain K = P H' S^-1, state x += K r, covariance P = (I - K H) P (I - K H)' + K R K' (Joseph form)
+	gain := matMul(ph, inv)
+	state := append([]float64(nil), t.state...)
+	for k := range numParms {
+		for i := range m {
+			state[k] += gain[k][i] * residual[i]
+		}
+		state[k] = math.Max(state[k], 1e-6*t.scale[k])
+	}
+	ikh := matMul(gain, jacobian)
+	for i := range ikh {
+		for j := range ikh[i] {
+			ikh[i][j] = -ikh[i][j]
+		}
+		ikh[i][i] += 1
+	}
+	covariance := matMul(matMul(ikh, prior), transpose(ikh))
+	for i := range numParms {
+		for j := range numParms {
+			for l := range m {
+				sd := float64(t.config.MeasurementNoise) * observed[l]
+				covariance[i][j] += gain[i][l] * sd * sd * gain[j][l]
+			}
+		}
+	}
+	t.state = state
+	t.covariance = covariance
+	t.innovation = innovation
+	t.numUpdates++
+	return nil
+}
+
+// metrics of a sample predicted by the analyzer at given parameters, in the order of Sample.measured()
+func (t *Tracker) observe(state []float64, s *Sample) ([]float64, error) {
+	config := *t.config.Configuration
+	config.ServiceParms = &analyzer.ServiceParms{
+		Alpha: float32(state[0]),
+		Beta:  float32(state[1]),
+		Gamma: float32(state[2]),
+	}
+	requestSize := &analyzer.RequestSize{AvgInputTokens: s.AvgInputTokens, AvgOutputTokens: s.AvgOutputTokens}
+	qa, err := analyzer.NewLLMQueueAnalyzer(&config, requestSize)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := qa.Analyze(s.RPS)
+	if err != nil {
+		return nil, err
+	}
+	var values []float64
+	if s.TTFT > 0 {
+		values = append(values, float64(metrics.AvgTTFT))
+	}
+	if s.ITL > 0 {
+		values = append(values, float64(metrics.AvgTokenTime))
+	}
+	if s.AvgBatchSize > 0 {
+		values = append(values, float64(metrics.AvgNumInServ/float32(qa.NumReplicas)))
+	}
+	return values, nil
+}
+
+// measured metrics of a sample
+func (s *Sample) measured() []float64 {
+	var values []float64
+	for _, x := range []float32{s.TTFT, s.ITL, s.AvgBatchSize} {
+		if x > 0 {
+			values = append(values, float64(x))
+		}
+	}
+	return values
+}
+
+// current estimates of the service parameters
+func (t *Tracker) ServiceParms() *analyzer.ServiceParms {
+	return &analyzer.ServiceParms{
+		Alpha: float32(t.state[0]),
+		Beta:  float32(t.state[1]),
+		Gamma: float32(t.state[2]),
+	}
+}
+
+// covariance of the current estimates of (alpha, beta, gamma)
+func (t *Tracker) Covariance() [][]float64 {
+	covariance := newSquare(numParms)
+	for i := range covariance {
+		copy(covariance[i], t.covariance[i])
+	}
+	return covariance
+}
+
+// standard deviations of the current estimates of (alpha, beta, gamma)
+func (t *Tracker) StdDev() []float32 {
+	sd := make([]float32, numParms)
+	for k := range sd {
+		sd[k] = float32(math.Sqrt(math.Max(t.covariance[k][k], 0)))
+	}
+	return sd
+}
+
+// relative residuals of the measured metrics of the last sample, before its update
+func (t *Tracker) GetInnovation() []float32 {
+	return t.innovation
+}
+
+func (t *Tracker) GetNumUpdates() int {
+	return t.numUpdates
+}
+
+func newSquare(n int) [][]float64 {
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n)
+	}
+	return a
+}
+
+func transpose(a [][]float64) [][]float64 {
+	b := make([][]float64, len(a[0]))
+	for j := range b {
+		b[j] = make([]float64, len(a))
+		for i := range a {
+			b[j][i] = a[i][j]
+		}
+	}
+	return b
+}
+
+func matMul(a, b [][]float64) [][]float64 {
+	c := make([][]float64, len(a))
+	for i := range c {
+		c[i] = make([]float64, len(b[0]))
+		for k := range b {
+			if a[i][k] == 0 {
+				continue
+			}
+			for j := range c[i] {
+				c[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return c
+}
+
+// check validity of tracking parameters
+func (c *TrackerConfig) check() error {
+	if c.Configuration == nil || c.Configuration.ServiceParms == nil {
+		return fmt.Errorf("tracker requires a configuration with initial service parameters")
+	}
+	p := c.Configuration.ServiceParms
+	if p.Alpha <= 0 || p.Beta <= 0 || p.Gamma <= 0 || c.InitialUncertainty < 0 || c.ProcessNoise < 0 ||
+		c.MeasurementNoise < 0 {
+		return fmt.Errorf("invalid tracker config %s", c)
+	}
+	return nil
+}
+
+// check validity of a sample
+func (s *Sample) check() error {
+	if s.RPS <= 0 || s.AvgInputTokens < 0 || s.AvgOutputTokens < 1 || s.TTFT < 0 || s.ITL < 0 || s.AvgBatchSize < 0 {
+		return fmt.Errorf("invalid sample %s", s)
+	}
+	if s.TTFT == 0 && s.ITL == 0 && s.AvgBatchSize == 0 {
+		return fmt.Errorf("no measured metric in sample %s", s)
+	}
+	return nil
+}
+
+func (c *TrackerConfig) String() string {
+	return fmt.Sprintf("{config=%s, initialUncertainty=%.3f, processNoise=%.3f, measurementNoise=%.3f}",
+		c.Configuration, c.InitialUncertainty, c.ProcessNoise, c.MeasurementNoise)
+}
+
+func (s *Sample) String() string {
+	return fmt.Sprintf("{rps=%.3f, inTokens=%.1f, outTokens=%.1f, ttft=%.3f, itl=%.3f, batch=%.2f}",
+		s.RPS, s.AvgInputTokens, s.AvgOutputTokens, s.TTFT, s.ITL, s.AvgBatchSize)
+}
+
+func (t *Tracker) String() string {
+	sd := t.StdDev()
+	p := t.ServiceParms()
+	return fmt.Sprintf("{alpha=%.6g ± %.3g, beta=%.6g ± %.3g, gamma=%.6g ± %.3g, updates=%d}",
+		p.Alpha, sd[0], p.Beta, sd[1], p.Gamma, sd[2], t.numUpdates)
+}
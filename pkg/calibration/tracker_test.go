@@ -0,0 +1,114 @@
+package calibration
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+func TestTrackerConvergesToTrueParameters(t *testing.T) {
+	truth := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333}
+	config := &analyzer.Configuration{MaxBatchSize: 64, MaxQueueSize: 256}
+
+	// true server, observed with 2% noise under varying load
+	rng := rand.New(rand.NewSource(5))
+	sizes := [][2]float32{{256, 1024}, {1024, 256}, {512, 512}}
+	var samples []*Sample
+	for i := range 60 {
+		size := sizes[i%len(sizes)]
+		trueConfig := *config
+		trueConfig.ServiceParms = truth
+		qa, err := analyzer.NewLLMQueueAnalyzer(&trueConfig, &analyzer.RequestSize{AvgInputTokens: size[0], AvgOutputTokens: size[1]})
+		if err != nil {
+			t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+		}
+		rps := float32(0.2+0.6*rng.Float64()) * qa.RateRange.Max
+		metrics, err := qa.Analyze(rps)
+		if err != nil {
+			t.Fatalf("Analyze: %v", err)
+		}
+		noise := func(x float32) float32 { return x * float32(1+0.02*rng.NormFloat64()) }
+		samples = append(samples, &Sample{
+			RPS: rps, AvgInputTokens: size[0], AvgOutputTokens: size[1],
+			TTFT: noise(metrics.AvgTTFT), ITL: noise(metrics.AvgTokenTime), AvgBatchSize: noise(metrics.AvgNumInServ),
+		})
+	}
+
+	initial := *config
+	initial.ServiceParms = &analyzer.ServiceParms{Alpha: 12, Beta: 0.02, Gamma: 0.0005}
+	tracker, err := NewTracker(&TrackerConfig{Configuration: &initial})
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+	initialSD := tracker.StdDev()
+	for _, s := range samples {
+		if err := tracker.Update(s); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	got := tracker.ServiceParms()
+	for _, pair := range [][2]float32{{got.Alpha, truth.Alpha}, {got.Beta, truth.Beta}, {got.Gamma, truth.Gamma}} {
+		if math.Abs(float64(pair[0]-pair[1])) > 0.1*float64(pair[1]) {
+			t.Errorf("got %s, want %s", tracker, truth)
+		}
+	}
+	for k, sd := range tracker.StdDev() {
+		if sd >= initialSD[k] {
+			t.Errorf("parameter %d: std dev %v did not shrink from %v", k, sd, initialSD[k])
+		}
+	}
+	if tracker.GetNumUpdates() != len(samples) || len(tracker.GetInnovation()) != 3 {
+		t.Errorf("updates %d, innovation %v", tracker.GetNumUpdates(), tracker.GetInnovation())
+	}
+	cov := tracker.Covariance()
+	if math.Abs(cov[0][1]-cov[1][0]) > 1e-9*math.Abs(cov[0][1]) {
+		t.Errorf("covariance not symmetric: %v", cov)
+	}
+}
+
+func TestTrackerRejectsInvalidInput(t *testing.T) {
+	if _, err := NewTracker(&TrackerConfig{Configuration: &analyzer.Configuration{MaxBatchSize: 8}}); err == nil {
+		t.Errorf("expected error without initial service parameters")
+	}
+	config := &analyzer.Configuration{MaxBatchSize: 8, ServiceParms: &analyzer.ServiceParms{Alpha: 8, Beta: 0.03, Gamma: 0.0003}}
+	tracker, err := NewTracker(&TrackerConfig{Configuration: config})
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+	if err := tracker.Update(&Sample{RPS: 1, AvgInputTokens: 100, AvgOutputTokens: 100}); err == nil {
+		t.Errorf("expected error for a sample without measured metrics")
+	}
+}
+
+func TestTrackerUnchangedOnFailedUpdate(t *testing.T) {
+	config := &analyzer.Configuration{MaxBatchSize: 8, MaxQueueSize: 64, ServiceParms: &analyzer.ServiceParms{Alpha: 8, Beta: 0.03, Gamma: 0.0003}}
+	tracker, err := NewTracker(&TrackerConfig{Configuration: config})
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+	sample := &Sample{RPS: 5, AvgInputTokens: 100, AvgOutputTokens: 100, TTFT: 60, ITL: 10}
+	if err := tracker.Update(sample); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	before := tracker.String()
+	cov := tracker.Covariance()
+
+	// an observation function failing during the update leaves the tracker as it was
+	config.MaxQueueSize = -1
+	if err := tracker.Update(sample); err == nil {
+		t.Fatalf("expected error for an invalid configuration")
+	}
+	if after := tracker.String(); after != before {
+		t.Errorf("tracker changed by a failed update: %s, was %s", after, before)
+	}
+	for i, row := range tracker.Covariance() {
+		for j, x := range row {
+			if x != cov[i][j] {
+				t.Fatalf("covariance changed by a failed update: %v, was %v", tracker.Covariance(), cov)
+			}
+		}
+	}
+}
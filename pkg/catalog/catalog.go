@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// accelerator variant: a server configuration on a given accelerator type, with its hourly cost
+type Variant struct {
+	Name          string                 `json:"name"`          // variant name (unique in the catalog)
+	Accelerator   string                 `json:"accelerator"`   // accelerator type (e.g. H100)
+	ServiceParms  *analyzer.ServiceParms `json:"serviceParms"`  // request processing parameters on the accelerator
+	MaxBatchSize  int                    `json:"maxBatchSize"`  // maximum batch size
+	MaxNumTokens  int                    `json:"maxNumTokens"`  // maximum number of tokens per batch (0 => analyzer.DefaultMaxNumTokens)
+	MaxQueueSize  int                    `json:"maxQueueSize"`  // maximum queue size
+	KVCacheTokens int                    `json:"kvCacheTokens"` // KV cache capacity (tokens, 0 => KVCacheBlocks)
+	KVCacheBlocks int                    `json:"kvCacheBlocks"` // KV cache capacity (blocks, 0 => unbounded KV cache)
+	KVBlockSize   int                    `json:"kvBlockSize"`   // number of tokens per KV cache block (0 => analyzer.DefaultKVBlockSize)
+	CostPerHour   float32                `json:"costPerHour"`   // cost of a replica per hour
+	MaxReplicas   int                    `json:"maxReplicas"`   // max number of available replicas (0 => unlimited)
+}
+
+// catalog of accelerator variants
+type Catalog struct {
+	Variants []*Variant `json:"variants"` // accelerator variants
+}
+
+// read a catalog from a YAML (.yaml, .yml) or JSON file
+func LoadCatalog(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	default:
+		return ParseJSON(data)
+	}
+}
+
+// parse a catalog in JSON
+func ParseJSON(data []byte) (*Catalog, error) {
+	c := &Catalog{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid catalog: %v", err)
+	}
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parse a catalog in YAML, with the same field names as in JSON
+func ParseYAML(data []byte) (*Catalog, error) {
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog: %v", err)
+	}
+	return ParseJSON(jsonData)
+}
+
+// queue configuration of a replica of the variant
+func (v *Variant) Configuration() *analyzer.Configuration {
+	return &analyzer.Configuration{
+		MaxBatchSize:  v.MaxBatchSize,
+		MaxNumTokens:  v.MaxNumTokens,
+		MaxQueueSize:  v.MaxQueueSize,
+		ServiceParms:  v.ServiceParms,
+		KVCacheTokens: v.KVCacheTokens,
+		KVCacheBlocks: v.KVCacheBlocks,
+		KVBlockSize:   v.KVBlockSize,
+	}
+}
+
+// variant of a given name, nil if none
+func (c *Catalog) Variant(name string) *Variant {
+	for _, v := range c.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// check validity of the catalog
+func (c *Catalog) check() error {
+	if len(c.Variants) == 0 {
+		return fmt.Errorf("empty catalog")
+	}
+	names := make(map[string]bool)
+	for _, v := range c.Variants {
+		if v.Name == "" || names[v.Name] {
+			return fmt.Errorf("missing or duplicate variant name %q", v.Name)
+		}
+		names[v.Name] = true
+		if v.ServiceParms == nil || v.MaxBatchSize <= 0 || v.MaxNumTokens < 0 || v.MaxQueueSize < 0 ||
+			v.KVCacheTokens < 0 || v.KVCacheBlocks < 0 || v.KVBlockSize < 0 || v.CostPerHour < 0 || v.MaxReplicas < 0 {
+			return fmt.Errorf("invalid variant %s", v)
+		}
+	}
+	return nil
+}
+
+func (v *Variant) String() string {
+	return fmt.Sprintf("{name=%s, accelerator=%s, servParms:%s, maxBatch=%d, maxNumTokens=%d, maxQueue=%d, "+
+		"kvTokens=%d, kvBlocks=%d, kvBlockSize=%d, cost=%.3f, maxReplicas=%d}",
+		v.Name, v.Accelerator, v.ServiceParms, v.MaxBatchSize, v.MaxNumTokens, v.MaxQueueSize,
+		v.KVCacheTokens, v.KVCacheBlocks, v.KVBlockSize, v.CostPerHour, v.MaxReplicas)
+}
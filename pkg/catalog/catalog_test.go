@@ -0,0 +1,95 @@
+package catalog
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const catalogYAML = `
+variants:
+  - name: h100-256
+    accelerator: H100
+    serviceParms: {alpha: 8, beta: 0.033, gamma: 0.000333}
+    maxBatchSize: 256
+    maxQueueSize: 128
+    kvCacheBlocks: 20000
+    costPerHour: 10
+  - name: a100-128
+    accelerator: A100
+    serviceParms: {alpha: 16, beta: 0.066, gamma: 0.000666}
+    maxBatchSize: 128
+    maxQueueSize: 128
+    costPerHour: 4
+    maxReplicas: 8
+`
+
+const catalogJSON = `{
+  "variants": [
+    {"name": "h100-256", "accelerator": "H100", "serviceParms": {"alpha": 8, "beta": 0.033, "gamma": 0.000333},
+     "maxBatchSize": 256, "maxQueueSize": 128, "kvCacheBlocks": 20000, "costPerHour": 10},
+    {"name": "a100-128", "accelerator": "A100", "serviceParms": {"alpha": 16, "beta": 0.066, "gamma": 0.000666},
+     "maxBatchSize": 128, "maxQueueSize": 128, "costPerHour": 4, "maxReplicas": 8}
+  ]
+}`
+
+func TestParseYAMLAndJSON(t *testing.T) {
+	fromYAML, err := ParseYAML([]byte(catalogYAML))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	fromJSON, err := ParseJSON([]byte(catalogJSON))
+	if err != nil {
+		t.Fatalf("ParseJSON: %v", err)
+	}
+	if len(fromYAML.Variants) != 2 || len(fromJSON.Variants) != 2 {
+		t.Fatalf("variants: got %d (YAML) and %d (JSON), want 2", len(fromYAML.Variants), len(fromJSON.Variants))
+	}
+	for i, v := range fromYAML.Variants {
+		if got, want := v.String(), fromJSON.Variants[i].String(); got != want {
+			t.Errorf("variant %d: YAML %s, JSON %s", i, got, want)
+		}
+	}
+	v := fromYAML.Variant("a100-128")
+	if v == nil || v.Accelerator != "A100" || v.ServiceParms.Gamma != 0.000666 || v.CostPerHour != 4 || v.MaxReplicas != 8 {
+		t.Errorf("a100-128: got %v", v)
+	}
+	if fromYAML.Variant("missing") != nil {
+		t.Errorf("expected no variant of an unknown name")
+	}
+}
+
+func TestLoadCatalog(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"catalog.yaml": catalogYAML, "catalog.json": catalogJSON} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c, err := LoadCatalog(path)
+		if err != nil {
+			t.Fatalf("LoadCatalog(%s): %v", name, err)
+		}
+		if len(c.Variants) != 2 {
+			t.Errorf("%s: got %d variants, want 2", name, len(c.Variants))
+		}
+	}
+	if _, err := LoadCatalog("../../examples/catalog.yaml"); err != nil {
+		t.Errorf("example catalog: %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":         `{"variants": []}`,
+		"duplicate":     `{"variants": [{"name": "a", "serviceParms": {"alpha": 1}, "maxBatchSize": 8}, {"name": "a", "serviceParms": {"alpha": 1}, "maxBatchSize": 8}]}`,
+		"no parms":      `{"variants": [{"name": "a", "maxBatchSize": 8}]}`,
+		"no batch":      `{"variants": [{"name": "a", "serviceParms": {"alpha": 1}}]}`,
+		"negative cost": `{"variants": [{"name": "a", "serviceParms": {"alpha": 1}, "maxBatchSize": 8, "costPerHour": -1}]}`,
+		"malformed":     `{"variants": [`,
+	} {
+		if _, err := ParseJSON([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
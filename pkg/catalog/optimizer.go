@@ -0,0 +1,168 @@
+package catalog
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// replicas of a variant in a plan
+type Allocation struct {
+	Variant           *Variant                  // accelerator variant
+	NumReplicas       int                       // number of replicas
+	RatePerReplica    float32                   // load per replica (requests/sec)
+	MaxRatePerReplica float32                   // max rate per replica meeting the targets (requests/sec)
+	Cost              float32                   // cost of the replicas per hour
+	Metrics           *analyzer.AnalysisMetrics // metrics of a replica at the load per replica
+}
+
+// mix of variants and replica counts serving a load
+type Plan struct {
+	Allocations []*Allocation // replicas per variant (variants with replicas only)
+	Cost        float32       // total cost per hour
+	Capacity    float32       // combined max rate of the replicas meeting the targets (requests/sec)
+	Headroom    float32       // fraction of the capacity left unused
+}
+
+// variant that can meet the targets, with its max rate per replica
+type candidate struct {
+	variant  *Variant
+	analyzer *analyzer.LLMQueueAnalyzer
+	maxRate  float32
+}
+
+// Find the cheapest mix of variants and replica counts serving a request rate within the targets.
+//
+// Each variant is sized once (LLMQueueAnalyzer.Size) for its max rate per replica meeting the targets;
+// variants that cannot meet the targets are left out. The mix minimizes the total cost per hour subject
+// to the combined max rate covering the request rate and the availability of each variant (an integer
+// covering problem, solved exactly by branch and bound over the variants in order of cost per unit
+// rate). The load is routed to the replicas in proportion to their max rates, so all replicas run at
+// the same fraction of their capacity. Variants are sized for the targets of a replica
+// (TargetPerf.PerReplica).
+func (c *Catalog) Optimize(requestRate float32, requestSize *analyzer.RequestSize,
+	targetPerf *analyzer.TargetPerf) (*Plan, error) {
+	if requestRate <= 0 {
+		return nil, fmt.Errorf("invalid request rate %v", requestRate)
+	}
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	target := targetPerf.PerReplica()
+
+	var candidates []*candidate
+	for _, v := range c.Variants {
+		r := *requestSize
+		qa, err := analyzer.NewLLMQueueAnalyzer(v.Configuration(), &r)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %v", v.Name, err)
+		}
+		_, metrics, _, err := qa.Size(target)
+		if err != nil || metrics.OfferedRate <= 0 {
+			continue
+		}
+		candidates = append(candidates, &candidate{variant: v, analyzer: qa, maxRate: metrics.OfferedRate})
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no variant meets targets %s", targetPerf)
+	}
+	slices.SortStableFunc(candidates, func(a, b *candidate) int {
+		ea, eb := a.variant.CostPerHour/a.maxRate, b.variant.CostPerHour/b.maxRate
+		switch {
+		case ea < eb:
+			return -1
+		case ea > eb:
+			return 1
+		}
+		return 0
+	})
+
+	counts := cheapestCover(candidates, float64(requestRate))
+	if counts == nil {
+		return nil, fmt.Errorf("request rate %v exceeds the capacity of the available variants", requestRate)
+	}
+	return newPlan(candidates, counts, requestRate)
+}
+
+// replica counts of the candidates (sorted by cost per unit rate) of least cost covering a rate, nil if none
+func cheapestCover(candidates []*candidate, rate float64) []int {
+	n := len(candidates)
+	tolerance := 1e-6 * rate
+	bestCost := math.Inf(1)
+	var best []int
+	counts := make([]int, n)
+
+	var search func(i int, remaining, cost float64)
+	search = func(i int, remaining, cost float64) {
+		if remaining <= tolerance {
+			if cost < bestCost {
+				bestCost = cost
+				best = slices.Clone(counts)
+			}
+			return
+		}
+		if i == n {
+			return
+		}
+		// lower bound: the remaining rate at the best cost per unit rate of the remaining candidates
+		c := candidates[i]
+		unitCost := float64(c.variant.CostPerHour) / float64(c.maxRate)
+		if cost+remaining*unitCost >= bestCost {
+			return
+		}
+		maxCount := int(math.Ceil((remaining - tolerance) / float64(c.maxRate)))
+		if c.variant.MaxReplicas > 0 {
+			maxCount = min(maxCount, c.variant.MaxReplicas)
+		}
+		// most replicas first, finding a good incumbent early
+		for k := maxCount; k >= 0; k-- {
+			counts[i] = k
+			search(i+1, remaining-float64(k)*float64(c.maxRate), cost+float64(k)*float64(c.variant.CostPerHour))
+		}
+		counts[i] = 0
+	}
+	search(0, rate, 0)
+	return best
+}
+
+// plan of given replica counts of the candidates, each replica loaded in proportion to its max rate
+func newPlan(candidates []*candidate, counts []int, requestRate float32) (*Plan, error) {
+	var capacity, cost float32
+	for i, c := range candidates {
+		capacity += float32(counts[i]) * c.maxRate
+		cost += float32(counts[i]) * c.variant.CostPerHour
+	}
+	fraction := requestRate / capacity
+
+	plan := &Plan{Cost: cost, Capacity: capacity, Headroom: 1 - fraction}
+	for i, c := range candidates {
+		if counts[i] == 0 {
+			continue
+		}
+		ratePerReplica := c.maxRate * fraction
+		metrics, err := c.analyzer.Analyze(ratePerReplica)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %v", c.variant.Name, err)
+		}
+		plan.Allocations = append(plan.Allocations, &Allocation{
+			Variant:           c.variant,
+			NumReplicas:       counts[i],
+			RatePerReplica:    ratePerReplica,
+			MaxRatePerReplica: c.maxRate,
+			Cost:              float32(counts[i]) * c.variant.CostPerHour,
+			Metrics:           metrics,
+		})
+	}
+	return plan, nil
+}
+
+func (a *Allocation) String() string {
+	return fmt.Sprintf("{variant=%s, replicas=%d, ratePerReplica=%.3f, maxRatePerReplica=%.3f, cost=%.3f, metrics=%s}",
+		a.Variant.Name, a.NumReplicas, a.RatePerReplica, a.MaxRatePerReplica, a.Cost, a.Metrics)
+}
+
+func (p *Plan) String() string {
+	return fmt.Sprintf("{cost=%.3f, capacity=%.3f, headroom=%.3f, allocations=%v}", p.Cost, p.Capacity, p.Headroom, p.Allocations)
+}
@@ -0,0 +1,107 @@
+package catalog
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+func testRequestSize() *analyzer.RequestSize {
+	return &analyzer.RequestSize{AvgInputTokens: 256, AvgOutputTokens: 1024}
+}
+
+func testCatalog(t *testing.T) *Catalog {
+	t.Helper()
+	c, err := ParseYAML([]byte(catalogYAML))
+	if err != nil {
+		t.Fatalf("ParseYAML: %v", err)
+	}
+	return c
+}
+
+// cheapest cover by exhaustive search over replica counts
+func bruteForceCost(maxRates, costs []float32, maxReplicas []int, rate float32) float32 {
+	best := float32(math.Inf(1))
+	var search func(i int, capacity, cost float32)
+	search = func(i int, capacity, cost float32) {
+		if i == len(maxRates) {
+			if capacity >= rate*(1-1e-6) && cost < best {
+				best = cost
+			}
+			return
+		}
+		limit := int(math.Ceil(float64(rate / maxRates[i])))
+		if maxReplicas[i] > 0 {
+			limit = min(limit, maxReplicas[i])
+		}
+		for k := 0; k <= limit; k++ {
+			search(i+1, capacity+float32(k)*maxRates[i], cost+float32(k)*costs[i])
+		}
+	}
+	search(0, 0, 0)
+	return best
+}
+
+func TestOptimizeMix(t *testing.T) {
+	c := testCatalog(t)
+	target := &analyzer.TargetPerf{TargetTTFT: 200, TargetITL: 40}
+	for _, rate := range []float32{5, 20, 60} {
+		plan, err := c.Optimize(rate, testRequestSize(), target)
+		if err != nil {
+			t.Fatalf("rate %v: Optimize: %v", rate, err)
+		}
+
+		// max rate of each variant, sized independently
+		var maxRates, costs []float32
+		var maxReplicas []int
+		for _, v := range c.Variants {
+			qa, err := analyzer.NewLLMQueueAnalyzer(v.Configuration(), testRequestSize())
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, metrics, _, err := qa.Size(target)
+			if err != nil {
+				t.Fatalf("%s: Size: %v", v.Name, err)
+			}
+			maxRates = append(maxRates, metrics.OfferedRate)
+			costs = append(costs, v.CostPerHour)
+			maxReplicas = append(maxReplicas, v.MaxReplicas)
+		}
+		if want := bruteForceCost(maxRates, costs, maxReplicas, rate); math.Abs(float64(plan.Cost-want)) > 1e-3 {
+			t.Errorf("rate %v: cost %v, want %v (plan %s)", rate, plan.Cost, want, plan)
+		}
+
+		// the load is served within the targets and within the availability of each variant
+		var load, cost float32
+		for _, a := range plan.Allocations {
+			load += a.RatePerReplica * float32(a.NumReplicas)
+			cost += a.Cost
+			if a.Variant.MaxReplicas > 0 && a.NumReplicas > a.Variant.MaxReplicas {
+				t.Errorf("rate %v: %d replicas of %s over %d available", rate, a.NumReplicas, a.Variant.Name, a.Variant.MaxReplicas)
+			}
+			if a.Metrics.AvgTTFT > target.TargetTTFT*1.001 || a.Metrics.AvgTokenTime > target.TargetITL*1.001 {
+				t.Errorf("rate %v: %s metrics %s violate targets %s", rate, a.Variant.Name, a.Metrics, target)
+			}
+		}
+		if math.Abs(float64(load-rate)) > 1e-3*float64(rate) || math.Abs(float64(cost-plan.Cost)) > 1e-3 {
+			t.Errorf("rate %v: load %v and cost %v of allocations, plan %s", rate, load, cost, plan)
+		}
+		if plan.Headroom < 0 || plan.Headroom >= 1 {
+			t.Errorf("rate %v: headroom %v not in [0, 1)", rate, plan.Headroom)
+		}
+	}
+}
+
+func TestOptimizeInfeasible(t *testing.T) {
+	c := testCatalog(t)
+	// ITL below the unloaded decode time of all variants
+	if _, err := c.Optimize(10, testRequestSize(), &analyzer.TargetPerf{TargetITL: 1}); err == nil {
+		t.Errorf("expected error for an unachievable target")
+	}
+	// load beyond the available replicas
+	c.Variants = c.Variants[1:]
+	if _, err := c.Optimize(1000, testRequestSize(), &analyzer.TargetPerf{TargetITL: 40}); err == nil {
+		t.Errorf("expected error for a load over the capacity")
+	}
+}
@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Closed network of a fixed population of users cycling between a think (delay) station and a
+// service station with state-dependent (batch) service rate, solved by exact mean value
+// analysis (MVA) for load-dependent stations. With j requests at the service station, the
+// departure rate is servRate[j-1], and servRate[N-1] for j > N (the extra requests wait).
+type ClosedModelStateDependent struct {
+	servRate  []float32 // state-dependent service rate
+	numUsers  int       // population size
+	thinkTime float32   // average think time between the response to a request and the next request
+	isValid   bool      // validity of the solution
+
+	p               []float64 // p[j] = probability of j requests at the service station
+	throughput      float32   // request throughput
+	avgRespTime     float32   // average response time (waiting + service)
+	avgWaitTime     float32   // average waiting time
+	avgServTime     float32   // average service time
+	avgNumInSystem  float32   // average number of requests at the service station (waiting + in service)
+	avgNumInServers float32   // average number of requests in service
+}
+
+func NewClosedModelStateDependent(servRate []float32) *ClosedModelStateDependent {
+	return &ClosedModelStateDependent{
+		servRate: servRate,
+	}
+}
+
+// Solve queueing model given the number of users and the average think time
+func (m *ClosedModelStateDependent) Solve(numUsers int, thinkTime float32) error {
+	m.isValid = false
+	if numUsers <= 0 || thinkTime < 0 || len(m.servRate) == 0 {
+		return fmt.Errorf("invalid closed model: numUsers=%d, thinkTime=%v, numServRates=%d",
+			numUsers, thinkTime, len(m.servRate))
+	}
+	m.numUsers = numUsers
+	m.thinkTime = thinkTime
+
+	// marginal probabilities p(j|n) of j requests at the service station with population n
+	p := make([]float64, numUsers+1)
+	next := make([]float64, numUsers+1)
+	p[0] = 1
+	var throughput, respTime float64
+	for n := 1; n <= numUsers; n++ {
+		// response time by the arrival theorem: an arrival sees the population n-1 in equilibrium
+		respTime = 0
+		for j := 1; j <= n; j++ {
+			respTime += float64(j) / m.rate(j) * p[j-1]
+		}
+		throughput = float64(n) / (float64(thinkTime) + respTime)
+
+		// p(0|n) = Z/n * X(n) * p(0|n-1) follows from the product form, avoiding the
+		// unstable p(0|n) = 1 - sum p(j|n) of the textbook recursion
+		next[0] = float64(thinkTime) / float64(n) * throughput * p[0]
+		sumP := next[0]
+		for j := 1; j <= n; j++ {
+			next[j] = throughput / m.rate(j) * p[j-1]
+			sumP += next[j]
+		}
+		for j := 0; j <= n; j++ {
+			next[j] /= sumP
+		}
+		p, next = next, p
+	}
+	m.p = p
+
+	num := len(m.servRate)
+	var avgNumInSystem, avgNumInServers float64
+	for j := 1; j <= numUsers; j++ {
+		avgNumInSystem += float64(j) * p[j]
+		avgNumInServers += float64(min(j, num)) * p[j]
+	}
+	m.throughput = float32(throughput)
+	m.avgRespTime = float32(respTime)
+	m.avgNumInSystem = float32(avgNumInSystem)
+	m.avgNumInServers = float32(avgNumInServers)
+	m.avgServTime = m.avgNumInServers / m.throughput
+	m.avgWaitTime = max(m.avgRespTime-m.avgServTime, 0)
+	m.isValid = throughput > 0
+	if !m.isValid {
+		return fmt.Errorf("invalid closed model %s", m)
+	}
+	return nil
+}
+
+// service rate with j requests at the service station
+func (m *ClosedModelStateDependent) rate(j int) float64 {
+	return float64(m.servRate[min(j, len(m.servRate))-1])
+}
+
+func (m *ClosedModelStateDependent) IsValid() bool {
+	return m.isValid
+}
+
+func (m *ClosedModelStateDependent) GetNumUsers() int {
+	return m.numUsers
+}
+
+func (m *ClosedModelStateDependent) GetThinkTime() float32 {
+	return m.thinkTime
+}
+
+func (m *ClosedModelStateDependent) GetProbabilities() []float64 {
+	return m.p
+}
+
+func (m *ClosedModelStateDependent) GetThroughput() float32 {
+	return m.throughput
+}
+
+func (m *ClosedModelStateDependent) GetAvgRespTime() float32 {
+	return m.avgRespTime
+}
+
+func (m *ClosedModelStateDependent) GetAvgWaitTime() float32 {
+	return m.avgWaitTime
+}
+
+func (m *ClosedModelStateDependent) GetAvgServTime() float32 {
+	return m.avgServTime
+}
+
+func (m *ClosedModelStateDependent) GetAvgNumInSystem() float32 {
+	return m.avgNumInSystem
+}
+
+func (m *ClosedModelStateDependent) GetAvgNumInServers() float32 {
+	return m.avgNumInServers
+}
+
+func (m *ClosedModelStateDependent) String() string {
+	var b bytes.Buffer
+	b.WriteString("ClosedModelStateDependent: ")
+	fmt.Fprintf(&b, "numUsers=%d; thinkTime=%v; ", m.numUsers, m.thinkTime)
+	fmt.Fprintf(&b, "tput=%v; resp=%v; wait=%v; serv=%v; ", m.throughput, m.avgRespTime, m.avgWaitTime, m.avgServTime)
+	fmt.Fprintf(&b, "numInSystem=%v; numInServers=%v; ", m.avgNumInSystem, m.avgNumInServers)
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClosedModelMatchesProductForm(t *testing.T) {
+	servRate := testServRate()
+	numUsers, thinkTime := 20, float32(5)
+	m := NewClosedModelStateDependent(servRate)
+	if err := m.Solve(numUsers, thinkTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// p(j) is proportional to Z^(n-j)/(n-j)! / (mu(1)*...*mu(j))
+	want := make([]float64, numUsers+1)
+	var sum float64
+	for j := 0; j <= numUsers; j++ {
+		want[j] = math.Pow(float64(thinkTime), float64(numUsers-j))
+		for i := 1; i <= numUsers-j; i++ {
+			want[j] /= float64(i)
+		}
+		for i := 1; i <= j; i++ {
+			want[j] /= float64(servRate[min(i, len(servRate))-1])
+		}
+		sum += want[j]
+	}
+	for j, p := range m.GetProbabilities() {
+		if math.Abs(p-want[j]/sum) > 1e-9 {
+			t.Errorf("p[%d]: got %v, want %v", j, p, want[j]/sum)
+		}
+	}
+
+	// Little's law over the whole cycle
+	cycle := m.GetThroughput() * (thinkTime + m.GetAvgRespTime())
+	if math.Abs(float64(cycle)-float64(numUsers)) > 1e-3 {
+		t.Errorf("users in cycle: got %v, want %d", cycle, numUsers)
+	}
+	if math.Abs(float64(m.GetAvgNumInSystem()-m.GetThroughput()*m.GetAvgRespTime())) > 1e-3 {
+		t.Errorf("users at station: got %v, want %v", m.GetAvgNumInSystem(), m.GetThroughput()*m.GetAvgRespTime())
+	}
+}
+
+func TestClosedModelSaturates(t *testing.T) {
+	servRate := testServRate()
+	m := NewClosedModelStateDependent(servRate)
+	if err := m.Solve(200, 1); err != nil {
+		t.Fatal(err)
+	}
+	maxRate := servRate[len(servRate)-1]
+	if math.Abs(float64(m.GetThroughput()-maxRate)) > 1e-4 {
+		t.Errorf("throughput: got %v, want saturation rate %v", m.GetThroughput(), maxRate)
+	}
+	if m.GetAvgNumInServers() > float32(len(servRate))*1.0001 {
+		t.Errorf("requests in service %v above batch limit %d", m.GetAvgNumInServers(), len(servRate))
+	}
+	if m.GetAvgWaitTime() <= 0 {
+		t.Errorf("saturated station should queue, wait=%v", m.GetAvgWaitTime())
+	}
+	if err := m.Solve(0, 1); err == nil {
+		t.Errorf("expected error for empty population")
+	}
+}
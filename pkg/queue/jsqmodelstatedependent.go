@@ -0,0 +1,226 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// limits on the Newton iteration of the mean-field JSQ(d) model
+const (
+	maxJSQIterations = 100
+	jsqTolerance     = 1e-12
+)
+
+// Mean-field model of a server in a large pool of identical servers, each with its own queue and
+// state-dependent service rates as in MM1ModelStateDependent, under join-the-shortest-of-d routing
+// (JSQ(d), power-of-d choices): each arrival samples d servers at random and joins the one with the
+// fewest requests.
+//
+// In the mean-field limit the servers are independent. With s[n] the fraction of servers holding
+// at least n requests, a server holding exactly n requests receives arrivals at rate
+// lambda * (s[n]^d - s[n+1]^d) / p[n], lambda being the arrival rate per server. The distribution
+// of a server is then that of a birth-death chain with these state-dependent arrival rates, whose
+// balance across each cut, lambda * (s[n-1]^d - s[n]^d) = mu(n) * (s[n] - s[n+1]) for n = 1, ..., K,
+// is solved for s by Newton's method (tridiagonal Jacobian), starting from random routing. Arrivals
+// finding all d sampled servers full (probability s[K]^d) are blocked. With d = 1 (random routing)
+// the model reduces to MM1ModelStateDependent.
+//
+// The arrival rate given to Solve is the rate per server. Arrivals join a server holding n requests
+// with probability s[n]^d - s[n+1]^d, which drives the waiting time distribution.
+type JSQModelStateDependent struct {
+	*MM1ModelStateDependent           // birth-death chain of a server, with state-dependent arrival rates
+	choices                 int       // number of servers sampled per arrival (d)
+	arrivalRate             []float64 // arrival rate to a server holding n requests (n = 0, ..., K-1)
+	iterations              int       // number of Newton iterations of the last solution
+}
+
+func NewJSQModelStateDependent(K int, servRate []float32, choices int) *JSQModelStateDependent {
+	m := &JSQModelStateDependent{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, servRate),
+		choices:                choices,
+		arrivalRate:            make([]float64, K),
+	}
+	m.pArrival = make([]float64, K+1)
+	m.QueueModel.computeStatistics = m.computeStatistics
+	return m
+}
+
+// Solve queueing model given the arrival rate per server
+func (m *JSQModelStateDependent) Solve(lambda float32, mu float32) {
+	m.MM1ModelStateDependent.Solve(lambda, mu)
+}
+
+// Evaluate performance measures of queueing model
+func (m *JSQModelStateDependent) computeStatistics() {
+	if !m.isValid {
+		return
+	}
+	if m.lambda <= 0 || m.choices < 1 {
+		m.isValid = false
+		return
+	}
+
+	// start from random routing: the birth-death chain with constant arrival rate
+	lambda := float64(m.lambda)
+	for n := range m.arrivalRate {
+		m.arrivalRate[n] = lambda
+	}
+	m.computeProbabilities()
+	tail := make([]float64, m.K+2)
+	m.tailProbabilities(tail)
+	m.iterations = 0
+	if m.choices > 1 {
+		// continuation over the number of choices, each solution starting the next
+		for d := 2; d <= m.choices; d++ {
+			if err := m.solveTail(tail, d); err != nil {
+				m.isValid = false
+				return
+			}
+		}
+		for n := 0; n <= m.K; n++ {
+			m.p[n] = tail[n] - tail[n+1]
+		}
+		m.rho = m.ComputeRho()
+	}
+
+	// arrival-epoch distribution (the least loaded of d sampled servers holds n requests),
+	// and the arrival rate to a server by state
+	d := float64(m.choices)
+	for n := 0; n <= m.K; n++ {
+		m.pArrival[n] = math.Pow(tail[n], d) - math.Pow(tail[n+1], d)
+		if n < m.K {
+			if m.p[n] > 0 {
+				m.arrivalRate[n] = lambda * m.pArrival[n] / m.p[n]
+			} else {
+				m.arrivalRate[n] = lambda * d * math.Pow(tail[n], d-1)
+			}
+		}
+	}
+	m.computeMeasures(float32(lambda * (1 - m.pArrival[m.K])))
+}
+
+// Solve the cut balance equations with d choices for the tail probabilities s[1], ..., s[K]
+// (s[0] = 1, s[K+1] = 0) by Newton's method with backtracking, from the given initial tail
+func (m *JSQModelStateDependent) solveTail(tail []float64, choices int) error {
+	K := m.K
+	lambda := float64(m.lambda)
+	d := float64(choices)
+	num := len(m.servRate)
+	mu := func(n int) float64 {
+		return float64(m.servRate[min(n, num)-1])
+	}
+
+	// residuals F[n-1] = lambda * (s[n-1]^d - s[n]^d) - mu(n) * (s[n] - s[n+1]), n = 1, ..., K
+	residual := func(s []float64, f []float64) float64 {
+		var norm float64
+		for n := 1; n <= K; n++ {
+			f[n-1] = lambda*(math.Pow(s[n-1], d)-math.Pow(s[n], d)) - mu(n)*(s[n]-s[n+1])
+			norm = math.Max(norm, math.Abs(f[n-1]))
+		}
+		return norm
+	}
+
+	f := make([]float64, K)
+	lower, diag, upper := make([]float64, K), make([]float64, K), make([]float64, K)
+	step := make([]float64, K)
+	trial := make([]float64, K+2)
+	norm := residual(tail, f)
+	for iterations := 0; iterations < maxJSQIterations && norm > jsqTolerance*lambda; iterations++ {
+		m.iterations++
+
+		// tridiagonal Jacobian J[n-1][k-1] = dF[n-1]/ds[k]
+		for n := 1; n <= K; n++ {
+			lower[n-1] = 0
+			if n > 1 {
+				lower[n-1] = lambda * d * math.Pow(tail[n-1], d-1)
+			}
+			diag[n-1] = -lambda*d*math.Pow(tail[n], d-1) - mu(n)
+			upper[n-1] = mu(n)
+		}
+		for i := range f {
+			step[i] = -f[i]
+		}
+		if err := solveTridiagonal(lower, diag, upper, step); err != nil {
+			return err
+		}
+
+		// backtrack until the residual decreases, keeping the tail non-increasing within [0, 1]
+		t := 1.0
+		for {
+			trial[0], trial[K+1] = 1, 0
+			for n := 1; n <= K; n++ {
+				trial[n] = min(max(tail[n]+t*step[n-1], 0), trial[n-1])
+			}
+			if next := residual(trial, f); next < norm || t < 1e-10 {
+				norm = next
+				break
+			}
+			t /= 2
+		}
+		copy(tail, trial)
+	}
+	if math.IsNaN(norm) || norm > jsqTolerance*lambda {
+		return fmt.Errorf("JSQ(%d) model did not converge in %d iterations (residual %g)", choices, maxJSQIterations, norm)
+	}
+	return nil
+}
+
+// Solve the tridiagonal system with sub-diagonal a (a[0] unused), diagonal b and super-diagonal c
+// (c[n-1] unused) in place of the right-hand side x, by the Thomas algorithm
+func solveTridiagonal(a, b, c, x []float64) error {
+	n := len(x)
+	cp := make([]float64, n)
+	denom := b[0]
+	for i := range n {
+		if i > 0 {
+			denom = b[i] - a[i]*cp[i-1]
+			x[i] -= a[i] * x[i-1]
+		}
+		if denom == 0 {
+			return fmt.Errorf("singular tridiagonal system")
+		}
+		cp[i] = c[i] / denom
+		x[i] /= denom
+	}
+	for i := n - 2; i >= 0; i-- {
+		x[i] -= cp[i] * x[i+1]
+	}
+	return nil
+}
+
+// fraction of servers holding at least n requests, tail[n] for n = 0, ..., K+1
+func (m *JSQModelStateDependent) tailProbabilities(tail []float64) {
+	tail[m.K+1] = 0
+	for n := m.K; n >= 0; n-- {
+		tail[n] = tail[n+1] + m.p[n]
+	}
+	tail[0] = 1
+}
+
+// number of servers sampled per arrival (d)
+func (m *JSQModelStateDependent) GetChoices() int {
+	return m.choices
+}
+
+// arrival rate to a server holding n requests (n = 0, ..., K-1)
+func (m *JSQModelStateDependent) GetArrivalRates() []float64 {
+	return m.arrivalRate
+}
+
+// queue length distribution seen by arrivals, pArrival[K] being the blocking probability
+func (m *JSQModelStateDependent) GetArrivalProbabilities() []float64 {
+	return m.pArrival
+}
+
+// number of Newton iterations of the last solution
+func (m *JSQModelStateDependent) GetIterations() int {
+	return m.iterations
+}
+
+func (m *JSQModelStateDependent) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "JSQModelStateDependent: choices=%d; iterations=%d; ", m.choices, m.iterations)
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
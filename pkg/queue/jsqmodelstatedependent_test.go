@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+// Random routing (d = 1) is the single-server state-dependent chain.
+func TestJSQOneChoiceIsStateDependent(t *testing.T) {
+	servRate := testServRate()
+	base := NewMM1ModelStateDependent(100, servRate)
+	base.Solve(1.2, 1)
+	m := NewJSQModelStateDependent(100, servRate, 1)
+	m.Solve(1.2, 1)
+	if !m.IsValid() {
+		t.Fatalf("invalid model %s", m)
+	}
+	if math.Abs(float64(m.GetAvgWaitTime()-base.GetAvgWaitTime())) > 1e-4 ||
+		math.Abs(float64(m.GetThroughput()-base.GetThroughput())) > 1e-5 ||
+		math.Abs(float64(m.GetWaitTimePercentile(0.9)-base.GetWaitTimePercentile(0.9))) > 1e-3 {
+		t.Errorf("got %s, want %s", m, base)
+	}
+}
+
+// With a constant service rate and a large K, the mean-field fixed point is the classic
+// s[n] = rho^((d^n - 1) / (d - 1)) of the supermarket model (Mitzenmacher, Vvedenskaya et al.).
+func TestJSQMatchesSupermarketModel(t *testing.T) {
+	lambda, mu := float32(0.9), float32(1.0)
+	rho := float64(lambda / mu)
+	for _, d := range []int{2, 3} {
+		m := NewJSQModelStateDependent(60, []float32{mu}, d)
+		m.Solve(lambda, 1)
+		if !m.IsValid() {
+			t.Fatalf("d=%d: invalid model %s", d, m)
+		}
+		p := m.GetProbabilities()
+		tail := 1.0
+		var avgNumInSystem float64
+		for n := 1; n < 8; n++ {
+			tail -= p[n-1]
+			want := math.Pow(rho, (math.Pow(float64(d), float64(n))-1)/float64(d-1))
+			if math.Abs(tail-want) > 1e-4 {
+				t.Errorf("d=%d: s[%d] got %v, want %v", d, n, tail, want)
+			}
+			avgNumInSystem += want
+		}
+		if got := float64(m.GetAvgNumInSystem()); math.Abs(got-avgNumInSystem) > 1e-3 {
+			t.Errorf("d=%d: number in system got %v, want %v", d, got, avgNumInSystem)
+		}
+		if got := float64(m.GetThroughput()); math.Abs(got-float64(lambda)) > 1e-4 {
+			t.Errorf("d=%d: throughput got %v, want %v", d, got, lambda)
+		}
+	}
+}
+
+// More choices shorten the wait, at the same throughput.
+func TestJSQWaitDecreasesWithChoices(t *testing.T) {
+	servRate := testServRate()
+	prevWait := float32(math.Inf(1))
+	for d := 1; d <= 4; d++ {
+		m := NewJSQModelStateDependent(64, servRate, d)
+		m.Solve(1.5, 1)
+		if !m.IsValid() {
+			t.Fatalf("d=%d: invalid model %s", d, m)
+		}
+		if wait := m.GetAvgWaitTime(); wait >= prevWait {
+			t.Errorf("d=%d: wait %v not below %v", d, wait, prevWait)
+		} else {
+			prevWait = wait
+		}
+		var sum float64
+		for _, p := range m.GetArrivalProbabilities() {
+			sum += p
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("d=%d: arrival probabilities sum to %v", d, sum)
+		}
+	}
+}
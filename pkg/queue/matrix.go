@@ -0,0 +1,142 @@
+package queue
+
+import (
+	"fmt"
+	"math"
+)
+
+// dense matrix of float64, used by the matrix-analytic (QBD) solvers
+type matrix [][]float64
+
+func newMatrix(rows, cols int) matrix {
+	a := make(matrix, rows)
+	for i := range a {
+		a[i] = make([]float64, cols)
+	}
+	return a
+}
+
+// diagonal matrix with the given diagonal
+func diagMatrix(d []float64) matrix {
+	a := newMatrix(len(d), len(d))
+	for i, v := range d {
+		a[i][i] = v
+	}
+	return a
+}
+
+// matrix product a * b
+func (a matrix) mul(b matrix) matrix {
+	c := newMatrix(len(a), len(b[0]))
+	for i := range a {
+		for k, aik := range a[i] {
+			if aik == 0 {
+				continue
+			}
+			for j, bkj := range b[k] {
+				c[i][j] += aik * bkj
+			}
+		}
+	}
+	return c
+}
+
+// matrix sum a + b
+func (a matrix) add(b matrix) matrix {
+	c := newMatrix(len(a), len(a[0]))
+	for i := range a {
+		for j := range a[i] {
+			c[i][j] = a[i][j] + b[i][j]
+		}
+	}
+	return c
+}
+
+// scalar multiple s * a
+func (a matrix) scale(s float64) matrix {
+	c := newMatrix(len(a), len(a[0]))
+	for i := range a {
+		for j := range a[i] {
+			c[i][j] = s * a[i][j]
+		}
+	}
+	return c
+}
+
+// row vector product x * a
+func vecMul(x []float64, a matrix) []float64 {
+	y := make([]float64, len(a[0]))
+	for k, xk := range x {
+		if xk == 0 {
+			continue
+		}
+		for j, akj := range a[k] {
+			y[j] += xk * akj
+		}
+	}
+	return y
+}
+
+// inverse of a square matrix, by Gauss-Jordan elimination with partial pivoting
+func (a matrix) inverse() (matrix, error) {
+	n := len(a)
+	w := newMatrix(n, 2*n)
+	for i := range n {
+		copy(w[i], a[i])
+		w[i][n+i] = 1
+	}
+	for col := range n {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(w[r][col]) > math.Abs(w[pivot][col]) {
+				pivot = r
+			}
+		}
+		if w[pivot][col] == 0 {
+			return nil, fmt.Errorf("singular matrix")
+		}
+		w[col], w[pivot] = w[pivot], w[col]
+		inv := 1 / w[col][col]
+		for j := range w[col] {
+			w[col][j] *= inv
+		}
+		for r := range n {
+			if r == col || w[r][col] == 0 {
+				continue
+			}
+			f := w[r][col]
+			for j := range w[r] {
+				w[r][j] -= f * w[col][j]
+			}
+		}
+	}
+	inv := newMatrix(n, n)
+	for i := range n {
+		copy(inv[i], w[i][n:])
+	}
+	return inv, nil
+}
+
+// solve x * a = 0 with sum(x) = 1, for a singular generator-like matrix a
+func stationaryVector(a matrix) ([]float64, error) {
+	n := len(a)
+	// transpose the system, replacing the last equation by the normalization
+	t := newMatrix(n, n)
+	for i := range n {
+		for j := range n {
+			t[j][i] = a[i][j]
+		}
+	}
+	for j := range n {
+		t[n-1][j] = 1
+	}
+	inv, err := t.inverse()
+	if err != nil {
+		return nil, err
+	}
+	x := make([]float64, n)
+	for i := range n {
+		x[i] = inv[i][n-1]
+	}
+	return x, nil
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// M/G(n)/1/K model: state-dependent service rates as in MM1ModelStateDependent, with a general
+// (non-exponential) service time distribution of given squared coefficient of variation (SCV).
+//
+// The state probabilities, throughput and number in service are those of the exponential chain.
+// The waiting time is corrected by the two-moment Allen-Cunneen approximation for Poisson arrivals,
+// Wq(G) = Wq(M) * (1 + scv) / 2, and the queue length follows by Little's law. The waiting time
+// distribution (GetWaitTimeCDF) of the exponential chain is stretched by the same factor, keeping
+// the probability of waiting unchanged.
+type MG1ModelStateDependent struct {
+	*MM1ModelStateDependent         // birth-death chain with exponential service
+	scv                     float32 // squared coefficient of variation of the service time
+}
+
+func NewMG1ModelStateDependent(K int, servRate []float32, scv float32) *MG1ModelStateDependent {
+	m := &MG1ModelStateDependent{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, servRate),
+		scv:                    scv,
+	}
+	m.QueueModel.computeStatistics = m.computeStatistics
+	return m
+}
+
+// Evaluate performance measures of queueing model
+func (m *MG1ModelStateDependent) computeStatistics() {
+	if !m.isValid {
+		return
+	}
+	if m.scv < 0 {
+		m.isValid = false
+		return
+	}
+	m.MM1ModelStateDependent.computeStatistics()
+
+	factor := m.GetCorrectionFactor()
+	m.waitTimeScale = factor
+	m.avgWaitTime *= factor
+	m.avgRespTime = m.avgWaitTime + m.avgServTime
+	m.avgQueueLength = m.throughput * m.avgWaitTime
+	m.avgNumInSystem = m.avgQueueLength + m.avgNumInServers
+}
+
+// squared coefficient of variation of the service time
+func (m *MG1ModelStateDependent) GetSCV() float32 {
+	return m.scv
+}
+
+// factor applied to the waiting time of the exponential chain
+func (m *MG1ModelStateDependent) GetCorrectionFactor() float32 {
+	return (1 + m.scv) / 2
+}
+
+func (m *MG1ModelStateDependent) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "MG1ModelStateDependent: scv=%v; ", m.scv)
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+// With a single server and a large K, the correction is exact: the Pollaczek-Khinchine mean wait
+// Wq = rho * (1 + scv) / (2 * mu * (1 - rho)).
+func TestGeneralServiceMatchesPollaczekKhinchine(t *testing.T) {
+	lambda, mu := float32(0.8), float32(1.0)
+	rho := float64(lambda / mu)
+	for _, scv := range []float32{0, 0.5, 1, 4} {
+		m := NewMG1ModelStateDependent(2000, []float32{mu}, scv)
+		m.Solve(lambda, 1)
+		if !m.IsValid() {
+			t.Fatalf("scv=%v: invalid model %s", scv, m)
+		}
+		want := rho * (1 + float64(scv)) / (2 * float64(mu) * (1 - rho))
+		if got := m.GetAvgWaitTime(); math.Abs(float64(got)-want)/want > 1e-3 {
+			t.Errorf("scv=%v: wait got %v, want %v", scv, got, want)
+		}
+		if got, want := m.GetAvgRespTime(), m.GetAvgWaitTime()+1/mu; math.Abs(float64(got-want)) > 1e-4 {
+			t.Errorf("scv=%v: response time got %v, want %v", scv, got, want)
+		}
+		// probability of waiting is unchanged
+		if got := m.GetWaitTimeCDF(0); math.Abs(float64(got)-(1-rho)) > 1e-3 {
+			t.Errorf("scv=%v: P[W=0] got %v, want %v", scv, got, 1-rho)
+		}
+	}
+}
+
+func TestGeneralServiceExponentialIsStateDependent(t *testing.T) {
+	servRate := testServRate()
+	base := NewMM1ModelStateDependent(100, servRate)
+	base.Solve(1.2, 1)
+	m := NewMG1ModelStateDependent(100, servRate, 1)
+	m.Solve(1.2, 1)
+	if m.GetAvgWaitTime() != base.GetAvgWaitTime() || m.GetWaitTimePercentile(0.9) != base.GetWaitTimePercentile(0.9) {
+		t.Errorf("got %s, want %s", m, base)
+	}
+	invalid := NewMG1ModelStateDependent(100, servRate, -1)
+	invalid.Solve(1.2, 1)
+	if invalid.IsValid() {
+		t.Errorf("expected invalid model for negative scv")
+	}
+}
@@ -0,0 +1,158 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// Distribution of the patience of waiting requests (time until the client gives up)
+type PatienceKind int
+
+const (
+	// each waiting request abandons at rate 1/patienceTime
+	ExponentialPatience PatienceKind = iota
+	// a request abandons once it has waited patienceTime
+	DeterministicPatience
+)
+
+// M/M(n)/1/K+G model: state-dependent service rates as in MM1ModelStateDependent, with waiting
+// requests abandoning the queue when their patience runs out.
+//
+//   - exponential patience: each of the n-N waiting requests reneges at rate theta = 1/patienceTime,
+//     adding theta*(n-N) to the death rate in state n.
+//   - deterministic patience: an arrival finding n >= N requests waits Erlang(n-N+1, servRate[N-1]),
+//     so it abandons if and only if that wait exceeds patienceTime. As requests ahead that will abandon
+//     never delay it, the model is equivalent to state-dependent balking, where an arrival joins with
+//     probability P[Erlang(n-N+1, servRate[N-1]) <= patienceTime], and all requests that join are served.
+//
+// The waiting time distribution (GetWaitTimeCDF) is that of the Erlang waits of all admitted
+// requests, ignoring abandonment; with deterministic patience, its value at patienceTime is the
+// probability that an admitted request is served.
+type MM1ModelAbandonment struct {
+	*MM1ModelStateDependent              // birth-death chain with abandonment
+	patience                PatienceKind // patience distribution
+	patienceTime            float32      // mean (exponential) or fixed (deterministic) patience
+}
+
+func NewMM1ModelAbandonment(K int, servRate []float32, patience PatienceKind, patienceTime float32) *MM1ModelAbandonment {
+	m := &MM1ModelAbandonment{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, servRate),
+		patience:               patience,
+		patienceTime:           patienceTime,
+	}
+	m.QueueModel.computeStatistics = m.computeStatistics
+	return m
+}
+
+// Evaluate performance measures of queueing model
+func (m *MM1ModelAbandonment) computeStatistics() {
+	if !m.isValid {
+		return
+	}
+	if m.patienceTime <= 0 || m.lambda <= 0 {
+		m.isValid = false
+		return
+	}
+	join := m.joinProbabilities()
+	m.computeProbabilities(join)
+
+	num := len(m.servRate)
+	var queueLength float64
+	for n := num + 1; n <= m.K; n++ {
+		queueLength += float64(n-num) * m.p[n]
+	}
+	admitted := float64(m.lambda) * (1 - m.p[m.K])
+
+	switch m.patience {
+	case DeterministicPatience:
+		// requests that balk are those that would abandon; all those joining are served
+		var joined float64
+		for n := 0; n < m.K; n++ {
+			joined += m.p[n] * join[n]
+		}
+		joined *= float64(m.lambda)
+		m.abandonRate = float32(admitted - joined)
+		m.computeMeasures(float32(joined))
+	default:
+		m.abandonRate = float32(queueLength) / m.patienceTime
+		m.computeMeasures(float32(admitted) - m.abandonRate)
+		// time in queue of admitted requests, whether eventually served or not
+		m.avgWaitTime = float32(queueLength / admitted)
+		m.avgRespTime = m.avgWaitTime + m.avgServTime
+		m.avgQueueLength = float32(queueLength)
+	}
+}
+
+// probability that an arrival finding n requests joins the queue (deterministic patience)
+func (m *MM1ModelAbandonment) joinProbabilities() []float64 {
+	join := make([]float64, m.K+1)
+	num := len(m.servRate)
+	x := float64(m.servRate[num-1]) * float64(m.patienceTime)
+	var poissonCDF float64
+	for n := range join {
+		if n < num || m.patience != DeterministicPatience {
+			join[n] = 1
+			continue
+		}
+		// P[Erlang(n-N+1, mu) <= d] = P[Poisson(mu*d) >= n-N+1]
+		poissonCDF += poissonPMF(n-num, x)
+		join[n] = math.Max(1-poissonCDF, 0)
+	}
+	return join
+}
+
+// Compute state probabilities of the birth-death chain with abandonment
+func (m *MM1ModelAbandonment) computeProbabilities(join []float64) {
+	num := len(m.servRate)
+	theta := 0.0
+	if m.patience == ExponentialPatience {
+		theta = 1 / float64(m.patienceTime)
+	}
+	m.p[0] = 1
+	for n := 0; n < m.K; n++ {
+		death := float64(m.servRate[min(n+1, num)-1]) + theta*float64(max(n+1-num, 0))
+		m.p[n+1] = m.p[n] * float64(m.lambda) * join[n] / death
+		if m.p[n+1] > rescaleThreshold {
+			for i := 0; i <= n+1; i++ {
+				m.p[i] /= rescaleThreshold
+			}
+		}
+	}
+	var sum float64
+	for _, p := range m.p {
+		sum += p
+	}
+	m.sumP = 0
+	for n := range m.p {
+		m.p[n] /= sum
+		m.sumP += m.p[n]
+	}
+	m.rho = m.ComputeRho()
+}
+
+func (m *MM1ModelAbandonment) GetPatience() PatienceKind {
+	return m.patience
+}
+
+func (m *MM1ModelAbandonment) GetPatienceTime() float32 {
+	return m.patienceTime
+}
+
+func (m *MM1ModelAbandonment) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "MM1ModelAbandonment: patience=%s(%v); abandonRate=%v; ", m.patience, m.patienceTime, m.abandonRate)
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
+
+func (k PatienceKind) String() string {
+	switch k {
+	case ExponentialPatience:
+		return "exponential"
+	case DeterministicPatience:
+		return "deterministic"
+	default:
+		return fmt.Sprintf("PatienceKind(%d)", int(k))
+	}
+}
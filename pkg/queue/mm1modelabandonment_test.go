@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAbandonmentWithLargePatienceIsStateDependent(t *testing.T) {
+	servRate := testServRate()
+	base := NewMM1ModelStateDependent(100, servRate)
+	base.Solve(1.2, 1)
+	for _, patience := range []PatienceKind{ExponentialPatience, DeterministicPatience} {
+		m := NewMM1ModelAbandonment(100, servRate, patience, 1e9)
+		m.Solve(1.2, 1)
+		if !m.IsValid() {
+			t.Fatalf("%s: invalid model %s", patience, m)
+		}
+		if math.Abs(float64(m.GetAvgRespTime()-base.GetAvgRespTime())) > 1e-3*float64(base.GetAvgRespTime()) ||
+			m.GetAbandonRate() > 1e-6 {
+			t.Errorf("%s: got %s, want %s", patience, m, base)
+		}
+	}
+}
+
+func TestAbandonmentConservesRequests(t *testing.T) {
+	servRate := testServRate()
+	lambda := float32(2) // above the max service rate 1.6
+	for _, patience := range []PatienceKind{ExponentialPatience, DeterministicPatience} {
+		m := NewMM1ModelAbandonment(200, servRate, patience, 10)
+		m.Solve(lambda, 1)
+		if !m.IsValid() {
+			t.Fatalf("%s: invalid model %s", patience, m)
+		}
+		p := m.GetProbabilities()
+		admitted := lambda * (1 - float32(p[len(p)-1]))
+		if math.Abs(float64(admitted-m.GetThroughput()-m.GetAbandonRate())) > 1e-4 {
+			t.Errorf("%s: admitted %v != served %v + abandoned %v", patience, admitted, m.GetThroughput(), m.GetAbandonRate())
+		}
+		// departures out of service
+		var served float64
+		for n := 1; n < len(p); n++ {
+			served += p[n] * float64(servRate[min(n, len(servRate))-1])
+		}
+		if math.Abs(served-float64(m.GetThroughput())) > 1e-4 {
+			t.Errorf("%s: throughput %v, want service departures %v", patience, m.GetThroughput(), served)
+		}
+		if m.GetAbandonRate() < lambda-servRate[len(servRate)-1]-1e-3 {
+			t.Errorf("%s: overload should be shed by abandonment, rate %v", patience, m.GetAbandonRate())
+		}
+	}
+}
@@ -5,11 +5,20 @@ import (
 	"math"
 )
 
+// limits on the search for waiting time quantiles
+const (
+	maxQuantileIterations = 100
+	quantileTolerance     = 1e-6
+)
+
 // M/M/1 model with state dependent service rate
 type MM1ModelStateDependent struct {
 	MM1KModel                 // extends base class
 	servRate        []float32 // state-dependent service rate
 	avgNumInServers float32
+	pArrival        []float64 // state probabilities seen by arrivals (nil => p, Poisson arrivals see time averages)
+	abandonRate     float32   // rate of admitted requests abandoning the queue before service (0 => no abandonment)
+	waitTimeScale   float32   // scale of the waiting time distribution of a general-service variant (0 => 1)
 }
 
 func NewMM1ModelStateDependent(K int, servRate []float32) *MM1ModelStateDependent {
@@ -40,7 +49,11 @@ func (m *MM1ModelStateDependent) computeStatistics() {
 		return
 	}
 	m.computeProbabilities()
+	m.computeMeasures(m.lambda * (1 - float32(m.p[m.K])))
+}
 
+// Evaluate performance measures from the state probabilities, given the throughput
+func (m *MM1ModelStateDependent) computeMeasures(throughput float32) {
 	// calculate avgNumInServers
 	num := len(m.servRate)
 	var avgNumInServers float64
@@ -56,7 +69,7 @@ func (m *MM1ModelStateDependent) computeStatistics() {
 	m.avgNumInServers = float32(avgNumInServers)
 	m.avgNumInSystem = float32(avgNumInSystem)
 
-	m.throughput = m.lambda * (1 - float32(m.p[m.K]))
+	m.throughput = throughput
 	m.avgRespTime = m.avgNumInSystem / m.throughput
 	m.avgServTime = m.avgNumInServers / m.throughput
 	m.avgWaitTime = m.avgRespTime - m.avgServTime
@@ -119,6 +132,115 @@ func (m *MM1ModelStateDependent) GetAvgNumInServers() float32 {
 	return m.avgNumInServers
 }
 
+// distribution of the number of requests in service: P[j] for j = 0, ..., N (N = number of servers),
+// with P[N] = P[n >= N]
+func (m *MM1ModelStateDependent) GetBatchSizeProbabilities() []float64 {
+	num := len(m.servRate)
+	probs := make([]float64, num+1)
+	for n, p := range m.p {
+		probs[min(n, num)] += p
+	}
+	return probs
+}
+
+// rate of admitted requests abandoning the queue before service
+func (m *MM1ModelStateDependent) GetAbandonRate() float32 {
+	return m.abandonRate
+}
+
+// state-dependent service rates: servRate[n-1] is the rate with n in service
+func (m *MM1ModelStateDependent) GetServRate() []float32 {
+	return m.servRate
+}
+
+// state probabilities seen by arrivals
+func (m *MM1ModelStateDependent) arrivalProbabilities() []float64 {
+	if m.pArrival != nil {
+		return m.pArrival
+	}
+	return m.p
+}
+
+// Compute the probability that an admitted request waits at most t before entering service.
+// An arrival finding n >= N requests in system (N = number of servers) waits for n-N+1
+// departures, each occurring at the saturated service rate servRate[N-1], hence its
+// waiting time is Erlang(n-N+1, servRate[N-1]); arrivals finding n < N do not wait.
+func (m *MM1ModelStateDependent) GetWaitTimeCDF(t float32) float32 {
+	if !m.isValid {
+		return 0
+	}
+	if t < 0 {
+		return 0
+	}
+	if m.waitTimeScale > 0 {
+		t /= m.waitTimeScale
+	}
+	num := len(m.servRate)
+	p := m.arrivalProbabilities()
+	admitted := 1 - p[m.K]
+	if admitted <= 0 {
+		return 0
+	}
+
+	// P[W > t] = sum_{n=N}^{K-1} p[n] * P[Poisson(mu*t) <= n-N]
+	x := float64(m.servRate[num-1]) * float64(t)
+	var tail, poissonCDF float64
+	for n := num; n < m.K; n++ {
+		i := n - num
+		poissonCDF += poissonPMF(i, x)
+		tail += p[n] * math.Min(poissonCDF, 1)
+	}
+	cdf := 1 - tail/admitted
+	return float32(min(max(cdf, 0), 1))
+}
+
+// Compute the q-th quantile (0 < q < 1) of the waiting time of admitted requests
+func (m *MM1ModelStateDependent) GetWaitTimePercentile(q float32) float32 {
+	if !m.isValid || q <= 0 || q >= 1 {
+		return 0
+	}
+	if m.GetWaitTimeCDF(0) >= q {
+		return 0
+	}
+
+	// bracket the quantile, starting from the mean time between saturated departures
+	lo := float32(0)
+	hi := 1 / m.servRate[len(m.servRate)-1]
+	for range maxQuantileIterations {
+		if m.GetWaitTimeCDF(hi) >= q {
+			break
+		}
+		lo = hi
+		hi *= 2
+	}
+
+	// bisect
+	for range maxQuantileIterations {
+		mid := 0.5 * (lo + hi)
+		if m.GetWaitTimeCDF(mid) >= q {
+			hi = mid
+		} else {
+			lo = mid
+		}
+		if hi-lo <= quantileTolerance*hi {
+			break
+		}
+	}
+	return hi
+}
+
+// Poisson probability mass P[X = i] for mean x, computed in log space to avoid underflow
+func poissonPMF(i int, x float64) float64 {
+	if x <= 0 {
+		if i == 0 {
+			return 1
+		}
+		return 0
+	}
+	lg, _ := math.Lgamma(float64(i + 1))
+	return math.Exp(-x + float64(i)*math.Log(x) - lg)
+}
+
 func (m *MM1ModelStateDependent) String() string {
 	var b bytes.Buffer
 	b.WriteString("MM1ModelStateDependent: ")
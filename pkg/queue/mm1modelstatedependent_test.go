@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+// With a single server and a large K, the state-dependent model reduces to M/M/1,
+// whose waiting time satisfies P[W > t] = rho * exp(-mu*(1-rho)*t).
+func TestWaitTimeCDFMatchesMM1(t *testing.T) {
+	lambda, mu := float32(0.8), float32(1.0)
+	m := NewMM1ModelStateDependent(2000, []float32{mu})
+	m.Solve(lambda, 1)
+	if !m.IsValid() {
+		t.Fatalf("invalid model %s", m)
+	}
+	rho := float64(lambda / mu)
+	for _, x := range []float32{0, 0.5, 2, 10, 25} {
+		want := 1 - rho*math.Exp(-float64(mu)*(1-rho)*float64(x))
+		if got := m.GetWaitTimeCDF(x); math.Abs(float64(got)-want) > 1e-3 {
+			t.Errorf("CDF(%v): got %v, want %v", x, got, want)
+		}
+	}
+
+	// quantile inverts the closed form
+	q := float32(0.9)
+	want := math.Log(rho/(1-float64(q))) / (float64(mu) * (1 - rho))
+	if got := m.GetWaitTimePercentile(q); math.Abs(float64(got)-want)/want > 1e-3 {
+		t.Errorf("P90: got %v, want %v", got, want)
+	}
+}
+
+func TestWaitTimePercentileNoQueueing(t *testing.T) {
+	// light load on many servers: almost nobody waits
+	servRate := make([]float32, 64)
+	for n := range servRate {
+		servRate[n] = float32(n + 1)
+	}
+	m := NewMM1ModelStateDependent(128, servRate)
+	m.Solve(1, 1)
+	if got := m.GetWaitTimePercentile(0.99); got != 0 {
+		t.Errorf("P99 wait: got %v, want 0", got)
+	}
+}
+
+func TestBatchSizeProbabilities(t *testing.T) {
+	servRate := []float32{0.5, 0.8, 1.0}
+	m := NewMM1ModelStateDependent(20, servRate)
+	m.Solve(0.7, 1)
+	probs := m.GetBatchSizeProbabilities()
+	if len(probs) != len(servRate)+1 {
+		t.Fatalf("length: got %d, want %d", len(probs), len(servRate)+1)
+	}
+	var sum, mean float64
+	for j, p := range probs {
+		sum += p
+		mean += float64(j) * p
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("probabilities sum to %v", sum)
+	}
+	if math.Abs(mean-float64(m.GetAvgNumInServers())) > 1e-5 {
+		t.Errorf("mean batch size %v, want %v", mean, m.GetAvgNumInServers())
+	}
+}
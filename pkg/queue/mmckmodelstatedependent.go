@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Dispatching rule assigning the requests in service to the servers of a pool
+type DispatchPolicy int
+
+const (
+	// requests are spread evenly, each server holding floor(n/c) or ceil(n/c) of the n requests in service
+	BalancedDispatch DispatchPolicy = iota
+	// requests fill one server up to its batch limit before the next server receives any
+	PackedDispatch
+)
+
+// M/M/c/K model of c servers sharing one queue, each server having state-dependent (batch) service rates.
+// The total departure rate with n requests in system is the sum of the per-server rates at the
+// batch sizes given by the dispatching rule, yielding a birth-death chain over the pool occupancy.
+type MMCKModelStateDependent struct {
+	*MM1ModelStateDependent                // birth-death chain over the number in the pool
+	numServers              int            // number of servers c
+	dispatch                DispatchPolicy // dispatching rule
+	serverRate              []float32      // per-server service rate at batch size b (b = 1, ..., N)
+}
+
+func NewMMCKModelStateDependent(K int, serverRate []float32, numServers int, dispatch DispatchPolicy) *MMCKModelStateDependent {
+	return &MMCKModelStateDependent{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, PoolServiceRates(serverRate, numServers, dispatch)),
+		numServers:             numServers,
+		dispatch:               dispatch,
+		serverRate:             serverRate,
+	}
+}
+
+// Compute the total service rate of a pool of servers, given the per-server service rate at batch
+// size b (serverRate[b-1], b = 1, ..., N) and the dispatching rule.
+// Returns poolRate[n-1], the total rate with n requests in service (n = 1, ..., c*N).
+func PoolServiceRates(serverRate []float32, numServers int, dispatch DispatchPolicy) []float32 {
+	N := len(serverRate)
+	c := max(numServers, 1)
+	rate := func(b int) float32 {
+		if b <= 0 {
+			return 0
+		}
+		return serverRate[b-1]
+	}
+	poolRate := make([]float32, c*N)
+	for n := 1; n <= c*N; n++ {
+		var total float32
+		switch dispatch {
+		case PackedDispatch:
+			full := n / N
+			total = float32(full)*serverRate[N-1] + rate(n-full*N)
+		default:
+			low := n / c
+			numHigh := n - low*c
+			total = float32(c-numHigh) * rate(low)
+			if numHigh > 0 {
+				total += float32(numHigh) * rate(low+1)
+			}
+		}
+		poolRate[n-1] = total
+	}
+	return poolRate
+}
+
+func (m *MMCKModelStateDependent) GetNumServers() int {
+	return m.numServers
+}
+
+func (m *MMCKModelStateDependent) GetDispatch() DispatchPolicy {
+	return m.dispatch
+}
+
+// average number of requests in service per server
+func (m *MMCKModelStateDependent) GetAvgNumInServersPerServer() float32 {
+	return m.GetAvgNumInServers() / float32(m.numServers)
+}
+
+func (m *MMCKModelStateDependent) String() string {
+	var b bytes.Buffer
+	b.WriteString("MMCKModelStateDependent: ")
+	fmt.Fprintf(&b, "c=%d; dispatch=%s; ", m.numServers, m.dispatch)
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
+
+func (d DispatchPolicy) String() string {
+	switch d {
+	case BalancedDispatch:
+		return "balanced"
+	case PackedDispatch:
+		return "packed"
+	default:
+		return fmt.Sprintf("DispatchPolicy(%d)", int(d))
+	}
+}
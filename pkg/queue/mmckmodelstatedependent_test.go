@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPoolServiceRates(t *testing.T) {
+	// per-server rate at batch b is b/(1+b)
+	serverRate := []float32{1.0 / 2, 2.0 / 3, 3.0 / 4}
+
+	balanced := PoolServiceRates(serverRate, 2, BalancedDispatch)
+	wantBalanced := []float32{1.0 / 2, 1, 1.0/2 + 2.0/3, 4.0 / 3, 2.0/3 + 3.0/4, 3.0 / 2}
+	packed := PoolServiceRates(serverRate, 2, PackedDispatch)
+	wantPacked := []float32{1.0 / 2, 2.0 / 3, 3.0 / 4, 3.0/4 + 1.0/2, 3.0/4 + 2.0/3, 3.0 / 2}
+	for n := range wantBalanced {
+		if math.Abs(float64(balanced[n]-wantBalanced[n])) > 1e-6 {
+			t.Errorf("balanced n=%d: got %v, want %v", n+1, balanced[n], wantBalanced[n])
+		}
+		if math.Abs(float64(packed[n]-wantPacked[n])) > 1e-6 {
+			t.Errorf("packed n=%d: got %v, want %v", n+1, packed[n], wantPacked[n])
+		}
+	}
+}
+
+func TestSingleServerPoolMatchesMM1StateDependent(t *testing.T) {
+	serverRate := []float32{0.5, 0.8, 1.0, 1.1}
+	pool := NewMMCKModelStateDependent(40, serverRate, 1, BalancedDispatch)
+	single := NewMM1ModelStateDependent(40, serverRate)
+	pool.Solve(0.9, 1)
+	single.Solve(0.9, 1)
+	if pool.GetAvgRespTime() != single.GetAvgRespTime() || pool.GetThroughput() != single.GetThroughput() {
+		t.Errorf("pool %s differs from single server %s", pool, single)
+	}
+}
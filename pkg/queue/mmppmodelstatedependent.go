@@ -0,0 +1,256 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Markov-modulated Poisson process (MMPP): while the modulating Markov chain is in phase j,
+// arrivals occur at rate Rates[j]; the chain switches from phase i to phase j != i at rate Switch[i][j]
+type MMPP struct {
+	Rates  []float32   // arrival rate in each phase
+	Switch [][]float32 // phase switching rates (diagonal ignored)
+}
+
+// MMPP/M(n)/1/K model: state-dependent service rates as in MM1ModelStateDependent, with
+// arrivals from an MMPP. The chain over (number in system n, phase j) is a finite,
+// level-dependent quasi-birth-death (QBD) process solved by linear level reduction.
+type MMPPModelStateDependent struct {
+	*MM1ModelStateDependent             // marginal queue length distribution and statistics
+	arrivals                *MMPP       // arrival process
+	meanRate                float32     // mean arrival rate of the arrival process
+	pi                      [][]float64 // joint state probabilities pi[n][j]
+}
+
+func NewMMPPModelStateDependent(K int, servRate []float32, arrivals *MMPP) (*MMPPModelStateDependent, error) {
+	if err := arrivals.check(); err != nil {
+		return nil, err
+	}
+	meanRate := arrivals.MeanRate()
+	if meanRate <= 0 {
+		return nil, fmt.Errorf("invalid MMPP mean rate %v", meanRate)
+	}
+	m := &MMPPModelStateDependent{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, servRate),
+		arrivals:               arrivals,
+		meanRate:               meanRate,
+	}
+	m.pArrival = make([]float64, K+1)
+	m.QueueModel.computeStatistics = m.computeStatistics
+	return m, nil
+}
+
+// Solve queueing model given the mean arrival rate lambda. The phase arrival rates are scaled
+// proportionally to have mean lambda, keeping the switching rates (the burst structure) unchanged.
+func (m *MMPPModelStateDependent) Solve(lambda float32, mu float32) {
+	m.MM1ModelStateDependent.Solve(lambda, mu)
+}
+
+// Evaluate performance measures of queueing model
+func (m *MMPPModelStateDependent) computeStatistics() {
+	if !m.isValid {
+		return
+	}
+	if m.lambda <= 0 {
+		m.isValid = false
+		return
+	}
+	if err := m.computeProbabilities(); err != nil {
+		m.isValid = false
+		return
+	}
+	m.rho = m.ComputeRho()
+	m.computeMeasures(m.lambda * (1 - float32(m.pArrival[m.K])))
+}
+
+// Compute the joint state probabilities by linear level reduction, and from them
+// the marginal (time-average) and arrival-epoch queue length distributions
+func (m *MMPPModelStateDependent) computeProbabilities() error {
+	numPhases := len(m.arrivals.Rates)
+	K := m.K
+
+	// phase arrival rates scaled to the mean rate lambda
+	scale := float64(m.lambda) / float64(m.meanRate)
+	lambdas := make([]float64, numPhases)
+	for j, r := range m.arrivals.Rates {
+		lambdas[j] = float64(r) * scale
+	}
+	gen := m.arrivals.generator()
+
+	// QBD blocks: up A0 = diag(lambda), down A2(n) = mu(n) I, local A1(n)
+	up := diagMatrix(lambdas)
+	down := func(n int) matrix {
+		return diagMatrix(repeat(float64(m.rateAt(n)), numPhases))
+	}
+	local := func(n int) matrix {
+		a := newMatrix(numPhases, numPhases)
+		for i := range numPhases {
+			copy(a[i], gen[i])
+			if n < K {
+				a[i][i] -= lambdas[i]
+			}
+			if n > 0 {
+				a[i][i] -= float64(m.rateAt(n))
+			}
+		}
+		return a
+	}
+
+	// backward reduction: pi[n] = pi[n-1] * R[n], R[n] = A0 * (-S[n])^-1
+	R := make([]matrix, K+1)
+	S := local(K)
+	for n := K; n >= 1; n-- {
+		inv, err := S.scale(-1).inverse()
+		if err != nil {
+			return err
+		}
+		R[n] = up.mul(inv)
+		S = local(n - 1).add(R[n].mul(down(n)))
+	}
+
+	// boundary level, then forward substitution with rescaling to avoid overflow
+	pi0, err := stationaryVector(S)
+	if err != nil {
+		return err
+	}
+	m.pi = make([][]float64, K+1)
+	m.pi[0] = pi0
+	for n := 1; n <= K; n++ {
+		m.pi[n] = vecMul(m.pi[n-1], R[n])
+		if sum(m.pi[n]) > rescaleThreshold {
+			for i := 0; i <= n; i++ {
+				for j := range m.pi[i] {
+					m.pi[i][j] /= rescaleThreshold
+				}
+			}
+		}
+	}
+
+	// normalize and compute marginals
+	var total float64
+	for n := 0; n <= K; n++ {
+		total += sum(m.pi[n])
+	}
+	m.sumP = 0
+	for n := 0; n <= K; n++ {
+		var pn, pa float64
+		for j := range m.pi[n] {
+			m.pi[n][j] /= total
+			pn += m.pi[n][j]
+			pa += lambdas[j] * m.pi[n][j]
+		}
+		m.p[n] = pn
+		m.pArrival[n] = pa / float64(m.lambda)
+		m.sumP += pn
+	}
+	return nil
+}
+
+// total service rate with n in system
+func (m *MMPPModelStateDependent) rateAt(n int) float32 {
+	if n <= 0 {
+		return 0
+	}
+	return m.servRate[min(n, len(m.servRate))-1]
+}
+
+// joint state probabilities pi[n][j] of n in system and arrival phase j
+func (m *MMPPModelStateDependent) GetJointProbabilities() [][]float64 {
+	return m.pi
+}
+
+// queue length distribution seen by arrivals
+func (m *MMPPModelStateDependent) GetArrivalProbabilities() []float64 {
+	return m.pArrival
+}
+
+func (m *MMPPModelStateDependent) String() string {
+	var b bytes.Buffer
+	b.WriteString("MMPPModelStateDependent: ")
+	fmt.Fprintf(&b, "phases=%d; ", len(m.arrivals.Rates))
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
+
+// threshold for rescaling unnormalized probabilities
+const rescaleThreshold = 1e100
+
+// check validity of arrival process
+func (a *MMPP) check() error {
+	numPhases := len(a.Rates)
+	if numPhases == 0 || len(a.Switch) != numPhases {
+		return fmt.Errorf("invalid MMPP dimensions %s", a)
+	}
+	var total float32
+	for i, r := range a.Rates {
+		if r < 0 || len(a.Switch[i]) != numPhases {
+			return fmt.Errorf("invalid MMPP %s", a)
+		}
+		total += r
+		for j, s := range a.Switch[i] {
+			if i != j && s < 0 {
+				return fmt.Errorf("invalid MMPP switching rate %s", a)
+			}
+		}
+	}
+	if total <= 0 {
+		return fmt.Errorf("invalid MMPP rates %s", a)
+	}
+	return nil
+}
+
+// infinitesimal generator of the modulating Markov chain
+func (a *MMPP) generator() matrix {
+	numPhases := len(a.Rates)
+	gen := newMatrix(numPhases, numPhases)
+	for i := range numPhases {
+		for j := range numPhases {
+			if i != j {
+				gen[i][j] = float64(a.Switch[i][j])
+				gen[i][i] -= float64(a.Switch[i][j])
+			}
+		}
+	}
+	return gen
+}
+
+// Compute the stationary phase probabilities of the modulating Markov chain
+func (a *MMPP) PhaseProbabilities() ([]float64, error) {
+	if len(a.Rates) == 1 {
+		return []float64{1}, nil
+	}
+	return stationaryVector(a.generator())
+}
+
+// Compute the mean arrival rate
+func (a *MMPP) MeanRate() float32 {
+	theta, err := a.PhaseProbabilities()
+	if err != nil {
+		return 0
+	}
+	var mean float64
+	for j, r := range a.Rates {
+		mean += theta[j] * float64(r)
+	}
+	return float32(mean)
+}
+
+func (a *MMPP) String() string {
+	return fmt.Sprintf("{rates=%v, switch=%v}", a.Rates, a.Switch)
+}
+
+func repeat(v float64, n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = v
+	}
+	return x
+}
+
+func sum(x []float64) float64 {
+	var s float64
+	for _, v := range x {
+		s += v
+	}
+	return s
+}
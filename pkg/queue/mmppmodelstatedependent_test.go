@@ -0,0 +1,82 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func testServRate() []float32 {
+	servRate := make([]float32, 8)
+	for n := 1; n <= 8; n++ {
+		servRate[n-1] = float32(n) / (1 + 0.5*float32(n))
+	}
+	return servRate
+}
+
+func TestMMPPWithEqualRatesIsPoisson(t *testing.T) {
+	servRate := testServRate()
+	arrivals := &MMPP{
+		Rates:  []float32{1, 1},
+		Switch: [][]float32{{0, 0.1}, {0.3, 0}},
+	}
+	mmpp, err := NewMMPPModelStateDependent(50, servRate, arrivals)
+	if err != nil {
+		t.Fatalf("NewMMPPModelStateDependent: %v", err)
+	}
+	poisson := NewMM1ModelStateDependent(50, servRate)
+	mmpp.Solve(1.5, 1)
+	poisson.Solve(1.5, 1)
+	if !mmpp.IsValid() {
+		t.Fatalf("invalid model %s", mmpp)
+	}
+	for _, c := range []struct {
+		name      string
+		got, want float32
+	}{
+		{"throughput", mmpp.GetThroughput(), poisson.GetThroughput()},
+		{"wait", mmpp.GetAvgWaitTime(), poisson.GetAvgWaitTime()},
+		{"inServ", mmpp.GetAvgNumInServers(), poisson.GetAvgNumInServers()},
+	} {
+		if math.Abs(float64(c.got-c.want)) > 1e-4*math.Max(1, float64(c.want)) {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestMMPPBurstinessIncreasesWaiting(t *testing.T) {
+	servRate := testServRate()
+	arrivals := &MMPP{
+		Rates:  []float32{4, 0.5},
+		Switch: [][]float32{{0, 0.05}, {0.05, 0}},
+	}
+	if mean := arrivals.MeanRate(); math.Abs(float64(mean)-2.25) > 1e-6 {
+		t.Fatalf("mean rate: got %v, want 2.25", mean)
+	}
+	mmpp, err := NewMMPPModelStateDependent(100, servRate, arrivals)
+	if err != nil {
+		t.Fatalf("NewMMPPModelStateDependent: %v", err)
+	}
+	poisson := NewMM1ModelStateDependent(100, servRate)
+	mmpp.Solve(1.5, 1)
+	poisson.Solve(1.5, 1)
+	if mmpp.GetAvgWaitTime() <= poisson.GetAvgWaitTime() {
+		t.Errorf("bursty wait %v not above Poisson wait %v", mmpp.GetAvgWaitTime(), poisson.GetAvgWaitTime())
+	}
+	if mmpp.GetWaitTimePercentile(0.99) <= poisson.GetWaitTimePercentile(0.99) {
+		t.Errorf("bursty P99 wait %v not above Poisson P99 wait %v",
+			mmpp.GetWaitTimePercentile(0.99), poisson.GetWaitTimePercentile(0.99))
+	}
+	var total float64
+	for _, pn := range mmpp.GetArrivalProbabilities() {
+		total += pn
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Errorf("arrival probabilities sum to %v", total)
+	}
+}
+
+func TestMMPPRejectsInvalid(t *testing.T) {
+	if _, err := NewMMPPModelStateDependent(10, testServRate(), &MMPP{Rates: []float32{1, 2}}); err == nil {
+		t.Error("expected error for missing switching rates, got nil")
+	}
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Scheduling discipline among priority classes sharing a queue
+type PriorityScheduling int
+
+const (
+	// a waiting request of higher priority is admitted to service first; requests in service are not interrupted
+	NonPreemptive PriorityScheduling = iota
+	// a higher-priority arrival evicts a lower-priority request from service, which later resumes where it stopped
+	PreemptiveResume
+)
+
+// Multi-priority model over the state-dependent birth-death chain. Classes are indexed by
+// priority, class 0 being the highest. All classes have the same service requirement, so the
+// total number in system is that of the FCFS chain at the total arrival rate, and priorities
+// only redistribute the waiting among classes:
+//
+//   - non-preemptive: the waiting times follow Cobham's formula for a multi-server queue,
+//     W_k = Pwait / mu / ((1 - sigma_{k-1}) * (1 - sigma_k)), with sigma_k the load of classes
+//     0, ..., k on the full-batch service rate mu and Pwait the probability that an arrival waits
+//     in the FCFS chain; the waiting times are scaled to conserve the FCFS mean waiting time.
+//   - preemptive-resume: classes 0, ..., k do not see lower classes, hence they form the FCFS
+//     chain at their cumulative rate; the measures of class k are the differences between the
+//     cumulative chains of classes 0, ..., k and 0, ..., k-1 (Little's law per class).
+type PriorityModel struct {
+	*MM1ModelStateDependent                    // FCFS chain of all classes
+	scheduling              PriorityScheduling // scheduling discipline
+	lambdas                 []float32          // arrival rate of each class
+
+	classThroughput []float32 // throughput of each class
+	classWaitTime   []float32 // average waiting time of each class
+	classServTime   []float32 // average service time of each class (including preemptions)
+}
+
+func NewPriorityModel(K int, servRate []float32, scheduling PriorityScheduling) *PriorityModel {
+	return &PriorityModel{
+		MM1ModelStateDependent: NewMM1ModelStateDependent(K, servRate),
+		scheduling:             scheduling,
+	}
+}
+
+// Solve queueing model given the arrival rate of each class, in priority order
+func (m *PriorityModel) SolveClasses(lambdas []float32) error {
+	if len(lambdas) == 0 {
+		return fmt.Errorf("no priority classes")
+	}
+	var lambda float32
+	for k, l := range lambdas {
+		if l < 0 {
+			return fmt.Errorf("invalid arrival rate %v of class %d", l, k)
+		}
+		lambda += l
+	}
+	m.lambdas = lambdas
+	numClasses := len(lambdas)
+	m.classThroughput = make([]float32, numClasses)
+	m.classWaitTime = make([]float32, numClasses)
+	m.classServTime = make([]float32, numClasses)
+
+	m.Solve(lambda, 1)
+	if !m.IsValid() {
+		return fmt.Errorf("invalid model %s", m.MM1ModelStateDependent)
+	}
+	if m.scheduling == PreemptiveResume {
+		return m.solvePreemptive()
+	}
+	m.solveNonPreemptive()
+	return nil
+}
+
+// Cobham's waiting times, scaled to the FCFS mean waiting time
+func (m *PriorityModel) solveNonPreemptive() {
+	numClasses := len(m.lambdas)
+	admitted := 1 - float32(m.p[m.K])
+	muFull := m.servRate[len(m.servRate)-1]
+	var pWait float64
+	for n := len(m.servRate); n < m.K; n++ {
+		pWait += m.p[n]
+	}
+	pWait /= float64(admitted)
+	for k, l := range m.lambdas {
+		m.classThroughput[k] = l * admitted
+		m.classServTime[k] = m.avgServTime
+	}
+
+	// Cobham's formula for the classes with load below the full-batch capacity
+	weight := make([]float64, numClasses)
+	sigmaPrev := 0.0
+	numStable := 0
+	for k := range numClasses {
+		sigma := sigmaPrev + float64(m.classThroughput[k]/muFull)
+		if sigma >= 1 {
+			break
+		}
+		weight[k] = 1 / ((1 - sigmaPrev) * (1 - sigma))
+		sigmaPrev = sigma
+		numStable++
+	}
+
+	totalWait := float64(m.throughput) * float64(m.avgWaitTime)
+
+	if numStable == numClasses {
+		var weighted float64
+		for k := range numClasses {
+			weighted += float64(m.classThroughput[k]) * weight[k]
+		}
+		for k := range numClasses {
+			if weighted > 0 {
+				m.classWaitTime[k] = float32(totalWait * weight[k] / weighted)
+			}
+		}
+		return
+	}
+
+	// classes beyond capacity share the waiting not accounted for by the stable classes
+	var stableWait, unstableRate float64
+	for k := range numClasses {
+		if k < numStable {
+			m.classWaitTime[k] = float32(pWait / float64(muFull) * weight[k])
+			stableWait += float64(m.classThroughput[k] * m.classWaitTime[k])
+		} else {
+			unstableRate += float64(m.classThroughput[k])
+		}
+	}
+	for k := numStable; k < numClasses; k++ {
+		m.classWaitTime[k] = float32(max(totalWait-stableWait, 0) / unstableRate)
+	}
+}
+
+// differences between the FCFS chains of the cumulative classes
+func (m *PriorityModel) solvePreemptive() error {
+	chain := NewMM1ModelStateDependent(m.K, m.servRate)
+	var cumRate, prevThroughput, prevNumInSystem, prevQueueLength float32
+	for k, l := range m.lambdas {
+		cumRate += l
+		chain.Solve(cumRate, 1)
+		if !chain.IsValid() {
+			return fmt.Errorf("invalid model of classes 0-%d %s", k, chain)
+		}
+		throughput := chain.GetThroughput()
+		numInSystem := chain.GetAvgNumInSystem()
+		queueLength := chain.GetAvgQueueLength()
+		m.classThroughput[k] = max(throughput-prevThroughput, 0)
+		if m.classThroughput[k] > 0 {
+			m.classWaitTime[k] = max(queueLength-prevQueueLength, 0) / m.classThroughput[k]
+			m.classServTime[k] = max(numInSystem-queueLength-(prevNumInSystem-prevQueueLength), 0) / m.classThroughput[k]
+		}
+		prevThroughput, prevNumInSystem, prevQueueLength = throughput, numInSystem, queueLength
+	}
+	return nil
+}
+
+func (m *PriorityModel) GetScheduling() PriorityScheduling {
+	return m.scheduling
+}
+
+func (m *PriorityModel) GetNumClasses() int {
+	return len(m.lambdas)
+}
+
+func (m *PriorityModel) GetClassThroughput(k int) float32 {
+	return m.classThroughput[k]
+}
+
+func (m *PriorityModel) GetClassWaitTime(k int) float32 {
+	return m.classWaitTime[k]
+}
+
+func (m *PriorityModel) GetClassServTime(k int) float32 {
+	return m.classServTime[k]
+}
+
+func (m *PriorityModel) GetClassRespTime(k int) float32 {
+	return m.classWaitTime[k] + m.classServTime[k]
+}
+
+func (m *PriorityModel) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PriorityModel: scheduling=%s; ", m.scheduling)
+	for k := range m.lambdas {
+		fmt.Fprintf(&b, "class%d={lambda=%v, tput=%v, wait=%v, serv=%v}; ",
+			k, m.lambdas[k], m.classThroughput[k], m.classWaitTime[k], m.classServTime[k])
+	}
+	b.WriteString(m.MM1ModelStateDependent.String())
+	return b.String()
+}
+
+func (s PriorityScheduling) String() string {
+	switch s {
+	case NonPreemptive:
+		return "non-preemptive"
+	case PreemptiveResume:
+		return "preemptive-resume"
+	default:
+		return fmt.Sprintf("PriorityScheduling(%d)", int(s))
+	}
+}
@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriorityConservesFCFSWaiting(t *testing.T) {
+	servRate := testServRate()
+	lambdas := []float32{0.3, 0.4, 0.3}
+	for _, sched := range []PriorityScheduling{NonPreemptive, PreemptiveResume} {
+		m := NewPriorityModel(100, servRate, sched)
+		if err := m.SolveClasses(lambdas); err != nil {
+			t.Fatalf("%s: %v", sched, err)
+		}
+		var throughput, numWaiting float64
+		for k := range lambdas {
+			throughput += float64(m.GetClassThroughput(k))
+			numWaiting += float64(m.GetClassThroughput(k) * m.GetClassWaitTime(k))
+		}
+		if math.Abs(throughput-float64(m.GetThroughput())) > 1e-4 {
+			t.Errorf("%s: class throughputs sum to %v, want %v", sched, throughput, m.GetThroughput())
+		}
+		if math.Abs(numWaiting-float64(m.GetAvgQueueLength())) > 1e-3*float64(m.GetAvgQueueLength()) {
+			t.Errorf("%s: class queue lengths sum to %v, want %v", sched, numWaiting, m.GetAvgQueueLength())
+		}
+		for k := 1; k < len(lambdas); k++ {
+			if m.GetClassWaitTime(k) < m.GetClassWaitTime(k-1) {
+				t.Errorf("%s: class %d waits %v, less than class %d %v",
+					sched, k, m.GetClassWaitTime(k), k-1, m.GetClassWaitTime(k-1))
+			}
+		}
+	}
+}
+
+func TestPreemptiveTopClassSeesOwnChain(t *testing.T) {
+	servRate := testServRate()
+	m := NewPriorityModel(100, servRate, PreemptiveResume)
+	if err := m.SolveClasses([]float32{0.4, 0.6}); err != nil {
+		t.Fatal(err)
+	}
+	alone := NewMM1ModelStateDependent(100, servRate)
+	alone.Solve(0.4, 1)
+	if math.Abs(float64(m.GetClassRespTime(0)-alone.GetAvgRespTime())) > 1e-3*float64(alone.GetAvgRespTime()) {
+		t.Errorf("top class response time %v, want %v", m.GetClassRespTime(0), alone.GetAvgRespTime())
+	}
+	if m.GetClassServTime(1) <= m.GetClassServTime(0) {
+		t.Errorf("preempted class service time %v not above top class %v", m.GetClassServTime(1), m.GetClassServTime(0))
+	}
+}
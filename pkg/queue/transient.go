@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"fmt"
+	"math"
+)
+
+// truncation error of the Poisson series in uniformization
+const uniformizationTolerance = 1e-12
+
+// performance measures of a (possibly transient) queue length distribution
+type StateMeasures struct {
+	AvgNumInSystem  float32 // average number in system
+	AvgNumInServers float32 // average number in service
+	AvgQueueLength  float32 // average number waiting
+	Throughput      float32 // departure rate
+	DropRate        float32 // rate of arrivals blocked by a full system
+	AvgWaitTime     float32 // expected waiting time of a request admitted at this instant
+}
+
+// Compute the transient queue length distributions of the birth-death chain by uniformization.
+// Starting from distribution p0 over 0..K at time 0, with arrival rate lambda, returns the
+// distribution at each of the given non-decreasing times.
+func (m *MM1ModelStateDependent) SolveTransient(lambda float32, p0 []float64, times []float32) ([][]float64, error) {
+	if lambda < 0 {
+		return nil, fmt.Errorf("invalid arrival rate %v", lambda)
+	}
+	if len(p0) != m.K+1 {
+		return nil, fmt.Errorf("invalid initial distribution length %d, want %d", len(p0), m.K+1)
+	}
+
+	// uniformization rate
+	var maxRate float32
+	for _, r := range m.servRate {
+		maxRate = max(maxRate, r)
+	}
+	unif := float64(lambda + maxRate)
+
+	x := make([]float64, len(p0))
+	copy(x, p0)
+	result := make([][]float64, len(times))
+	var t float32
+	for i, ti := range times {
+		if ti < t {
+			return nil, fmt.Errorf("times not in increasing order at %v", ti)
+		}
+		x = m.uniformize(lambda, unif, x, float64(ti-t))
+		t = ti
+		result[i] = make([]float64, len(x))
+		copy(result[i], x)
+	}
+	return result, nil
+}
+
+// advance distribution x by time dt: sum_k Poisson(k; unif*dt) * x * P^k, with P = I + Q/unif
+func (m *MM1ModelStateDependent) uniformize(lambda float32, unif float64, x []float64, dt float64) []float64 {
+	if dt <= 0 || unif <= 0 {
+		return x
+	}
+	mean := unif * dt
+	maxTerms := int(mean+10*math.Sqrt(mean)) + 20
+
+	y := make([]float64, len(x))
+	next := make([]float64, len(x))
+	var weights float64
+	for k := 0; k <= maxTerms && weights < 1-uniformizationTolerance; k++ {
+		w := poissonPMF(k, mean)
+		weights += w
+		for n := range x {
+			y[n] += w * x[n]
+		}
+		m.transitionStep(lambda, unif, x, next)
+		x, next = next, x
+	}
+	// renormalize the truncated series
+	if weights > 0 {
+		for n := range y {
+			y[n] /= weights
+		}
+	}
+	return y
+}
+
+// one step of the uniformized chain: next = x * (I + Q/unif)
+func (m *MM1ModelStateDependent) transitionStep(lambda float32, unif float64, x, next []float64) {
+	K := m.K
+	up := float64(lambda) / unif
+	for n := 0; n <= K; n++ {
+		out := 0.0
+		if n < K {
+			out += up
+		}
+		down := float64(m.stateServRate(n)) / unif
+		out += down
+		next[n] = x[n] * (1 - out)
+		if n > 0 {
+			next[n] += x[n-1] * up
+		}
+		if n < K {
+			next[n] += x[n+1] * float64(m.stateServRate(n+1)) / unif
+		}
+	}
+}
+
+// total service rate with n in system
+func (m *MM1ModelStateDependent) stateServRate(n int) float32 {
+	if n <= 0 {
+		return 0
+	}
+	return m.servRate[min(n, len(m.servRate))-1]
+}
+
+// Compute performance measures of a queue length distribution p over 0..K, given arrival rate lambda
+func (m *MM1ModelStateDependent) Measures(lambda float32, p []float64) *StateMeasures {
+	num := len(m.servRate)
+	satRate := float64(m.servRate[num-1])
+	var inSystem, inServers, queueLength, throughput, waitNum float64
+	for n, pn := range p {
+		inSystem += float64(n) * pn
+		inServers += float64(min(n, num)) * pn
+		queueLength += float64(max(n-num, 0)) * pn
+		throughput += float64(m.stateServRate(n)) * pn
+		if n >= num && n < m.K {
+			waitNum += float64(n-num+1) * pn
+		}
+	}
+	admitted := 1 - p[m.K]
+	var avgWaitTime float64
+	if admitted > 0 && satRate > 0 {
+		avgWaitTime = waitNum / satRate / admitted
+	}
+	return &StateMeasures{
+		AvgNumInSystem:  float32(inSystem),
+		AvgNumInServers: float32(inServers),
+		AvgQueueLength:  float32(queueLength),
+		Throughput:      float32(throughput),
+		DropRate:        lambda * float32(p[m.K]),
+		AvgWaitTime:     float32(avgWaitTime),
+	}
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTransientConvergesToSteadyState(t *testing.T) {
+	servRate := testServRate()
+	m := NewMM1ModelStateDependent(60, servRate)
+	lambda := float32(1)
+	m.Solve(lambda, 1)
+
+	// start from a congested system: 50 in system
+	p0 := make([]float64, 61)
+	p0[50] = 1
+	ps, err := m.SolveTransient(lambda, p0, []float32{0, 5, 2000})
+	if err != nil {
+		t.Fatalf("SolveTransient: %v", err)
+	}
+	if got := m.Measures(lambda, ps[0]).AvgNumInSystem; got != 50 {
+		t.Errorf("at t=0: got %v in system, want 50", got)
+	}
+	if early := m.Measures(lambda, ps[1]).AvgNumInSystem; early >= 50 || early <= m.GetAvgNumInSystem() {
+		t.Errorf("at t=5: got %v in system, want between steady state %v and 50", early, m.GetAvgNumInSystem())
+	}
+	final := m.Measures(lambda, ps[2])
+	if math.Abs(float64(final.AvgNumInSystem-m.GetAvgNumInSystem())) > 1e-3 {
+		t.Errorf("steady state: got %v in system, want %v", final.AvgNumInSystem, m.GetAvgNumInSystem())
+	}
+	if math.Abs(float64(final.Throughput-m.GetThroughput())) > 1e-4 {
+		t.Errorf("steady state: got throughput %v, want %v", final.Throughput, m.GetThroughput())
+	}
+}
+
+func TestTransientRejectsDecreasingTimes(t *testing.T) {
+	m := NewMM1ModelStateDependent(10, testServRate())
+	p0 := make([]float64, 11)
+	p0[0] = 1
+	if _, err := m.SolveTransient(1, p0, []float32{2, 1}); err == nil {
+		t.Error("expected error for decreasing times, got nil")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+	"github.com/llm-inferno/queue-analysis/pkg/calibration"
 )
 
 // problem input data
@@ -17,22 +18,30 @@ type ProblemData struct {
 	Beta            float32 `json:"beta"`            // slope for compute time (msec/token)
 	Gamma           float32 `json:"gamma"`           // slope for memory access time (msec/token*2)
 	MaxQueueSize    int     `json:"maxQueueSize"`    // maximum queue size
+	NumReplicas     int     `json:"numReplicas"`     // number of replicas sharing the queue (0 => 1)
 	TargetTTFT      float32 `json:"targetTTFT"`      // target time to first token (msec)
 	TargetITL       float32 `json:"targetITL"`       // target inter-token interval (msec)
+	TargetTPS       float32 `json:"targetTPS"`       // target aggregate output token throughput (tokens/sec)
+	TargetUserTPS   float32 `json:"targetUserTPS"`   // target per-user decode speed (tokens/sec)
+	NumUsers        int     `json:"numUsers"`        // number of closed-loop users
+	ThinkTime       float32 `json:"thinkTime"`       // average user think time between requests (msec)
+	Strategy        string  `json:"strategy"`        // concurrency search strategy (empty => formula_guided)
 }
 
 // analysis solution output data
 type AnalysisData struct {
-	OfferedRPS    float32 `json:"offeredRPS"`    // offered arrival rate (requests/sec)
-	Throughput    float32 `json:"throughput"`    // effective throughput (requests/sec)
-	AvgRespTime   float32 `json:"avgRespTime"`   // average response time (sec)
-	AvgWaitTime   float32 `json:"avgWaitTime"`   // average queueing time (sec)
-	AvgNumInServ  float32 `json:"avgNumInServ"`  // average number of requests in system
-	AvgTTFT       float32 `json:"avgTTFT"`       // average time to first token (msec)
-	AvgITL        float32 `json:"avgITL"`        // average inter-token latency (msec)
-	MaxRPS        float32 `json:"maxRPS"`        // maximum throughput (requests/sec)
-	RPSTargetTTFT float32 `json:"RPSTargetTTFT"` // throughput to achieve target TTFT (requests/sec)
-	RPSTargetITL  float32 `json:"RPSTargetITL"`  // throughput to achieve target ITL (requests/sec)
+	OfferedRPS       float32 `json:"offeredRPS"`       // offered arrival rate (requests/sec)
+	Throughput       float32 `json:"throughput"`       // effective throughput (requests/sec)
+	AvgRespTime      float32 `json:"avgRespTime"`      // average response time (sec)
+	AvgWaitTime      float32 `json:"avgWaitTime"`      // average queueing time (sec)
+	AvgNumInServ     float32 `json:"avgNumInServ"`     // average number of requests in system
+	AvgTTFT          float32 `json:"avgTTFT"`          // average time to first token (msec)
+	AvgITL           float32 `json:"avgITL"`           // average inter-token latency (msec)
+	MaxRPS           float32 `json:"maxRPS"`           // maximum throughput (requests/sec)
+	RPSTargetTTFT    float32 `json:"RPSTargetTTFT"`    // throughput to achieve target TTFT (requests/sec)
+	RPSTargetITL     float32 `json:"RPSTargetITL"`     // throughput to achieve target ITL (requests/sec)
+	RPSTargetTPS     float32 `json:"RPSTargetTPS"`     // min throughput to achieve target TPS (requests/sec)
+	RPSTargetUserTPS float32 `json:"RPSTargetUserTPS"` // throughput to achieve target per-user TPS (requests/sec)
 }
 
 // optimal-concurrency output data
@@ -48,9 +57,27 @@ type OptimizeData struct {
 	MITL         int     `json:"M_ITL"`        // closed-form ITL-binding bracket
 	MTPF         int     `json:"M_TPF"`        // closed-form TTFT-prefill-binding bracket
 	Calls        int     `json:"oracleCalls"`  // feasible oracle calls
+	Strategy     string  `json:"strategy"`     // search strategy
 	Feasible     bool    `json:"feasible"`
 }
 
+// capacity plan output data
+type CapacityData struct {
+	NumReplicas      int     `json:"numReplicas"`      // min number of replicas meeting the targets
+	MaxBatchSize     int     `json:"maxBatchSize"`     // concurrency (max batch size) per replica
+	RPSPerReplica    float32 `json:"RPSPerReplica"`    // load per replica (requests/sec)
+	MaxRPSPerReplica float32 `json:"maxRPSPerReplica"` // max rate per replica meeting the targets (requests/sec)
+	Headroom         float32 `json:"headroom"`         // fraction of the capacity of the replicas left unused
+	TTFTSlack        float32 `json:"TTFTSlack"`        // relative slack of the TTFT target per replica
+	ITLSlack         float32 `json:"ITLSlack"`         // relative slack of the ITL target per replica
+	UserTPSSlack     float32 `json:"userTPSSlack"`     // relative slack of the per-user TPS target per replica
+	AvgRespTime      float32 `json:"avgRespTime"`      // average response time per replica (msec)
+	AvgWaitTime      float32 `json:"avgWaitTime"`      // average queueing time per replica (msec)
+	AvgNumInServ     float32 `json:"avgNumInServ"`     // average number of requests in service per replica
+	AvgTTFT          float32 `json:"avgTTFT"`          // average time to first token (msec)
+	AvgITL           float32 `json:"avgITL"`           // average inter-token latency (msec)
+}
+
 // REST server for llm inference server analysis
 type Analyzer struct {
 	router *gin.Engine
@@ -64,6 +91,9 @@ func NewAnalyzer() *Analyzer {
 	analyzer.router.POST("/solve", solve)
 	analyzer.router.POST("/target", target)
 	analyzer.router.POST("/optimize", optimize)
+	analyzer.router.POST("/closed", closed)
+	analyzer.router.POST("/calibrate", calibrate)
+	analyzer.router.POST("/capacity", capacity)
 	return analyzer
 }
 
@@ -82,8 +112,13 @@ func IsValid(pd *ProblemData) bool {
 		pd.Beta >= 0 &&
 		pd.Gamma >= 0 &&
 		pd.MaxQueueSize >= 0 &&
+		pd.NumReplicas >= 0 &&
 		pd.TargetTTFT >= 0 &&
-		pd.TargetITL >= 0
+		pd.TargetITL >= 0 &&
+		pd.TargetTPS >= 0 &&
+		pd.TargetUserTPS >= 0 &&
+		pd.NumUsers >= 0 &&
+		pd.ThinkTime >= 0
 }
 
 /*
@@ -153,9 +188,10 @@ func target(c *gin.Context) {
 
 	// size queue for given targets
 	targetPerf := &analyzer.TargetPerf{
-		TargetTTFT: pd.TargetTTFT,
-		TargetITL:  pd.TargetITL,
-		TargetTPS:  0, // not used in this service
+		TargetTTFT:    pd.TargetTTFT,
+		TargetITL:     pd.TargetITL,
+		TargetTPS:     pd.TargetTPS,
+		TargetUserTPS: pd.TargetUserTPS,
 	}
 	targetRate, metrics, targetPerf, err := queueAnalyzer.Size(targetPerf)
 	if err != nil {
@@ -165,16 +201,18 @@ func target(c *gin.Context) {
 
 	// return solution
 	analysisData := &AnalysisData{
-		OfferedRPS:    metrics.OfferedRate,
-		Throughput:    metrics.Throughput,
-		AvgRespTime:   metrics.AvgRespTime,
-		AvgWaitTime:   metrics.AvgWaitTime,
-		AvgNumInServ:  metrics.AvgNumInServ,
-		AvgTTFT:       metrics.AvgTTFT,
-		AvgITL:        metrics.AvgTokenTime,
-		MaxRPS:        metrics.MaxRate,
-		RPSTargetTTFT: targetRate.RateTargetTTFT,
-		RPSTargetITL:  targetRate.RateTargetITL,
+		OfferedRPS:       metrics.OfferedRate,
+		Throughput:       metrics.Throughput,
+		AvgRespTime:      metrics.AvgRespTime,
+		AvgWaitTime:      metrics.AvgWaitTime,
+		AvgNumInServ:     metrics.AvgNumInServ,
+		AvgTTFT:          metrics.AvgTTFT,
+		AvgITL:           metrics.AvgTokenTime,
+		MaxRPS:           metrics.MaxRate,
+		RPSTargetTTFT:    targetRate.RateTargetTTFT,
+		RPSTargetITL:     targetRate.RateTargetITL,
+		RPSTargetTPS:     targetRate.RateTargetTPS,
+		RPSTargetUserTPS: targetRate.RateTargetUserTPS,
 	}
 	c.IndentedJSON(http.StatusOK, analysisData)
 }
@@ -199,11 +237,14 @@ func optimize(c *gin.Context) {
 	}
 
 	targetPerf := &analyzer.TargetPerf{
-		TargetTTFT: pd.TargetTTFT,
-		TargetITL:  pd.TargetITL,
-		TargetTPS:  0, // not used in this service
+		TargetTTFT:    pd.TargetTTFT,
+		TargetITL:     pd.TargetITL,
+		TargetTPS:     pd.TargetTPS,
+		TargetUserTPS: pd.TargetUserTPS,
 	}
-	result, err := queueAnalyzer.OptimalConcurrency(targetPerf)
+	optimizer := queueAnalyzer.ConcurrencyOptimizer(targetPerf)
+	optimizer.Strategy = pd.Strategy
+	result, err := optimizer.Find()
 	if err != nil {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "OptimalConcurrency() failed: " + err.Error()})
 		return
@@ -216,6 +257,7 @@ func optimize(c *gin.Context) {
 		MTPF:        result.MTPF,
 		Calls:       result.Calls,
 		Feasible:    result.Feasible,
+		Strategy:    result.Strategy,
 	}
 	if result.Metrics != nil {
 		data.AvgRespTime = result.Metrics.AvgRespTime
@@ -228,12 +270,126 @@ func optimize(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, data)
 }
 
+// analyze queue under a closed population of users with think time
+func closed(c *gin.Context) {
+	// get problem data
+	pd := ProblemData{}
+	if err := c.BindJSON(&pd); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "binding error: " + err.Error()})
+		return
+	}
+	if !IsValid(&pd) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "data error: invalid input data"})
+		return
+	}
+
+	// create queue analyzer
+	queueAnalyzer := CreateQueueAnalyzer(&pd)
+	if queueAnalyzer == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "NewLLMQueueAnalyzer() failed"})
+		return
+	}
+
+	// analyze queue under the closed population
+	metrics, err := queueAnalyzer.AnalyzeClosed(pd.NumUsers, pd.ThinkTime)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "AnalyzeClosed() failed: " + err.Error()})
+		return
+	}
+
+	// return solution
+	analysisData := &AnalysisData{
+		OfferedRPS:   metrics.OfferedRate,
+		Throughput:   metrics.Throughput,
+		AvgRespTime:  metrics.AvgRespTime,
+		AvgWaitTime:  metrics.AvgWaitTime,
+		AvgNumInServ: metrics.AvgNumInServ,
+		AvgTTFT:      metrics.AvgTTFT,
+		AvgITL:       metrics.AvgTokenTime,
+		MaxRPS:       metrics.MaxRate,
+	}
+	c.IndentedJSON(http.StatusOK, analysisData)
+}
+
+// fit service parameters (alpha, beta, gamma) to benchmark observations
+func calibrate(c *gin.Context) {
+	// get calibration problem
+	problem := calibration.Problem{}
+	if err := c.BindJSON(&problem); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "binding error: " + err.Error()})
+		return
+	}
+
+	// fit parameters
+	result, err := calibration.Calibrate(problem.Observations, &problem.Config)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "Calibrate() failed: " + err.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, result)
+}
+
+// find the min number of replicas, and their concurrency, serving a request rate within targets
+func capacity(c *gin.Context) {
+	pd := ProblemData{}
+	if err := c.BindJSON(&pd); err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "binding error: " + err.Error()})
+		return
+	}
+	if !IsValid(&pd) {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "data error: invalid input data"})
+		return
+	}
+
+	// the number of replicas is planned, not given
+	if pd.NumReplicas > 1 {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "data error: numReplicas is not supported"})
+		return
+	}
+
+	// maxBatchSize is interpreted as the upper bound of the concurrency per replica.
+	queueAnalyzer := CreateQueueAnalyzer(&pd)
+	if queueAnalyzer == nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "NewLLMQueueAnalyzer() failed"})
+		return
+	}
+
+	targetPerf := &analyzer.TargetPerf{
+		TargetTTFT:    pd.TargetTTFT,
+		TargetITL:     pd.TargetITL,
+		TargetUserTPS: pd.TargetUserTPS,
+	}
+	plan, err := queueAnalyzer.PlanCapacity(pd.RPS, targetPerf)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "PlanCapacity() failed: " + err.Error()})
+		return
+	}
+
+	data := &CapacityData{
+		NumReplicas:      plan.NumReplicas,
+		MaxBatchSize:     plan.MaxBatchSize,
+		RPSPerReplica:    plan.RatePerReplica,
+		MaxRPSPerReplica: plan.MaxRatePerReplica,
+		Headroom:         plan.Headroom,
+		TTFTSlack:        plan.Slack.TTFT,
+		ITLSlack:         plan.Slack.ITL,
+		UserTPSSlack:     plan.Slack.UserTPS,
+		AvgRespTime:      plan.Metrics.AvgRespTime,
+		AvgWaitTime:      plan.Metrics.AvgWaitTime,
+		AvgNumInServ:     plan.Metrics.AvgNumInServ,
+		AvgTTFT:          plan.Metrics.AvgTTFT,
+		AvgITL:           plan.Metrics.AvgTokenTime,
+	}
+	c.IndentedJSON(http.StatusOK, data)
+}
+
 // create queue analyzer from problem data
 func CreateQueueAnalyzer(pd *ProblemData) *analyzer.LLMQueueAnalyzer {
 	// create queue analyzer
 	config := &analyzer.Configuration{
 		MaxBatchSize: pd.MaxBatchSize,
 		MaxQueueSize: pd.MaxQueueSize,
+		NumReplicas:  pd.NumReplicas,
 		ServiceParms: &analyzer.ServiceParms{
 			Alpha: pd.Alpha,
 			Beta:  pd.Beta,
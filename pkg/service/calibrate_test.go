@@ -0,0 +1,47 @@
+package service
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+	"github.com/llm-inferno/queue-analysis/pkg/calibration"
+)
+
+func TestCalibrateEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+
+	// ITL observations of the baseline parameters, without chunking (nc=1)
+	sp := &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333}
+	problem := calibration.Problem{}
+	for _, size := range [][2]float32{{128, 256}, {1024, 512}} {
+		r := &analyzer.RequestSize{AvgInputTokens: size[0], AvgOutputTokens: size[1]}
+		for _, B := range []float32{1, 8, 32} {
+			problem.Observations = append(problem.Observations, &calibration.Observation{
+				BatchSize: B, InputTokens: size[0], OutputTokens: size[1],
+				ITL: sp.ChunkedDecodeTime(r, B, 1),
+			})
+		}
+	}
+	w := postJSON(t, a, "/calibrate", problem)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var out calibration.Result
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if math.Abs(float64(out.Alpha.Value-sp.Alpha)) > 1e-2 || out.NumMeasurements != 6 {
+		t.Errorf("got %s, want alpha %v from 6 measurements", &out, sp.Alpha)
+	}
+
+	// too few observations
+	problem.Observations = problem.Observations[:2]
+	if w := postJSON(t, a, "/calibrate", problem); w.Code != http.StatusBadRequest {
+		t.Errorf("status got %d, want 400", w.Code)
+	}
+}
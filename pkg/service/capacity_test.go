@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCapacityEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+
+	pd := ProblemData{
+		RPS:          40,
+		MaxBatchSize: 256, MaxQueueSize: 128,
+		AvgInputTokens: 256, AvgOutputTokens: 1024,
+		Alpha: 8, Beta: 0.033, Gamma: 0.000333,
+		TargetTTFT: 60, TargetITL: 20,
+	}
+	w := postJSON(t, a, "/capacity", pd)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var out CapacityData
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if out.NumReplicas < 2 || out.MaxBatchSize <= 0 || out.MaxBatchSize > pd.MaxBatchSize {
+		t.Errorf("plan: %+v", out)
+	}
+	if float32(out.NumReplicas)*out.MaxRPSPerReplica < pd.RPS || out.Headroom < 0 {
+		t.Errorf("replicas do not cover the load: %+v", out)
+	}
+
+	// the number of replicas is planned
+	pd.NumReplicas = 4
+	if w := postJSON(t, a, "/capacity", pd); w.Code != http.StatusBadRequest {
+		t.Errorf("numReplicas: status got %d, want 400", w.Code)
+	}
+
+	// no load to plan for
+	pd.NumReplicas = 0
+	pd.RPS = 0
+	if w := postJSON(t, a, "/capacity", pd); w.Code != http.StatusBadRequest {
+		t.Errorf("status got %d, want 400", w.Code)
+	}
+}
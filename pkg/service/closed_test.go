@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClosedEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+
+	pd := ProblemData{
+		MaxBatchSize: 64, MaxQueueSize: 128,
+		AvgInputTokens: 256, AvgOutputTokens: 1024,
+		Alpha: 8, Beta: 0.033, Gamma: 0.000333,
+		NumUsers: 200, ThinkTime: 5000,
+	}
+	w := postJSON(t, a, "/closed", pd)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var out AnalysisData
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if out.Throughput <= 0 || out.Throughput > out.MaxRPS*1.01 {
+		t.Errorf("throughput %v not in (0, maxRPS=%v]", out.Throughput, out.MaxRPS)
+	}
+	if out.AvgTTFT <= 0 || out.AvgITL <= 0 {
+		t.Errorf("TTFT %v and ITL %v should be positive", out.AvgTTFT, out.AvgITL)
+	}
+
+	// no users
+	pd.NumUsers = 0
+	if w := postJSON(t, a, "/closed", pd); w.Code != http.StatusBadRequest {
+		t.Errorf("status got %d, want 400", w.Code)
+	}
+}
@@ -61,3 +61,68 @@ func TestOptimizeEndpointRejectsInvalid(t *testing.T) {
 		t.Errorf("status got %d, want 400", w.Code)
 	}
 }
+
+func TestOptimizeEndpointStrategy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+	pd := ProblemData{
+		MaxBatchSize: 256, MaxQueueSize: 128,
+		AvgInputTokens: 256, AvgOutputTokens: 1024,
+		Alpha: 8, Beta: 0.033, Gamma: 0.000333,
+		TargetTTFT: 60, TargetITL: 20,
+		Strategy: "naive_max",
+	}
+	w := postJSON(t, a, "/optimize", pd)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var out OptimizeData
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if out.Strategy != "naive_max" || out.Concurrency != 256 || out.Calls != 1 {
+		t.Errorf("got strategy %q, concurrency %d, calls %d", out.Strategy, out.Concurrency, out.Calls)
+	}
+
+	pd.Strategy = "no_such_strategy"
+	if w = postJSON(t, a, "/optimize", pd); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown strategy: status got %d, want 400", w.Code)
+	}
+}
+
+func TestOptimizeEndpointTPSTargets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+	pd := ProblemData{
+		MaxBatchSize: 256, MaxQueueSize: 128,
+		AvgInputTokens: 256, AvgOutputTokens: 1024,
+		Alpha: 8, Beta: 0.033, Gamma: 0.000333,
+		TargetTTFT: 60, TargetITL: 20,
+	}
+	optimize := func() OptimizeData {
+		t.Helper()
+		w := postJSON(t, a, "/optimize", pd)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+		}
+		var out OptimizeData
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		return out
+	}
+	base := optimize()
+
+	// a per-user speed of 1000/16 tokens/sec binds before the 20 msec ITL target
+	pd.TargetUserTPS = 62.5
+	if out := optimize(); !out.Feasible || out.AvgITL > 16.001 || out.MITL >= base.MITL {
+		t.Errorf("user TPS target: got %+v, without it M_ITL=%d", out, base.MITL)
+	}
+
+	// an aggregate TPS beyond the throughput meeting the latency targets at any concurrency
+	pd.TargetUserTPS = 0
+	pd.TargetTPS = 1e6
+	if out := optimize(); out.Feasible {
+		t.Errorf("unreachable TPS target: got %+v", out)
+	}
+}
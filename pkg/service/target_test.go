@@ -0,0 +1,41 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTargetEndpointTPS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := NewAnalyzer()
+
+	pd := ProblemData{
+		MaxBatchSize: 64, MaxQueueSize: 128,
+		AvgInputTokens: 256, AvgOutputTokens: 1024,
+		Alpha: 8, Beta: 0.033, Gamma: 0.000333,
+		TargetITL: 20, TargetTPS: 1000, TargetUserTPS: 60,
+	}
+	w := postJSON(t, a, "/target", pd)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status got %d, want 200; body=%s", w.Code, w.Body.String())
+	}
+	var out AnalysisData
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("unmarshal response: %v", err)
+	}
+	if out.RPSTargetTPS <= 0 || out.RPSTargetTPS > out.Throughput {
+		t.Errorf("RPSTargetTPS %v not in (0, throughput=%v]", out.RPSTargetTPS, out.Throughput)
+	}
+	if out.RPSTargetUserTPS >= out.RPSTargetITL {
+		t.Errorf("user TPS 60 (ITL<16.7) should bind before ITL 20: %v >= %v", out.RPSTargetUserTPS, out.RPSTargetITL)
+	}
+
+	// unattainable aggregate TPS
+	pd.TargetTPS = 1e6
+	if w := postJSON(t, a, "/target", pd); w.Code != http.StatusBadRequest {
+		t.Errorf("status got %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,183 @@
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// request arriving to the server
+type Request struct {
+	ArrivalTime  float64 `json:"arrivalTime"`  // arrival time (msec)
+	InputTokens  int     `json:"inputTokens"`  // number of input tokens
+	OutputTokens int     `json:"outputTokens"` // number of output tokens (>= 1)
+}
+
+// source of requests in order of arrival time
+type Arrivals interface {
+	// next request, false if there are no more
+	Next() (Request, bool)
+}
+
+// Poisson arrivals of requests with sizes drawn from a request size
+type PoissonArrivals struct {
+	rate   float64 // arrival rate (requests/msec)
+	tokens *tokenSampler
+	rng    *rand.Rand // inter-arrival times
+	now    float64    // time of the last arrival (msec)
+}
+
+// Poisson arrivals at a rate (requests/sec), with sizes drawn from the token distributions of the
+// request size, or its averages. Inter-arrival times and request sizes are drawn from separate
+// random streams of the seed, so that two sources with the same seed and request size see the
+// same sizes in the same order, and arrival times scaled by the ratio of their rates (common
+// random numbers).
+func NewPoissonArrivals(rate float32, requestSize *analyzer.RequestSize, seed uint64) (*PoissonArrivals, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("invalid arrival rate %v", rate)
+	}
+	tokens, err := newTokenSampler(requestSize, seed)
+	if err != nil {
+		return nil, err
+	}
+	return &PoissonArrivals{
+		rate:   float64(rate) / 1000,
+		tokens: tokens,
+		rng:    rand.New(rand.NewPCG(seed, arrivalStream)),
+	}, nil
+}
+
+func (a *PoissonArrivals) Next() (Request, bool) {
+	a.now += a.rng.ExpFloat64() / a.rate
+	in, out := a.tokens.sample()
+	return Request{ArrivalTime: a.now, InputTokens: in, OutputTokens: out}, true
+}
+
+// trace of requests replayed in order of arrival time
+type TraceArrivals struct {
+	requests []Request
+	next     int
+}
+
+// arrivals replaying a trace of requests (sorted by arrival time)
+func NewTraceArrivals(requests []Request) (*TraceArrivals, error) {
+	for _, r := range requests {
+		if r.ArrivalTime < 0 || r.InputTokens < 0 || r.OutputTokens < 1 {
+			return nil, fmt.Errorf("invalid request %+v", r)
+		}
+	}
+	sorted := slices.Clone(requests)
+	slices.SortStableFunc(sorted, func(a, b Request) int {
+		switch {
+		case a.ArrivalTime < b.ArrivalTime:
+			return -1
+		case a.ArrivalTime > b.ArrivalTime:
+			return 1
+		}
+		return 0
+	})
+	return &TraceArrivals{requests: sorted}, nil
+}
+
+// read a trace of requests from a JSON file (array of requests)
+func LoadTrace(path string) (*TraceArrivals, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var requests []Request
+	if err := json.Unmarshal(data, &requests); err != nil {
+		return nil, fmt.Errorf("invalid trace: %v", err)
+	}
+	return NewTraceArrivals(requests)
+}
+
+func (a *TraceArrivals) Next() (Request, bool) {
+	if a.next >= len(a.requests) {
+		return Request{}, false
+	}
+	a.next++
+	return a.requests[a.next-1], true
+}
+
+// number of requests in the trace
+func (a *TraceArrivals) Len() int {
+	return len(a.requests)
+}
+
+// random streams of a seed
+const (
+	arrivalStream = iota + 1
+	tokenStream
+)
+
+// sampler of the numbers of input and output tokens of a request size
+type tokenSampler struct {
+	input, output []float64 // cumulative probabilities of the points of the distributions (nil => averages)
+	size          *analyzer.RequestSize
+	rng           *rand.Rand
+}
+
+func newTokenSampler(r *analyzer.RequestSize, seed uint64) (*tokenSampler, error) {
+	if r == nil || r.AvgInputTokens < 0 || r.AvgOutputTokens < 1 {
+		return nil, fmt.Errorf("invalid request size %s", r)
+	}
+	return &tokenSampler{
+		input:  cumulative(r.InputTokens),
+		output: cumulative(r.OutputTokens),
+		size:   r,
+		rng:    rand.New(rand.NewPCG(seed, tokenStream)),
+	}, nil
+}
+
+// numbers of input and output tokens of the next request
+func (s *tokenSampler) sample() (in, out int) {
+	in = s.round(s.draw(s.size.InputTokens, s.input, s.size.AvgInputTokens))
+	out = max(s.round(s.draw(s.size.OutputTokens, s.output, s.size.AvgOutputTokens)), 1)
+	return in, out
+}
+
+// value of a point of the distribution drawn at random, or the average
+func (s *tokenSampler) draw(d *analyzer.TokenDistribution, cdf []float64, avg float32) float64 {
+	if d == nil {
+		return float64(avg)
+	}
+	u := s.rng.Float64()
+	i, _ := slices.BinarySearch(cdf, u)
+	return float64(d.Values[min(i, len(d.Values)-1)])
+}
+
+// integer rounding preserving the mean: floor(x) + Bernoulli(x - floor(x))
+func (s *tokenSampler) round(x float64) int {
+	n := math.Floor(x)
+	if s.rng.Float64() < x-n {
+		n++
+	}
+	return int(n)
+}
+
+// cumulative probabilities of the points of a distribution, nil if none
+func cumulative(d *analyzer.TokenDistribution) []float64 {
+	if d == nil {
+		return nil
+	}
+	cdf := make([]float64, len(d.Values))
+	var total float64
+	for i := range cdf {
+		w := 1.0
+		if d.Weights != nil {
+			w = float64(d.Weights[i])
+		}
+		total += w
+		cdf[i] = total
+	}
+	for i := range cdf {
+		cdf[i] /= total
+	}
+	return cdf
+}
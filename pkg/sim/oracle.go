@@ -0,0 +1,166 @@
+package sim
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// default parameters of the simulation-backed concurrency oracle
+const (
+	DefaultOracleRequests       = 4000
+	DefaultOracleRateIterations = 10
+)
+
+// Concurrency oracle estimating f(M), the throughput sustainable at concurrency cap M while meeting
+// the targets, by simulation rather than by the analytic model (analyzer.LLMQueueAnalyzer.Size).
+// At cap M (MaxBatchSize = M), the max request rate meeting the targets is found by bisection over
+// the rate range of the analytic model at M, each step a simulation of Poisson arrivals; f(M) is
+// the simulated throughput at that rate.
+//
+// By default all simulations draw from the same seed (common random numbers): they see the same
+// request sizes in the same order, with arrival times scaled by the rate, so that readings at
+// neighboring caps and rates differ by the server rather than by the sample, and repeated probes at
+// the same cap return the same reading. With IndependentProbes each probe draws fresh random
+// numbers, and readings at the same cap differ, as with a live benchmark. Either way readings carry
+// the sampling error of finite simulations, and f(M) need not be monotone.
+//
+// Supported targets are TTFT, ITL, TPS, UserTPS, and the percentile TTFT and response time;
+// abandonment is not simulated.
+type ConcurrencyOracle struct {
+	ServiceParms      *analyzer.ServiceParms
+	RequestSize       *analyzer.RequestSize
+	Target            *analyzer.TargetPerf
+	MaxNumTokens      int    // token budget per iteration (0 => analyzer.DefaultMaxNumTokens)
+	MaxQueueSize      int    // max number of waiting requests
+	NumRequests       int    // measured requests per simulation (0 => DefaultOracleRequests)
+	RateIterations    int    // bisection steps of the rate search (0 => DefaultOracleRateIterations)
+	Seed              uint64 // seed of the random numbers
+	IndependentProbes bool   // each probe draws fresh random numbers (no common random numbers across probes)
+
+	numProbes      int // number of probes so far
+	numSimulations int // number of simulations so far
+}
+
+// Oracle reading at concurrency cap m, to be used as analyzer.ConcurrencyOptimizer.Oracle:
+// the simulated throughput (requests/sec) at the max rate meeting the targets, and false if the
+// targets are not met even at the lowest rate (or the oracle parameters are invalid, see Check).
+func (o *ConcurrencyOracle) Throughput(m int) (throughput float32, feasible bool) {
+	if o.Check() != nil {
+		return 0, false
+	}
+	seed := o.Seed
+	if o.IndependentProbes {
+		seed += uint64(o.numProbes)
+	}
+	o.numProbes++
+
+	config := &analyzer.Configuration{
+		MaxBatchSize: m,
+		MaxNumTokens: o.MaxNumTokens,
+		MaxQueueSize: o.MaxQueueSize,
+		ServiceParms: o.ServiceParms,
+	}
+	qa, err := analyzer.NewLLMQueueAnalyzer(config, o.RequestSize)
+	if err != nil {
+		return 0, false
+	}
+	simConfig := &Config{
+		Configuration: config,
+		NumRequests:   o.numRequests(),
+		Percentile:    o.Target.Percentile,
+	}
+
+	// simulated throughput at a rate, and whether the latency targets are met
+	eval := func(rate float32) (float32, bool) {
+		o.numSimulations++
+		arrivals, err := NewPoissonArrivals(rate, o.RequestSize, seed)
+		if err != nil {
+			return 0, false
+		}
+		r, err := Simulate(simConfig, arrivals)
+		if err != nil {
+			return 0, false
+		}
+		return r.Metrics.Throughput, o.meetsLatencyTargets(r.Metrics)
+	}
+
+	// max rate meeting the latency targets, by bisection
+	lo, hi := qa.RateRange.Min, qa.RateRange.Max
+	if throughput, feasible = eval(lo); !feasible {
+		return 0, false
+	}
+	if t, ok := eval(hi); ok {
+		throughput = t
+	} else {
+		for range o.rateIterations() {
+			mid := (lo + hi) / 2
+			if t, ok := eval(mid); ok {
+				lo, throughput = mid, t
+			} else {
+				hi = mid
+			}
+		}
+	}
+
+	// aggregate TPS increases with the rate, hence it is met at the max rate or not at all
+	if o.Target.TargetTPS > 0 && throughput*o.RequestSize.AvgOutputTokens < o.Target.TargetTPS {
+		return 0, false
+	}
+	return throughput, true
+}
+
+// Check validity of the oracle parameters
+func (o *ConcurrencyOracle) Check() error {
+	if o.ServiceParms == nil || o.RequestSize == nil || o.Target == nil {
+		return fmt.Errorf("oracle requires ServiceParms, RequestSize, and Target")
+	}
+	if o.Target.MaxAbandonFraction > 0 {
+		return fmt.Errorf("abandonment target not supported by the simulation oracle")
+	}
+	if o.MaxNumTokens < 0 || o.MaxQueueSize < 0 || o.NumRequests < 0 || o.RateIterations < 0 {
+		return fmt.Errorf("invalid oracle parameters %s", o)
+	}
+	return nil
+}
+
+// number of probes so far
+func (o *ConcurrencyOracle) NumProbes() int {
+	return o.numProbes
+}
+
+// number of simulations so far, over all probes
+func (o *ConcurrencyOracle) NumSimulations() int {
+	return o.numSimulations
+}
+
+// whether simulated metrics meet the latency targets (all but TPS)
+func (o *ConcurrencyOracle) meetsLatencyTargets(m *analyzer.AnalysisMetrics) bool {
+	t := o.Target
+	return (t.TargetTTFT <= 0 || m.AvgTTFT <= t.TargetTTFT) &&
+		(t.TargetITL <= 0 || m.AvgTokenTime <= t.TargetITL) &&
+		(t.TargetUserTPS <= 0 || m.AvgTokenTime > 0 && 1000/m.AvgTokenTime >= t.TargetUserTPS) &&
+		(t.TargetPctTTFT <= 0 || m.PctTTFT <= t.TargetPctTTFT) &&
+		(t.TargetPctRespTime <= 0 || m.PctRespTime <= t.TargetPctRespTime)
+}
+
+func (o *ConcurrencyOracle) numRequests() int {
+	if o.NumRequests == 0 {
+		return DefaultOracleRequests
+	}
+	return o.NumRequests
+}
+
+func (o *ConcurrencyOracle) rateIterations() int {
+	if o.RateIterations == 0 {
+		return DefaultOracleRateIterations
+	}
+	return o.RateIterations
+}
+
+func (o *ConcurrencyOracle) String() string {
+	return fmt.Sprintf("{servParms=%s, requestSize=%s, target=%v, maxNumTokens=%d, maxQueue=%d, requests=%d, "+
+		"rateIterations=%d, seed=%d, independent=%t}",
+		o.ServiceParms, o.RequestSize, o.Target, o.MaxNumTokens, o.MaxQueueSize, o.NumRequests,
+		o.RateIterations, o.Seed, o.IndependentProbes)
+}
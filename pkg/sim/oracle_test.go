@@ -0,0 +1,96 @@
+package sim
+
+import (
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+func newBaselineOracle(target *analyzer.TargetPerf) *ConcurrencyOracle {
+	return &ConcurrencyOracle{
+		ServiceParms: baselineConfig(1, 0).ServiceParms,
+		RequestSize:  baselineSize(),
+		Target:       target,
+		MaxQueueSize: 128,
+		NumRequests:  1000,
+		Seed:         1,
+	}
+}
+
+func TestOracleTracksAnalyticCurve(t *testing.T) {
+	target := &analyzer.TargetPerf{TargetTTFT: 40, TargetITL: 15}
+	o := newBaselineOracle(target)
+	opt := &analyzer.ConcurrencyOptimizer{
+		ServiceParms: o.ServiceParms, RequestSize: o.RequestSize, Target: target, MaxQueueSize: o.MaxQueueSize,
+	}
+	if _, err := opt.Find(); err != nil { // sets the default oracle
+		t.Fatalf("Find: %v", err)
+	}
+	for _, m := range []int{8, 32, 128} {
+		got, ok := o.Throughput(m)
+		want, _ := opt.Oracle(m)
+		if !ok || math.Abs(float64(got/want-1)) > 0.1 {
+			t.Errorf("f(%d): simulated %v (feasible %t), analytic %v", m, got, ok, want)
+		}
+	}
+	if o.NumProbes() != 3 || o.NumSimulations() < 3*2 {
+		t.Errorf("got %d probes and %d simulations", o.NumProbes(), o.NumSimulations())
+	}
+}
+
+func TestOracleCommonRandomNumbers(t *testing.T) {
+	o := newBaselineOracle(&analyzer.TargetPerf{TargetTTFT: 40, TargetITL: 15})
+	a, _ := o.Throughput(16)
+	b, _ := o.Throughput(16)
+	if a != b {
+		t.Errorf("common random numbers: repeated readings %v and %v differ", a, b)
+	}
+	o.IndependentProbes = true
+	c, _ := o.Throughput(16)
+	d, _ := o.Throughput(16)
+	if c == d {
+		t.Errorf("independent probes: repeated readings both %v", c)
+	}
+}
+
+func TestOracleDrivesFind(t *testing.T) {
+	target := &analyzer.TargetPerf{TargetTTFT: 40, TargetITL: 15}
+	o := newBaselineOracle(target)
+	o.IndependentProbes = true
+	opt := &analyzer.ConcurrencyOptimizer{
+		ServiceParms: o.ServiceParms, RequestSize: o.RequestSize, Target: target, MaxQueueSize: o.MaxQueueSize,
+		MMax: 128, Oracle: o.Throughput,
+	}
+	res, err := opt.Find()
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if !res.Feasible || res.Metrics != nil {
+		t.Fatalf("got %+v, want a feasible result without analytic metrics", res)
+	}
+	if len(res.Probes) != o.NumProbes() {
+		t.Errorf("optimizer recorded %d probes, oracle %d", len(res.Probes), o.NumProbes())
+	}
+	// noisy readings may misdirect the bisection, but M* stays within the bracket
+	if res.Concurrency < 1 || res.Concurrency > 128 || res.Throughput < 0.8*res.AnchorThroughput {
+		t.Errorf("M*=%d with f(M*)=%v, anchor %v", res.Concurrency, res.Throughput, res.AnchorThroughput)
+	}
+}
+
+func TestOracleInfeasibleTargets(t *testing.T) {
+	// TTFT below the prefill time of a lone request
+	o := newBaselineOracle(&analyzer.TargetPerf{TargetTTFT: 5})
+	if f, ok := o.Throughput(16); ok || f != 0 {
+		t.Errorf("got %v (feasible %t), want infeasible", f, ok)
+	}
+	// TPS above the saturation throughput
+	o = newBaselineOracle(&analyzer.TargetPerf{TargetITL: 15, TargetTPS: 1e6})
+	if _, ok := o.Throughput(16); ok {
+		t.Error("unreachable TPS target reported feasible")
+	}
+	o = newBaselineOracle(&analyzer.TargetPerf{TargetTTFT: 40, MaxAbandonFraction: 0.1})
+	if o.Check() == nil {
+		t.Error("expected error for an abandonment target")
+	}
+}
@@ -0,0 +1,384 @@
+package sim
+
+import (
+	"fmt"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+// default simulation parameters
+const (
+	DefaultNumRequests    = 20000
+	DefaultWarmupFraction = 0.1
+	DefaultNumBatches     = 20
+	DefaultConfidence     = float32(0.95)
+)
+
+// simulation parameters
+type Config struct {
+	Configuration *analyzer.Configuration // server: MaxBatchSize, MaxNumTokens (0 => analyzer.DefaultMaxNumTokens), MaxQueueSize, ServiceParms
+	NumRequests   int                     // number of measured requests (0 => DefaultNumRequests; at most those of a trace after the warmup)
+	Warmup        int                     // number of initial requests not measured (0 => DefaultWarmupFraction of NumRequests)
+	NumBatches    int                     // number of batches of measured requests for the confidence intervals (0 => DefaultNumBatches)
+	Confidence    float32                 // confidence level of the confidence intervals (0 => DefaultConfidence)
+	Percentile    float32                 // percentile level of the percentile metrics (0 => analyzer.DefaultPercentile)
+}
+
+// simulation results: the metrics of the analytic model, and estimates with confidence intervals
+type Result struct {
+	Metrics        *analyzer.AnalysisMetrics // point estimates (MaxRate and the analytic variants MeanField*, Exact* are not set)
+	Confidence     float32                   // confidence level of the confidence intervals
+	Throughput     Estimate                  // throughput of admitted requests (requests/sec)
+	AvgWaitTime    Estimate                  // average request queueing time (msec)
+	AvgPrefillTime Estimate                  // average request prefill time (msec)
+	AvgTokenTime   Estimate                  // average token decode time, over output tokens (msec)
+	AvgTTFT        Estimate                  // average time to first token (msec)
+	AvgRespTime    Estimate                  // average request response time (msec)
+	PctWaitTime    Estimate                  // percentile request queueing time (msec)
+	PctTTFT        Estimate                  // percentile time to first token (msec)
+	PctRespTime    Estimate                  // percentile request response time (msec)
+	NumMeasured    int                       // number of measured requests
+	NumRejected    int                       // number of measured requests rejected on a full queue
+	NumIterations  int                       // number of batch iterations
+	SimTime        float64                   // simulated time (msec)
+}
+
+// request in the server
+type job struct {
+	Request
+	index      int     // arrival index
+	admitTime  float64 // time of entering the batch
+	prefilled  int     // number of input tokens processed
+	numChunks  int     // number of prefill chunks processed
+	prefillEnd float64 // time of the end of the prefill
+	generated  int     // number of output tokens generated
+	firstToken float64 // time of the first output token
+}
+
+// completed request measures (msec)
+type sample struct {
+	wait, prefill, decode, ttft, resp float64
+	outputTokens                      int
+}
+
+// Simulate a continuous-batching inference server at the iteration level, fed by arrivals.
+//
+// Requests wait in a FCFS queue, and are rejected when the server holds MaxQueueSize plus
+// MaxBatchSize requests (as in the analytic model). Before each iteration, waiting requests enter
+// the batch up to MaxBatchSize. Each iteration generates one output token for each request past
+// its prefill, and spends the remainder of the MaxNumTokens budget on prefill chunks of the other
+// requests, in order of entering the batch (chunked prefill). As in the work model of the
+// analyzer, the iteration time is alpha + beta * compute + gamma * memory, where compute is the
+// number of tokens processed, and memory is the context of each decode step (input tokens plus
+// tokens generated so far) plus, for the k-th prefill chunk of a request, k times its number of
+// tokens. The first output token is generated by the first iteration after the prefill, and a
+// request leaves the batch with its last output token.
+//
+// Requests before the warmup are simulated but not measured; the simulation ends when all
+// measured requests are done. Rates and the average batch size are over the measurement window,
+// from the arrival of the first measured request to the next arrival after the last (or, at the
+// end of a trace, until the measured requests are done). Confidence intervals are by batch means
+// over consecutive batches of measured requests.
+func Simulate(config *Config, arrivals Arrivals) (*Result, error) {
+	c, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	server := c.Configuration
+	parms := server.ServiceParms
+	maxNumTokens := server.MaxNumTokens
+	if maxNumTokens == 0 {
+		maxNumTokens = analyzer.DefaultMaxNumTokens
+	}
+	first, last := c.Warmup, c.Warmup+c.NumRequests // measured arrival indices [first, last)
+	batchOf := func(index int) int {
+		return (index - first) * c.NumBatches / c.NumRequests
+	}
+
+	var (
+		now           float64
+		queue         []*job
+		batch         []*job
+		numArrivals   int
+		numResolved   int
+		numIterations int
+		samples       = make([][]sample, c.NumBatches)
+		admitted      = make([]int, c.NumBatches)
+		startTime     = make([]float64, c.NumBatches+1) // arrival time of the first request of each batch, and end of the window
+		rejected      int
+		measuring     bool    // within the measurement window, from the first measured arrival to the next after the last
+		busyArea      float64 // integral of the batch size over the measurement window
+		lastStart     float64 // start time of the last iteration
+		lastBatchSize int     // batch size of the last iteration
+	)
+	// integral of the batch size from time t (within or after the last iteration) to now
+	areaSince := func(t float64) float64 {
+		return float64(lastBatchSize) * max(now-max(t, lastStart), 0)
+	}
+	pending, hasPending := arrivals.Next()
+
+	for numResolved < c.NumRequests {
+		if len(batch) == 0 && len(queue) == 0 {
+			if !hasPending {
+				break
+			}
+			now = max(now, pending.ArrivalTime)
+		}
+
+		// arrivals up to now join the queue, or are rejected if the server is full
+		for hasPending && pending.ArrivalTime <= now {
+			index := numArrivals
+			numArrivals++
+			measured := index >= first && index < last
+			switch {
+			case index == first:
+				measuring = true
+				busyArea = areaSince(pending.ArrivalTime)
+			case index == last:
+				measuring = false
+				busyArea -= areaSince(pending.ArrivalTime)
+				startTime[c.NumBatches] = pending.ArrivalTime
+			}
+			if measured && (index == first || batchOf(index-1) != batchOf(index)) {
+				startTime[batchOf(index)] = pending.ArrivalTime
+			}
+			if len(queue)+len(batch) < server.MaxQueueSize+server.MaxBatchSize {
+				queue = append(queue, &job{Request: pending, index: index})
+				if measured {
+					admitted[batchOf(index)]++
+				}
+			} else if measured {
+				rejected++
+				numResolved++
+			}
+			pending, hasPending = arrivals.Next()
+		}
+
+		// waiting requests enter the batch
+		for len(queue) > 0 && len(batch) < server.MaxBatchSize {
+			j := queue[0]
+			queue = queue[1:]
+			j.admitTime = now
+			if j.InputTokens == 0 {
+				j.prefillEnd = now
+			}
+			batch = append(batch, j)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		// iteration: decode steps, then prefill chunks within the token budget
+		var compute, memory float64
+		budget := maxNumTokens
+		prefilling := make([]int, len(batch)) // tokens of the prefill chunk of each request in the batch
+		for _, j := range batch {
+			if j.prefilled == j.InputTokens {
+				compute++
+				memory += float64(j.InputTokens + j.generated + 1)
+				budget--
+			}
+		}
+		for i, j := range batch {
+			if j.prefilled < j.InputTokens && budget > 0 {
+				x := min(j.InputTokens-j.prefilled, budget)
+				prefilling[i] = x
+				budget -= x
+				compute += float64(x)
+				memory += float64(x * (j.numChunks + 1))
+			}
+		}
+		lastStart, lastBatchSize = now, len(batch)
+		now += float64(parms.Alpha) + float64(parms.Beta)*compute + float64(parms.Gamma)*memory
+		numIterations++
+		if measuring {
+			busyArea += areaSince(lastStart)
+		}
+
+		// progress of the requests in the batch
+		remaining := batch[:0]
+		for i, j := range batch {
+			switch {
+			case prefilling[i] > 0:
+				j.prefilled += prefilling[i]
+				j.numChunks++
+				if j.prefilled == j.InputTokens {
+					j.prefillEnd = now
+				}
+			case j.prefilled == j.InputTokens:
+				j.generated++
+				if j.generated == 1 {
+					j.firstToken = now
+				}
+			}
+			if j.generated < j.OutputTokens {
+				remaining = append(remaining, j)
+				continue
+			}
+			if j.index >= first && j.index < last {
+				b := batchOf(j.index)
+				samples[b] = append(samples[b], sample{
+					wait:         j.admitTime - j.ArrivalTime,
+					prefill:      j.prefillEnd - j.admitTime,
+					decode:       now - j.prefillEnd,
+					ttft:         j.firstToken - j.ArrivalTime,
+					resp:         now - j.ArrivalTime,
+					outputTokens: j.OutputTokens,
+				})
+				numResolved++
+			}
+		}
+		clear(batch[len(remaining):])
+		batch = remaining
+	}
+	if numArrivals < last {
+		return nil, fmt.Errorf("arrivals ended after %d requests, %d needed", numArrivals, last)
+	}
+	if measuring {
+		// no arrival after the measured requests: the window ends when they are done
+		startTime[c.NumBatches] = now
+	}
+	return newResult(c, samples, admitted, rejected, startTime, busyArea, numIterations, now), nil
+}
+
+// results from the samples of the measured requests
+func newResult(c *Config, samples [][]sample, admitted []int, rejected int, startTime []float64,
+	busyArea float64, numIterations int, simTime float64) *Result {
+	confidence := float64(c.Confidence)
+	percentile := float64(c.Percentile)
+	field := func(f func(s sample) float64) [][]float64 {
+		values := make([][]float64, len(samples))
+		for b, batch := range samples {
+			for _, s := range batch {
+				values[b] = append(values[b], f(s))
+			}
+		}
+		return values
+	}
+	pct := func(values []float64) float64 { return quantile(values, percentile) }
+
+	// decode time per output token, weighted by the number of output tokens
+	var tokenTime Estimate
+	{
+		var perBatch []float64
+		var totalTime, totalTokens float64
+		for _, batch := range samples {
+			var t, n float64
+			for _, s := range batch {
+				t += s.decode
+				n += float64(s.outputTokens)
+			}
+			if n > 0 {
+				perBatch = append(perBatch, t/n)
+			}
+			totalTime += t
+			totalTokens += n
+		}
+		if totalTokens > 0 {
+			tokenTime = intervalEstimate(totalTime/totalTokens, perBatch, confidence)
+		}
+	}
+
+	// rates over the arrival times of the batches (requests/sec)
+	duration := startTime[len(startTime)-1] - startTime[0]
+	var rates []float64
+	for b, n := range admitted {
+		if d := startTime[b+1] - startTime[b]; d > 0 {
+			rates = append(rates, float64(n)/d*1000)
+		}
+	}
+	var numAdmitted int
+	for _, n := range admitted {
+		numAdmitted += n
+	}
+	var throughput, offeredRate, avgNumInServ float64
+	if duration > 0 {
+		throughput = float64(numAdmitted) / duration * 1000
+		offeredRate = float64(c.NumRequests) / duration * 1000
+		avgNumInServ = busyArea / duration
+	}
+
+	r := &Result{
+		Confidence:     c.Confidence,
+		Throughput:     intervalEstimate(throughput, rates, confidence),
+		AvgWaitTime:    batchEstimate(field(func(s sample) float64 { return s.wait }), mean, confidence),
+		AvgPrefillTime: batchEstimate(field(func(s sample) float64 { return s.prefill }), mean, confidence),
+		AvgTokenTime:   tokenTime,
+		AvgTTFT:        batchEstimate(field(func(s sample) float64 { return s.ttft }), mean, confidence),
+		AvgRespTime:    batchEstimate(field(func(s sample) float64 { return s.resp }), mean, confidence),
+		PctWaitTime:    batchEstimate(field(func(s sample) float64 { return s.wait }), pct, confidence),
+		PctTTFT:        batchEstimate(field(func(s sample) float64 { return s.ttft }), pct, confidence),
+		PctRespTime:    batchEstimate(field(func(s sample) float64 { return s.resp }), pct, confidence),
+		NumMeasured:    c.NumRequests,
+		NumRejected:    rejected,
+		NumIterations:  numIterations,
+		SimTime:        simTime,
+	}
+	r.Metrics = &analyzer.AnalysisMetrics{
+		OfferedRate:    float32(offeredRate),
+		Throughput:     r.Throughput.Value,
+		AvgRespTime:    r.AvgRespTime.Value,
+		AvgWaitTime:    r.AvgWaitTime.Value,
+		AvgNumInServ:   float32(avgNumInServ),
+		AvgPrefillTime: r.AvgPrefillTime.Value,
+		AvgTokenTime:   r.AvgTokenTime.Value,
+		AvgTTFT:        r.AvgTTFT.Value,
+		Rho:            float32(avgNumInServ) / float32(c.Configuration.MaxBatchSize),
+		Percentile:     c.Percentile,
+		PctWaitTime:    r.PctWaitTime.Value,
+		PctTTFT:        r.PctTTFT.Value,
+		PctRespTime:    r.PctRespTime.Value,
+		Goodput:        r.Throughput.Value,
+	}
+	return r
+}
+
+// simulation parameters with defaults applied
+func (c *Config) withDefaults() (*Config, error) {
+	if err := c.check(); err != nil {
+		return nil, err
+	}
+	d := *c
+	if d.NumRequests == 0 {
+		d.NumRequests = DefaultNumRequests
+	}
+	if d.Warmup == 0 {
+		d.Warmup = int(DefaultWarmupFraction * float32(d.NumRequests))
+	}
+	if d.NumBatches == 0 {
+		d.NumBatches = min(DefaultNumBatches, d.NumRequests)
+	}
+	if d.Confidence == 0 {
+		d.Confidence = DefaultConfidence
+	}
+	if d.Percentile == 0 {
+		d.Percentile = analyzer.DefaultPercentile
+	}
+	if d.NumBatches > d.NumRequests {
+		return nil, fmt.Errorf("invalid simulation config %s: more batches than requests", c)
+	}
+	return &d, nil
+}
+
+// check validity of simulation parameters
+func (c *Config) check() error {
+	s := c.Configuration
+	if s == nil || s.ServiceParms == nil || s.MaxBatchSize <= 0 || s.MaxQueueSize < 0 || s.MaxNumTokens < 0 ||
+		c.NumRequests < 0 || c.Warmup < 0 || c.NumBatches < 0 || c.NumBatches == 1 ||
+		c.Confidence < 0 || c.Confidence >= 1 || c.Percentile < 0 || c.Percentile >= 1 {
+		return fmt.Errorf("invalid simulation config %s", c)
+	}
+	return nil
+}
+
+func (c *Config) String() string {
+	return fmt.Sprintf("{config=%s, requests=%d, warmup=%d, batches=%d, confidence=%.3f, percentile=%.3f}",
+		c.Configuration, c.NumRequests, c.Warmup, c.NumBatches, c.Confidence, c.Percentile)
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("{tput=%s, wait=%s, prefill=%s, itl=%s, ttft=%s, resp=%s, pctWait=%s, pctTTFT=%s, pctResp=%s, "+
+		"measured=%d, rejected=%d, iterations=%d, time=%.1f}",
+		r.Throughput, r.AvgWaitTime, r.AvgPrefillTime, r.AvgTokenTime, r.AvgTTFT, r.AvgRespTime,
+		r.PctWaitTime, r.PctTTFT, r.PctRespTime, r.NumMeasured, r.NumRejected, r.NumIterations, r.SimTime)
+}
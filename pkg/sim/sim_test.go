@@ -0,0 +1,196 @@
+package sim
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/llm-inferno/queue-analysis/pkg/analyzer"
+)
+
+func baselineConfig(maxBatchSize, maxQueueSize int) *analyzer.Configuration {
+	return &analyzer.Configuration{
+		MaxBatchSize: maxBatchSize,
+		MaxQueueSize: maxQueueSize,
+		ServiceParms: &analyzer.ServiceParms{Alpha: 8, Beta: 0.033, Gamma: 0.000333},
+	}
+}
+
+func baselineSize() *analyzer.RequestSize {
+	return &analyzer.RequestSize{AvgInputTokens: 64, AvgOutputTokens: 128}
+}
+
+func simulate(t *testing.T, config *Config, rate float32, size *analyzer.RequestSize, seed uint64) *Result {
+	t.Helper()
+	arrivals, err := NewPoissonArrivals(rate, size, seed)
+	if err != nil {
+		t.Fatalf("NewPoissonArrivals: %v", err)
+	}
+	r, err := Simulate(config, arrivals)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	return r
+}
+
+func TestSimulateMatchesMD1(t *testing.T) {
+	// one request in service, no prefill and one output token: constant service time D
+	config := baselineConfig(1, 10000)
+	sp := config.ServiceParms
+	D := float64(sp.Alpha + sp.Beta + sp.Gamma)
+	size := &analyzer.RequestSize{AvgInputTokens: 0, AvgOutputTokens: 1}
+	rho := 0.7
+	rate := float32(rho / D * 1000)
+
+	r := simulate(t, &Config{Configuration: config, NumRequests: 100000}, rate, size, 1)
+	want := rho * D / (2 * (1 - rho)) // Pollaczek-Khinchine
+	got := r.AvgWaitTime
+	if math.Abs(float64(got.Value)-want) > 2*float64(got.HalfWidth) {
+		t.Errorf("M/D/1 wait: got %s, want %.3f", got, want)
+	}
+	if math.Abs(float64(r.AvgRespTime.Value)-want-D) > 2*float64(r.AvgRespTime.HalfWidth) {
+		t.Errorf("M/D/1 response: got %s, want %.3f", r.AvgRespTime, want+D)
+	}
+	if math.Abs(float64(r.Metrics.Rho)-rho) > 0.02 {
+		t.Errorf("utilization: got %v, want %v", r.Metrics.Rho, rho)
+	}
+	if r.NumRejected != 0 {
+		t.Errorf("rejected %d requests with an unbounded queue", r.NumRejected)
+	}
+}
+
+func TestSimulateAgreesWithAnalyzer(t *testing.T) {
+	config := baselineConfig(64, 128)
+	size := baselineSize()
+	qa, err := analyzer.NewLLMQueueAnalyzer(config, size)
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	rate := 0.5 * qa.RateRange.Max
+	metrics, err := qa.Analyze(rate)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	r := simulate(t, &Config{Configuration: config}, rate, size, 1)
+	for _, c := range []struct {
+		name      string
+		got, want float32
+	}{
+		{"throughput", r.Metrics.Throughput, metrics.Throughput},
+		{"batch size", r.Metrics.AvgNumInServ, metrics.AvgNumInServ},
+		{"ITL", r.Metrics.AvgTokenTime, metrics.AvgTokenTime},
+		{"response time", r.Metrics.AvgRespTime, metrics.AvgRespTime},
+	} {
+		if math.Abs(float64(c.got/c.want-1)) > 0.1 {
+			t.Errorf("%s: simulated %v, analytic %v", c.name, c.got, c.want)
+		}
+	}
+	if r.Throughput.HalfWidth <= 0 || r.AvgRespTime.HalfWidth <= 0 {
+		t.Errorf("missing confidence intervals: %s", r)
+	}
+}
+
+func TestSimulateTrace(t *testing.T) {
+	// hand-computed iterations of alpha + beta * tokens
+	config := baselineConfig(4, 0)
+	config.ServiceParms = &analyzer.ServiceParms{Alpha: 10, Beta: 1, Gamma: 0}
+	trace, err := NewTraceArrivals([]Request{
+		{ArrivalTime: 5, InputTokens: 0, OutputTokens: 1},
+		{ArrivalTime: 0, InputTokens: 3, OutputTokens: 2},
+	})
+	if err != nil {
+		t.Fatalf("NewTraceArrivals: %v", err)
+	}
+	r, err := Simulate(&Config{Configuration: config, NumRequests: 2, NumBatches: 2}, trace)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	// request at 0: prefill [0, 13], tokens at 13 + 12 = 25 (with the other request) and 36
+	// request at 5: waits until 13, token at 25
+	if r.NumIterations != 3 || r.SimTime != 36 {
+		t.Errorf("got %d iterations ending at %v, want 3 at 36", r.NumIterations, r.SimTime)
+	}
+	if got, want := r.AvgWaitTime.Value, float32(8)/2; got != want {
+		t.Errorf("wait: got %v, want %v", got, want)
+	}
+	if got, want := r.AvgTTFT.Value, float32(25+20)/2; got != want {
+		t.Errorf("TTFT: got %v, want %v", got, want)
+	}
+	if got, want := r.AvgRespTime.Value, float32(36+20)/2; got != want {
+		t.Errorf("response time: got %v, want %v", got, want)
+	}
+}
+
+func TestSimulateSingleBatch(t *testing.T) {
+	config := baselineConfig(4, 0)
+	trace, err := NewTraceArrivals([]Request{{ArrivalTime: 0, InputTokens: 3, OutputTokens: 2}})
+	if err != nil {
+		t.Fatalf("NewTraceArrivals: %v", err)
+	}
+	r, err := Simulate(&Config{Configuration: config, NumRequests: 1}, trace)
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	// a single request makes a single batch, with no confidence interval
+	if r.AvgTTFT.NumBatches != 1 || r.AvgTTFT.HalfWidth != 0 {
+		t.Errorf("TTFT: got %s over %d batches, want no interval over 1", r.AvgTTFT, r.AvgTTFT.NumBatches)
+	}
+	if _, err := json.Marshal(r); err != nil {
+		t.Errorf("json.Marshal: %v", err)
+	}
+}
+
+func TestSimulateRejectsOnFullServer(t *testing.T) {
+	config := baselineConfig(8, 4)
+	qa, err := analyzer.NewLLMQueueAnalyzer(config, baselineSize())
+	if err != nil {
+		t.Fatalf("NewLLMQueueAnalyzer: %v", err)
+	}
+	r := simulate(t, &Config{Configuration: config, NumRequests: 5000}, 2*qa.RateRange.Max, baselineSize(), 1)
+	if r.NumRejected == 0 {
+		t.Fatal("no rejections at twice the maximum rate")
+	}
+	if r.Metrics.Throughput >= r.Metrics.OfferedRate || r.Metrics.Throughput > 1.1*qa.RateRange.Max {
+		t.Errorf("throughput %v not limited (offered %v, max %v)", r.Metrics.Throughput, r.Metrics.OfferedRate, qa.RateRange.Max)
+	}
+}
+
+func TestSimulateIsReproducible(t *testing.T) {
+	config := &Config{Configuration: baselineConfig(16, 64), NumRequests: 2000}
+	size := &analyzer.RequestSize{
+		AvgInputTokens: 64, AvgOutputTokens: 128,
+		OutputTokens: &analyzer.TokenDistribution{Values: []float32{32, 224}},
+	}
+	a := simulate(t, config, 2, size, 7)
+	b := simulate(t, config, 2, size, 7)
+	if *a.Metrics != *b.Metrics {
+		t.Errorf("same seed, different results:\n%s\n%s", a, b)
+	}
+	c := simulate(t, config, 2, size, 8)
+	if *a.Metrics == *c.Metrics {
+		t.Errorf("different seeds, same results: %s", a)
+	}
+}
+
+func TestSimulateRejectsInvalidConfig(t *testing.T) {
+	arrivals, err := NewPoissonArrivals(1, baselineSize(), 1)
+	if err != nil {
+		t.Fatalf("NewPoissonArrivals: %v", err)
+	}
+	for _, config := range []*Config{
+		{},
+		{Configuration: baselineConfig(0, 0)},
+		{Configuration: baselineConfig(8, 0), NumBatches: 1},
+		{Configuration: baselineConfig(8, 0), Confidence: 1},
+		{Configuration: baselineConfig(8, 0), NumRequests: 10, NumBatches: 20},
+	} {
+		if _, err := Simulate(config, arrivals); err == nil {
+			t.Errorf("expected error for %s", config)
+		}
+	}
+	trace, _ := NewTraceArrivals([]Request{{ArrivalTime: 0, OutputTokens: 1}})
+	if _, err := Simulate(&Config{Configuration: baselineConfig(8, 0), NumRequests: 10}, trace); err == nil {
+		t.Error("expected error for a trace shorter than the requests")
+	}
+}
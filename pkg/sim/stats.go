@@ -0,0 +1,82 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/llm-inferno/queue-analysis/pkg/utils"
+)
+
+// point estimate with a confidence interval over the batch means;
+// with fewer than two (non-empty) batches there is no interval and HalfWidth is zero
+type Estimate struct {
+	Value      float32 `json:"value"`      // point estimate
+	HalfWidth  float32 `json:"halfWidth"`  // half-width of the confidence interval (0 => none)
+	NumBatches int     `json:"numBatches"` // number of batches in the confidence interval
+}
+
+// estimate of a statistic over all samples, with the confidence interval of the batch means:
+// the statistic over each batch of consecutive samples, t-distributed across batches
+func batchEstimate(samples [][]float64, statistic func([]float64) float64, confidence float64) Estimate {
+	var all []float64
+	values := make([]float64, 0, len(samples))
+	for _, batch := range samples {
+		all = append(all, batch...)
+		if len(batch) > 0 {
+			values = append(values, statistic(batch))
+		}
+	}
+	if len(all) == 0 {
+		return Estimate{}
+	}
+	return intervalEstimate(statistic(all), values, confidence)
+}
+
+// estimate of value with the confidence interval of the batch values
+func intervalEstimate(value float64, values []float64, confidence float64) Estimate {
+	return Estimate{
+		Value:      float32(value),
+		HalfWidth:  float32(halfWidth(values, confidence)),
+		NumBatches: len(values),
+	}
+}
+
+// half-width of the confidence interval of the mean of independent values (0 for fewer than two values)
+func halfWidth(values []float64, confidence float64) float64 {
+	n := len(values)
+	if n < 2 {
+		return 0
+	}
+	m := mean(values)
+	var ss float64
+	for _, v := range values {
+		ss += (v - m) * (v - m)
+	}
+	sd := math.Sqrt(ss / float64(n-1))
+	return utils.TQuantile((1+confidence)/2, n-1) * sd / math.Sqrt(float64(n))
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// q-quantile of values, interpolated linearly between order statistics
+func quantile(values []float64, q float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	x := q * float64(len(sorted)-1)
+	i := int(x)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (x-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%.3f ± %.3f", e.Value, e.HalfWidth)
+}
@@ -71,6 +71,26 @@ func BinarySearch(xMin float32, xMax float32, yTarget float32,
 	return xStar, 0, nil
 }
 
+// p-quantile of the Student t distribution with dof degrees of freedom: exact for 1 and 2 degrees
+// of freedom, and by the Cornish-Fisher expansion around the normal quantile otherwise
+// (Abramowitz and Stegun 26.7.5)
+func TQuantile(p float64, dof int) float64 {
+	switch dof {
+	case 1:
+		return math.Tan(math.Pi * (p - 0.5))
+	case 2:
+		return (2*p - 1) / math.Sqrt(2*p*(1-p))
+	}
+	z := math.Sqrt2 * math.Erfinv(2*p-1)
+	v := float64(dof)
+	z2 := z * z
+	g1 := z * (z2 + 1) / 4
+	g2 := z * ((5*z2+16)*z2 + 3) / 96
+	g3 := z * (((3*z2+19)*z2+17)*z2 - 15) / 384
+	g4 := z * ((((79*z2+776)*z2+1482)*z2-1920)*z2 - 945) / 92160
+	return z + g1/v + g2/(v*v) + g3/(v*v*v) + g4/(v*v*v*v)
+}
+
 // Function used in binary search (target service time)
 func EvalServTime(model *queue.MM1ModelStateDependent) func(x float32) (float32, error) {
 	return func(x float32) (float32, error) {